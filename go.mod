@@ -6,7 +6,9 @@ require (
 	github.com/SENERGY-Platform/platform-connector-lib v0.0.0-20210930074249-f0f2d7c8f5ac
 	github.com/SENERGY-Platform/process-deployment v0.0.0-20210824112758-7165db49cc7a
 	github.com/SENERGY-Platform/senergy-platform-connector v0.0.0-20211018135105-982763a59c1e
+	github.com/eclipse/paho.mqtt.golang v1.3.5
 	github.com/satori/go.uuid v1.2.0
+	github.com/segmentio/kafka-go v0.4.20
 )
 
 require (
@@ -20,7 +22,6 @@ require (
 	github.com/eapache/go-resiliency v1.2.0 // indirect
 	github.com/eapache/go-xerial-snappy v0.0.0-20180814174437-776d5712da21 // indirect
 	github.com/eapache/queue v1.1.0 // indirect
-	github.com/eclipse/paho.mqtt.golang v1.3.5 // indirect
 	github.com/golang-jwt/jwt v3.2.2+incompatible // indirect
 	github.com/golang/snappy v0.0.4 // indirect
 	github.com/google/uuid v1.1.1 // indirect
@@ -44,7 +45,6 @@ require (
 	github.com/lib/pq v1.10.3 // indirect
 	github.com/pierrec/lz4 v2.6.1+incompatible // indirect
 	github.com/rcrowley/go-metrics v0.0.0-20201227073835-cf1acfcdf475 // indirect
-	github.com/segmentio/kafka-go v0.4.20 // indirect
 	golang.org/x/crypto v0.0.0-20210711020723-a769d52b0f97 // indirect
 	golang.org/x/net v0.0.0-20210614182718-04defd469f4e // indirect
 	golang.org/x/text v0.3.6 // indirect
@@ -2,17 +2,31 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"github.com/SENERGY-Platform/senergy-load-test/pkg"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/compare"
 	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/control"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/distributed"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/metrics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/report"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/scenario"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/slo"
 	"log"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 )
 
+const DefaultSloCheckInterval = 10 * time.Second
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		os.Exit(compare.Command(os.Args[2:]))
+	}
 	configLocation := flag.String("config", "config.json", "configuration file")
 	flag.Parse()
 
@@ -27,21 +41,240 @@ func main() {
 			log.Fatal("ERROR:", err)
 		}
 	} else {
-		wg := &sync.WaitGroup{}
-		defer wg.Wait()
+		err = config.Validate()
+		if err != nil {
+			log.Fatal("ERROR: invalid config ", err)
+		}
+		switch config.Mode {
+		case distributed.CoordinatorMode:
+			os.Exit(coordinate(config))
+		case distributed.WorkerMode:
+			os.Exit(work(config))
+		default:
+			os.Exit(run(config, hooks{}))
+		}
+	}
+}
 
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
+// hooks extend run for the worker mode
+type hooks struct {
+	started  func() error               //called after pkg.Start; the duration starts after it returns, an error aborts the run
+	finished func(result report.Report) //called with the written report
+}
 
-		err = pkg.Start(ctx, wg, config)
+// run executes the load test until a shutdown signal, the end of config.Duration or of the scenario, an exhausted budget (max_events, max_process_starts)
+// or a continuous slo breach and returns the exit code
+func run(config configuration.Config, hooks hooks) int {
+	config = pkg.WithRunDefaults(config)
+	err := validateThresholds(config, config.Thresholds)
+	if err != nil {
+		log.Println("ERROR: invalid thresholds", err)
+		return slo.ExitStartup
+	}
+	duration, err := optionalDuration(config.Duration, 0)
+	if err != nil {
+		log.Println("ERROR: invalid duration", err)
+		return slo.ExitStartup
+	}
+	checkInterval, err := optionalDuration(config.SloCheckInterval, DefaultSloCheckInterval)
+	if err == nil && checkInterval <= 0 {
+		err = errors.New("expect positive interval")
+	}
+	if err != nil {
+		log.Println("ERROR: invalid slo_check_interval", err)
+		return slo.ExitStartup
+	}
+	warmup, err := optionalDuration(config.SloWarmup, 0)
+	if err != nil {
+		log.Println("ERROR: invalid slo_warmup", err)
+		return slo.ExitStartup
+	}
+	var runner *scenario.Runner
+	if config.ScenarioLocation != "" {
+		phases, err := scenario.Load(config.ScenarioLocation)
+		if err != nil {
+			log.Println("ERROR: invalid scenario", err)
+			return slo.ExitStartup
+		}
+		for _, phase := range phases.Phases {
+			err = slo.ValidateConsumer(phase.Thresholds, config.LatencyConsumer.Type != "")
+			if err != nil {
+				log.Println("ERROR: invalid scenario thresholds of", phase.Name, err)
+				return slo.ExitStartup
+			}
+		}
+		runner = scenario.NewRunner(phases)
+	}
+
+	//a signal during the startup cancels it; the instances started so far are shut down in order (see pkg.shutdown)
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	started := time.Now()
+	wg := &sync.WaitGroup{}
+	ctx, cancel := context.WithCancel(signals)
+
+	err = pkg.Start(ctx, wg, config)
+	if err != nil {
+		log.Println("ERROR: ", err)
+		cancel()
+		wg.Wait()
+		return slo.ExitStartup
+	}
+	if hooks.started != nil {
+		err = hooks.started()
 		if err != nil {
 			log.Println("ERROR: ", err)
-			return
+			cancel()
+			wg.Wait()
+			return slo.ExitStartup
 		}
+	}
+	var scenarioDone <-chan struct{}
+	if runner != nil {
+		runner.Run(ctx)
+		scenarioDone = runner.Done()
+	}
+
+	var end <-chan time.Time
+	if duration > 0 {
+		end = time.After(duration)
+	}
+	breach := make(chan []slo.Result, 1)
+	if config.SloEvaluation == slo.EvaluateContinuously && len(config.Thresholds) > 0 {
+		slo.Watch(ctx, config.Thresholds, checkInterval, warmup, metrics.Summary, func(results []slo.Result) {
+			breach <- results
+		})
+	}
+
+	var breached []slo.Result
+	select {
+	case <-signals.Done():
+		log.Println("received shutdown signal")
+	case <-end:
+		log.Println("INFO: duration reached", config.Duration)
+	case <-control.Exhausted():
+		log.Println("INFO: budget exhausted")
+	case breached = <-breach:
+		log.Println("WARNING: slo breached; abort run")
+	case <-control.ShutdownRequested():
+		log.Println("INFO: shutdown requested by api")
+	case <-scenarioDone:
+		log.Println("INFO: end of scenario reached")
+	}
+
+	//stops every instance in order (see pkg.shutdown)
+	cancel()
+	if runner != nil {
+		<-runner.Done()
+	}
+	wg.Wait()
+
+	result := report.New(config, started, time.Now())
+	if runner != nil {
+		result.Phases = runner.Results()
+	}
+	code := finish(config, &result, breached)
+	if hooks.finished != nil {
+		hooks.finished(result)
+	}
+	return code
+}
+
+// finish evaluates the thresholds against result, writes the report and returns the exit code (see report.Report.ExitCode)
+func finish(config configuration.Config, result *report.Report, breached []slo.Result) int {
+	var err error
+	result.Slo, err = slo.Evaluate(config.Thresholds, result.Run)
+	if err != nil {
+		log.Println("ERROR: unable to evaluate thresholds", err)
+	}
+	if slo.ExitCode(result.Slo) == slo.ExitOk && breached != nil {
+		result.Slo = breached
+	}
+	for _, r := range result.Slo {
+		log.Println("SLO:", r.String())
+	}
+	err = result.Write(config)
+	if err != nil {
+		log.Println("ERROR: unable to write report", err)
+	}
+	return result.ExitCode()
+}
+
+// validateThresholds checks thresholds and rejects downstream metrics without a latency_consumer
+func validateThresholds(config configuration.Config, thresholds []slo.Threshold) error {
+	err := slo.Validate(thresholds)
+	if err != nil {
+		return err
+	}
+	return slo.ValidateConsumer(thresholds, config.LatencyConsumer.Type != "")
+}
+
+// coordinate assigns the fleet to workers, starts them in sync and writes the combined report (see pkg/distributed)
+func coordinate(config configuration.Config) int {
+	config = pkg.WithRunDefaults(config)
+	err := validateThresholds(config, config.Thresholds)
+	if err != nil {
+		log.Println("ERROR: invalid thresholds", err)
+		return slo.ExitStartup
+	}
+	coordinator, err := distributed.NewCoordinator(config)
+	if err != nil {
+		log.Println("ERROR: invalid coordinator config", err)
+		return slo.ExitStartup
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	result, err := coordinator.Run(ctx)
+	if err != nil {
+		log.Println("ERROR:", err)
+		return slo.ExitStartup
+	}
+	return finish(config, &result, nil)
+}
+
+// work runs the share of the fleet assigned by the coordinator and sends the report to it;
+// the emitters start paused and are resumed at the start time of the coordinator, which is sent when all workers are started
+func work(config configuration.Config) int {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	config, id, err := distributed.Join(ctx, config)
+	if err != nil {
+		log.Println("ERROR: unable to join coordinator", err)
+		return slo.ExitStartup
+	}
+	config.StartPaused = true
+	return run(config, hooks{
+		started: func() error {
+			startAt, err := distributed.Ready(ctx, config, id)
+			if err != nil {
+				return errors.New("unable to receive start time of coordinator: " + err.Error())
+			}
+			//a signal while waiting for the start time shuts the started instances down in order
+			select {
+			case <-ctx.Done():
+				return errors.New("shutdown signal before the synchronized start")
+			case <-time.After(time.Until(startAt)):
+			}
+			for _, instance := range control.Instances() {
+				instance.Resume()
+			}
+			log.Println("INFO: synchronized start of emitters")
+			return nil
+		},
+		finished: func(result report.Report) {
+			result.Run.Histograms = report.Histograms()
+			err := distributed.Submit(config, id, result)
+			if err != nil {
+				log.Println("ERROR: unable to send report to coordinator", err)
+			}
+		},
+	})
+}
 
-		shutdown := make(chan os.Signal, 1)
-		signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
-		sig := <-shutdown
-		log.Println("received shutdown signal", sig)
+// optionalDuration parses value; empty or - results in the default
+func optionalDuration(value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" || value == "-" {
+		return defaultValue, nil
 	}
+	return time.ParseDuration(value)
 }
@@ -6,6 +6,8 @@ import (
 	"github.com/SENERGY-Platform/platform-connector-lib/security"
 	"github.com/SENERGY-Platform/senergy-load-test/pkg/analytics"
 	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/metrics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
 	"log"
 	"os"
 	"runtime/debug"
@@ -13,20 +15,97 @@ import (
 )
 
 type Analytic struct {
-	Id string `json:"id"`
+	Id      string `json:"id"`
+	LocalId string `json:"local_id"`
 }
 
-func EnsureAnalytics(ctx context.Context, wg *sync.WaitGroup, config configuration.Config, hubId string) (analytics []Analytic, err error) {
-	analytics, err = LoadAnalytics(config)
+// AnalyticsList are the deployed pipelines of an instance, stored at config.AnalyticInfoLocation
+type AnalyticsList struct {
+	config configuration.Config
+	mux    sync.Mutex
+	list   []Analytic
+}
+
+func (this *AnalyticsList) Get() []Analytic {
+	if this == nil {
+		return nil
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return append([]Analytic{}, this.list...)
+}
+
+// AddDevices deploys the pipelines of devices added by scaling (see CreateAnalytics) and stores them with the other pipelines
+func (this *AnalyticsList) AddDevices(fleet []FleetDevice, stat statistics.Interface) (created int, err error) {
+	if this == nil {
+		return 0, nil
+	}
+	analytics, err := CreateAnalytics(this.config, fleet, stat)
+	if len(analytics) == 0 {
+		return 0, err
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.list = append(this.list, analytics...)
+	storeErr := StoreAnalytics(this.config, this.list)
+	if err == nil {
+		err = storeErr
+	}
+	return len(analytics), err
+}
+
+// RemoveDevices deletes the pipelines of the devices (see simulation.Remove) and stores the remaining pipelines
+func (this *AnalyticsList) RemoveDevices(localIds []string, stat statistics.Interface) (deleted int, err error) {
+	if this == nil {
+		return 0, nil
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	removed := map[string]bool{}
+	for _, id := range localIds {
+		removed[id] = true
+	}
+	remaining := []Analytic{}
+	obsolete := []Analytic{}
+	for _, a := range this.list {
+		if removed[a.LocalId] {
+			obsolete = append(obsolete, a)
+		} else {
+			remaining = append(remaining, a)
+		}
+	}
+	if len(obsolete) == 0 {
+		return 0, nil
+	}
+	deletedIds := map[string]bool{}
+	deleted, err = deleteAnalytics(this.config, obsolete, stat, func(a Analytic) {
+		deletedIds[a.Id] = true
+	})
+	//keep pipelines that could not be deleted in the list to retry on shutdown
+	for _, a := range obsolete {
+		if !deletedIds[a.Id] {
+			remaining = append(remaining, a)
+		}
+	}
+	this.list = remaining
+	storeErr := StoreAnalytics(this.config, this.list)
+	if err == nil {
+		err = storeErr
+	}
+	return deleted, err
+}
+
+func EnsureAnalytics(ctx context.Context, wg *sync.WaitGroup, config configuration.Config, fleet []FleetDevice, instance *metrics.Instance) (result *AnalyticsList, err error) {
+	analytics, err := LoadAnalytics(config)
 	if err != nil {
-		analytics, err = CreateAnalytics(config, hubId)
+		analytics, err = CreateAnalytics(config, fleet, instance.Stat)
 		if err != nil {
 			log.Println("ERROR: unable to create analytics")
 			return
 		}
 		err = StoreAnalytics(config, analytics)
 		if err != nil {
-			deleteErr := DeleteAnalytics(config, analytics)
+			_, deleteErr := DeleteAnalytics(config, analytics, instance.Stat)
 			log.Println("ERROR: unable to store analytics", err, deleteErr)
 			return
 		}
@@ -34,12 +113,14 @@ func EnsureAnalytics(ctx context.Context, wg *sync.WaitGroup, config configurati
 	if wg != nil {
 		wg.Add(1)
 	}
+	result = &AnalyticsList{config: config, list: analytics}
 	go func() {
 		<-ctx.Done()
-		err := DeleteAnalytics(config, analytics)
+		deleted, err := DeleteAnalytics(config, result.Get(), instance.Stat)
 		if err != nil {
 			log.Println("ERROR: unable to delete analytic", err)
 		}
+		instance.AddDeleted("pipelines", deleted)
 		if wg != nil {
 			wg.Done()
 		}
@@ -47,45 +128,59 @@ func EnsureAnalytics(ctx context.Context, wg *sync.WaitGroup, config configurati
 	return
 }
 
-func DeleteAnalytics(config configuration.Config, list []Analytic) interface{} {
+func DeleteAnalytics(config configuration.Config, list []Analytic, stat statistics.Interface) (deleted int, err error) {
+	return deleteAnalytics(config, list, stat, func(Analytic) {})
+}
+
+// deleteAnalytics removes the pipelines of list and calls removed for each deleted pipeline; it continues after failed removals
+func deleteAnalytics(config configuration.Config, list []Analytic, stat statistics.Interface, removed func(a Analytic)) (deleted int, err error) {
 	openidToken, err := security.GetOpenidPasswordToken(config.AuthUrl, config.AuthClientId, config.AuthClientSecret, config.UserName, config.Password)
 	if err != nil {
+		stat.Error(statistics.AuthFailed, "auth/token")
 		log.Println("ERROR:", err)
 		debug.PrintStack()
-		return err
+		return deleted, err
 	}
 	token := openidToken.JwtToken()
-	a := analytics.New(config)
+	a := analytics.New(config, stat)
 	for _, pipeline := range list {
-		a.Remove(token, pipeline.Id)
+		removeErr := a.Remove(token, pipeline.Id)
+		if removeErr != nil {
+			log.Println("ERROR: unable to remove pipeline", pipeline.Id, removeErr)
+			err = removeErr
+		} else {
+			removed(pipeline)
+			deleted++
+		}
 	}
-	return nil
+	return deleted, err
 }
 
-func CreateAnalytics(config configuration.Config, hubId string) (result []Analytic, err error) {
+func CreateAnalytics(config configuration.Config, fleet []FleetDevice, stat statistics.Interface) (result []Analytic, err error) {
 	token, err := security.GetOpenidPasswordToken(config.AuthUrl, config.AuthClientId, config.AuthClientSecret, config.UserName, config.Password)
 	if err != nil {
+		stat.Error(statistics.AuthFailed, "auth/token")
 		log.Println("ERROR:", err)
 		debug.PrintStack()
 		return result, err
 	}
-	devices, err := GetHubDeviceIds(config, hubId, token.JwtToken(), false)
+	devices, err := SelectDevices(config, fleet, func(group configuration.DeviceGroup) int64 {
+		return group.OneAnalyticsEveryNDevices
+	}, token.JwtToken())
 	if err != nil {
 		log.Println("ERROR:", err)
 		debug.PrintStack()
 		return result, err
 	}
-	a := analytics.New(config)
-	for i, device := range devices {
-		if int64(i)%config.OneAnalyticsEveryNDevices == 0 {
-			pipelineId, err := a.Deploy(token.JwtToken(), device, config.AnalyticsFlowId, device, config.ProcessServiceId)
-			if err != nil {
-				log.Println("ERROR:", err)
-				debug.PrintStack()
-				return result, err
-			}
-			result = append(result, Analytic{Id: pipelineId})
+	a := analytics.New(config, stat)
+	for _, device := range devices {
+		pipelineId, err := a.Deploy(token.JwtToken(), device.Id, config.AnalyticsFlowId, device.Id, device.Group.ProcessServiceId)
+		if err != nil {
+			log.Println("ERROR:", err)
+			debug.PrintStack()
+			return result, err
 		}
+		result = append(result, Analytic{Id: pipelineId, LocalId: device.LocalId})
 	}
 	return
 }
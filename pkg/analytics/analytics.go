@@ -18,12 +18,14 @@ package analytics
 
 import (
 	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
 )
 
 type Analytics struct {
 	config configuration.Config
+	stat   statistics.Interface
 }
 
-func New(config configuration.Config) *Analytics {
-	return &Analytics{config: config}
+func New(config configuration.Config, stat statistics.Interface) *Analytics {
+	return &Analytics{config: config, stat: stat}
 }
@@ -17,10 +17,14 @@
 package analytics
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"github.com/SENERGY-Platform/platform-connector-lib/security"
 	"github.com/SENERGY-Platform/senergy-load-test/pkg/analytics/model"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
 	"log"
+	"net/http"
 	"net/url"
 	"runtime/debug"
 	"strings"
@@ -101,10 +105,12 @@ func (this *Analytics) Remove(token security.JwtToken, pipelineId string) (err e
 func (this *Analytics) remove(token security.JwtToken, pipelineId string) error {
 	resp, err := token.Delete(this.config.PublicFlowEngineUrl + "/pipeline/" + url.PathEscape(pipelineId))
 	if err != nil {
+		this.stat.Error(statistics.HttpErrorCategory(resp), "flow-engine/pipeline")
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
+		this.stat.Error(statistics.HttpErrorCategory(resp), "flow-engine/pipeline")
 		debug.PrintStack()
 		return errors.New("unexpected statuscode")
 	}
@@ -112,6 +118,26 @@ func (this *Analytics) remove(token security.JwtToken, pipelineId string) error
 }
 
 func (this *Analytics) sendDeployRequest(token security.JwtToken, request model.PipelineRequest) (result model.Pipeline, err error) {
-	err = token.PostJSON(this.config.PublicFlowEngineUrl+"/pipeline", request, &result)
+	b := new(bytes.Buffer)
+	err = json.NewEncoder(b).Encode(request)
+	if err != nil {
+		return result, err
+	}
+	resp, err := token.Post(this.config.PublicFlowEngineUrl+"/pipeline", "application/json", b)
+	err = this.decode(resp, err, &result, "flow-engine/pipeline")
 	return
 }
+
+// decode reads the json response into result and counts failed requests by endpoint
+func (this *Analytics) decode(resp *http.Response, err error, result interface{}, endpoint string) error {
+	if err != nil {
+		this.stat.Error(statistics.HttpErrorCategory(resp), endpoint)
+		return err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(result)
+	if err != nil {
+		this.stat.Error(statistics.Unmarshal, endpoint)
+	}
+	return err
+}
@@ -23,6 +23,7 @@ import (
 )
 
 func (this *Analytics) GetFlowInputs(token security.JwtToken, id string) (result []model.FlowModelCell, err error) {
-	err = token.GetJSON(this.config.PublicFlowParserUrl+"/flow/getinputs/"+url.PathEscape(id), &result)
+	resp, err := token.Get(this.config.PublicFlowParserUrl + "/flow/getinputs/" + url.PathEscape(id))
+	err = this.decode(resp, err, &result, "flow-parser/inputs")
 	return
 }
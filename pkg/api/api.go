@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/metrics"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const ShutdownTimeout = 5 * time.Second
+
+// Start serves the http endpoints (/metrics and the runtime control endpoints, see controlRoutes) on config.HttpPort until ctx is done;
+// the control endpoints require config.ApiToken (see Authorize)
+func Start(ctx context.Context, wg *sync.WaitGroup, config configuration.Config) error {
+	if config.HttpPort == "" || config.HttpPort == "-" {
+		return nil
+	}
+	control := http.NewServeMux()
+	controlRoutes(control)
+	router := http.NewServeMux()
+	router.HandleFunc("/metrics", metrics.Handler)
+	router.Handle("/", Authorize(config.ApiToken, control))
+	if config.ApiToken == "" {
+		log.Println("INFO: control api only accepts requests from localhost; set api_token to allow remote requests")
+	}
+	return Serve(ctx, wg, config.HttpPort, router)
+}
+
+// Serve serves handler on port until ctx is done
+func Serve(ctx context.Context, wg *sync.WaitGroup, port string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: handler}
+	if wg != nil {
+		wg.Add(1)
+	}
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			log.Println("ERROR: api server", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		if wg != nil {
+			wg.Done()
+		}
+	}()
+	log.Println("INFO: serve api on port", port)
+	return nil
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+)
+
+// Authorize passes requests with the header "Authorization: Bearer <token>" to handler;
+// without token only requests from the loopback interface are passed
+func Authorize(token string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if token == "" {
+			if !isLoopback(request.RemoteAddr) {
+				http.Error(writer, "api only accepts requests from localhost without api_token", http.StatusForbidden)
+				return
+			}
+			handler.ServeHTTP(writer, request)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(request.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(writer, "missing or invalid api token", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(writer, request)
+	})
+}
+
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/control"
+	"log"
+	"net/http"
+	"time"
+)
+
+// controlRoutes registers the runtime control endpoints; every endpoint acts on all instances or, with ?hub=<name>, on one instance
+func controlRoutes(router *http.ServeMux) {
+	router.HandleFunc("/status", method(http.MethodGet, getStatus))
+	router.HandleFunc("/emitters/pause", method(http.MethodPost, each(func(instance *control.Instance, request *http.Request) (interface{}, error) {
+		instance.Pause()
+		return instance.Status(), nil
+	})))
+	router.HandleFunc("/emitters/resume", method(http.MethodPost, each(func(instance *control.Instance, request *http.Request) (interface{}, error) {
+		instance.Resume()
+		return instance.Status(), nil
+	})))
+	router.HandleFunc("/emitters/rate", method(http.MethodPut, setRate))
+	router.HandleFunc("/emitters/burst", method(http.MethodPost, burst))
+	router.HandleFunc("/devices", devices)
+	router.HandleFunc("/statistics/flush", method(http.MethodPost, each(func(instance *control.Instance, request *http.Request) (interface{}, error) {
+		if instance.Metrics != nil && instance.Metrics.Stat != nil {
+			instance.Metrics.Stat.Flush()
+		}
+		return instance.Status(), nil
+	})))
+	router.HandleFunc("/shutdown", method(http.MethodPost, func(writer http.ResponseWriter, request *http.Request) {
+		control.Shutdown()
+		writer.WriteHeader(http.StatusAccepted)
+	}))
+}
+
+type RateRequest struct {
+	Factor     float64 `json:"factor,omitempty"`      //multiplier of the configured frequency
+	Interval   string  `json:"interval,omitempty"`    //new emitter_interval; service intervals are scaled accordingly
+	TargetRate float64 `json:"target_rate,omitempty"` //new target rate in events per second
+}
+
+type BurstRequest struct {
+	Events int `json:"events"` //per instance; limited to the capacity of the emitter queue
+}
+
+type BurstResult struct {
+	Hub    string `json:"hub"`
+	Queued int    `json:"queued"`
+}
+
+type DevicesRequest struct {
+	Count  int  `json:"count"`  //per instance
+	Delete bool `json:"delete"` //on removal: delete the devices from the platform
+}
+
+type DevicesResult struct {
+	Hub     string `json:"hub"`
+	Devices int    `json:"devices"`
+}
+
+func getStatus(writer http.ResponseWriter, request *http.Request) {
+	result := []control.Status{}
+	for _, instance := range selected(request) {
+		result = append(result, instance.Status())
+	}
+	respond(writer, result, nil)
+}
+
+func setRate(writer http.ResponseWriter, request *http.Request) {
+	body := RateRequest{}
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	interval := time.Duration(0)
+	if body.Interval != "" {
+		interval, err = time.ParseDuration(body.Interval)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	each(func(instance *control.Instance, request *http.Request) (interface{}, error) {
+		switch {
+		case body.Interval != "":
+			err = instance.SetInterval(interval)
+		case body.TargetRate != 0:
+			err = instance.SetTargetRate(body.TargetRate)
+		default:
+			err = instance.SetFactor(body.Factor)
+		}
+		if err != nil {
+			return nil, err
+		}
+		log.Println("INFO: api changed emitter factor of", instance.Name, "to", instance.Factor())
+		return instance.Status(), nil
+	})(writer, request)
+}
+
+func burst(writer http.ResponseWriter, request *http.Request) {
+	body := BurstRequest{}
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Events <= 0 {
+		http.Error(writer, "events must be positive", http.StatusBadRequest)
+		return
+	}
+	each(func(instance *control.Instance, request *http.Request) (interface{}, error) {
+		if instance.Burst == nil {
+			return BurstResult{Hub: instance.Name}, nil
+		}
+		log.Println("INFO: api burst of", body.Events, "events for", instance.Name)
+		return BurstResult{Hub: instance.Name, Queued: instance.Burst(body.Events)}, nil
+	})(writer, request)
+}
+
+func devices(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost && request.Method != http.MethodDelete {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body := DevicesRequest{}
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Count <= 0 {
+		http.Error(writer, "count must be positive", http.StatusBadRequest)
+		return
+	}
+	each(func(instance *control.Instance, request *http.Request) (result interface{}, err error) {
+		devices := 0
+		if request.Method == http.MethodPost {
+			log.Println("INFO: api adds", body.Count, "devices to", instance.Name)
+			devices, err = instance.AddDevices(body.Count)
+		} else {
+			log.Println("INFO: api removes", body.Count, "devices from", instance.Name)
+			devices, err = instance.RemoveDevices(body.Count, body.Delete)
+		}
+		return DevicesResult{Hub: instance.Name, Devices: devices}, err
+	})(writer, request)
+}
+
+// PartialError is the response of an endpoint which failed for an instance after it changed others
+type PartialError struct {
+	Error   string        `json:"error"`
+	Hub     string        `json:"hub"`     //instance which failed; the following instances are unchanged
+	Applied []interface{} `json:"applied"` //results of the instances which were changed before the error
+}
+
+// each applies f to the selected instances and responds with the list of results; the first error aborts
+func each(f func(instance *control.Instance, request *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		instances := selected(request)
+		if len(instances) == 0 {
+			http.Error(writer, "no matching instance", http.StatusNotFound)
+			return
+		}
+		result := []interface{}{}
+		for _, instance := range instances {
+			temp, err := f(instance, request)
+			if err != nil && len(result) > 0 {
+				log.Println("WARNING: api request failed for", instance.Name, "after", len(result), "changed instances", err)
+				respondJson(writer, errorStatus(err), PartialError{Error: err.Error(), Hub: instance.Name, Applied: result})
+				return
+			}
+			if err != nil {
+				respond(writer, result, err)
+				return
+			}
+			result = append(result, temp)
+		}
+		respond(writer, result, nil)
+	}
+}
+
+func selected(request *http.Request) (result []*control.Instance) {
+	hub := request.URL.Query().Get("hub")
+	for _, instance := range control.Instances() {
+		if hub == "" || instance.Name == hub {
+			result = append(result, instance)
+		}
+	}
+	return result
+}
+
+func method(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != method {
+			http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler(writer, request)
+	}
+}
+
+func respond(writer http.ResponseWriter, result interface{}, err error) {
+	if err != nil {
+		http.Error(writer, err.Error(), errorStatus(err))
+		return
+	}
+	respondJson(writer, http.StatusOK, result)
+}
+
+func respondJson(writer http.ResponseWriter, status int, result interface{}) {
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.WriteHeader(status)
+	err := json.NewEncoder(writer).Encode(result)
+	if err != nil {
+		log.Println("WARNING: unable to encode api response", err)
+	}
+}
+
+func errorStatus(err error) int {
+	if errors.Is(err, control.ErrScalingUnsupported) {
+		return http.StatusNotImplemented
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/control"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	ok := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {})
+	tests := []struct {
+		name       string
+		token      string
+		remoteAddr string
+		header     string
+		expect     int
+	}{
+		{name: "localhost without token", remoteAddr: "127.0.0.1:4000", expect: http.StatusOK},
+		{name: "ipv6 localhost without token", remoteAddr: "[::1]:4000", expect: http.StatusOK},
+		{name: "remote without token", remoteAddr: "192.0.2.1:4000", expect: http.StatusForbidden},
+		{name: "remote with token", token: "secret", remoteAddr: "192.0.2.1:4000", header: "Bearer secret", expect: http.StatusOK},
+		{name: "remote with wrong token", token: "secret", remoteAddr: "192.0.2.1:4000", header: "Bearer other", expect: http.StatusUnauthorized},
+		{name: "localhost without header", token: "secret", remoteAddr: "127.0.0.1:4000", expect: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/status", nil)
+			request.RemoteAddr = test.remoteAddr
+			if test.header != "" {
+				request.Header.Set("Authorization", test.header)
+			}
+			recorder := httptest.NewRecorder()
+			Authorize(test.token, ok).ServeHTTP(recorder, request)
+			if recorder.Code != test.expect {
+				t.Errorf("expect %v, got %v", test.expect, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestPartialError(t *testing.T) {
+	control.Register(&control.Instance{Name: "test_a", ScaleUp: func(count int) (int, error) { return 10 + count, nil }})
+	control.Register(&control.Instance{Name: "test_b"})
+	control.Register(&control.Instance{Name: "test_c", ScaleUp: func(count int) (int, error) { return count, nil }})
+	defer control.Unregister("test_a")
+	defer control.Unregister("test_b")
+	defer control.Unregister("test_c")
+
+	router := http.NewServeMux()
+	controlRoutes(router)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/devices", strings.NewReader(`{"count": 2}`)))
+	if recorder.Code != http.StatusNotImplemented {
+		t.Fatal("expect status 501, got", recorder.Code, recorder.Body.String())
+	}
+	result := PartialError{}
+	err := json.NewDecoder(recorder.Body).Decode(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Hub != "test_b" || len(result.Applied) != 1 || result.Error == "" {
+		t.Errorf("unexpected response %#v", result)
+	}
+	applied, _ := result.Applied[0].(map[string]interface{})
+	if applied["hub"] != "test_a" || applied["devices"] != 12.0 {
+		t.Errorf("expect the result of test_a, got %#v", result.Applied[0])
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/devices?hub=test_b", strings.NewReader(`{"count": 2}`)))
+	if recorder.Code != http.StatusNotImplemented || strings.HasPrefix(recorder.Body.String(), "{") {
+		t.Error("expect a plain error if no instance was changed, got", recorder.Code, recorder.Body.String())
+	}
+}
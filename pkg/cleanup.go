@@ -10,6 +10,8 @@ import (
 	"time"
 )
 
+const DefaultCleanupBatchSize = 1000
+
 func Cleanup(config configuration.Config) error {
 	token, err := security.GetOpenidPasswordToken(config.AuthUrl, config.AuthClientId, config.AuthClientSecret, config.UserName, config.Password)
 	if err != nil {
@@ -20,6 +22,9 @@ func Cleanup(config configuration.Config) error {
 
 	//devices
 	limit := int(config.DeviceCount)
+	if limit <= 0 {
+		limit = DefaultCleanupBatchSize
+	}
 	temp := []SearchElement{}
 	devices := []SearchElement{}
 	var after *ListAfter
@@ -59,6 +64,7 @@ func Cleanup(config configuration.Config) error {
 		}
 		resp.Body.Close()
 	}
+	forgetFleets(config)
 
 	//processes
 	offset := 0
@@ -89,6 +95,26 @@ func Cleanup(config configuration.Config) error {
 	return nil
 }
 
+// forgetFleets removes the scaled fleets of the deleted devices from the client infos, so that the next start uses the device groups
+func forgetFleets(config configuration.Config) {
+	locations := []string{config.ClientInfoLocation}
+	for i := int64(1); i <= config.Instances; i++ {
+		locations = append(locations, iterateFileLocation(config.ClientInfoLocation, i))
+	}
+	for _, location := range locations {
+		c := config
+		c.ClientInfoLocation = location
+		info, err := LoadClientInfo(c)
+		if err != nil || (len(info.Fleet) == 0 && len(info.Detached) == 0) {
+			continue
+		}
+		err = StoreClientInfo(c, ClientInfo{Id: info.Id})
+		if err != nil {
+			log.Println("WARNING: unable to reset client info at", location, err)
+		}
+	}
+}
+
 type SearchElement struct {
 	Id   string `json:"id"`
 	Name string `json:"name"`
@@ -3,17 +3,21 @@ package pkg
 import (
 	"context"
 	"encoding/json"
-	platform_connector_lib "github.com/SENERGY-Platform/platform-connector-lib"
 	"github.com/SENERGY-Platform/platform-connector-lib/iot"
 	"github.com/SENERGY-Platform/platform-connector-lib/security"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/api"
 	"github.com/SENERGY-Platform/senergy-load-test/pkg/client"
 	"github.com/SENERGY-Platform/senergy-load-test/pkg/client/factory"
 	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/control"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/loadprofile"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/metrics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/sinks"
 	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/tracking"
 	senergyclient "github.com/SENERGY-Platform/senergy-platform-connector/test/client"
+	uuid "github.com/satori/go.uuid"
 	"log"
-	"math/rand"
-	"net/url"
 	"os"
 	"path"
 	"runtime/debug"
@@ -23,15 +27,62 @@ import (
 	"time"
 )
 
+// ClientInfo is stored at config.ClientInfoLocation and reused on restarts
 type ClientInfo struct {
-	Id string `json:"id"`
+	Id       string                               `json:"id"`
+	Fleet    []FleetEntry                         `json:"fleet,omitempty"`    //current devices if the fleet was scaled; empty uses the device groups
+	Detached []senergyclient.DeviceRepresentation `json:"detached,omitempty"` //devices removed by scaling but not deleted
+}
+
+func LoadClientInfo(config configuration.Config) (info ClientInfo, err error) {
+	file, err := os.Open(config.ClientInfoLocation)
+	if err != nil {
+		return info, err
+	}
+	defer file.Close()
+	err = json.NewDecoder(file).Decode(&info)
+	return
+}
+
+func StoreClientInfo(config configuration.Config, info ClientInfo) (err error) {
+	file, err := os.OpenFile(config.ClientInfoLocation, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	err = json.NewEncoder(file).Encode(info)
+	if err != nil {
+		return err
+	}
+	return file.Sync()
 }
 
 const DeviceUriKey = "deviceUri"
 const ServiceUriKey = "serviceUri"
 const ProcessIdKey = "processId"
 
+const MinStatisticsInterval = time.Second
+
+// budgets limit the emitted events and process starts of all instances of the run (max_events, max_process_starts)
+type budgets struct {
+	events        *control.Budget
+	processStarts *control.Budget
+}
+
+// WithRunDefaults sets a random seed and run id if they are not configured
+func WithRunDefaults(config configuration.Config) configuration.Config {
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	if config.RunId == "" {
+		config.RunId = strings.Split(uuid.NewV4().String(), "-")[0]
+	}
+	return config
+}
+
 func Start(basectx context.Context, wg *sync.WaitGroup, config configuration.Config) (err error) {
+	config = WithRunDefaults(config)
+	log.Println("INFO: use seed", config.Seed)
 	ctx, cancel := context.WithCancel(basectx)
 	defer func() {
 		if err != nil {
@@ -40,24 +91,77 @@ func Start(basectx context.Context, wg *sync.WaitGroup, config configuration.Con
 			wg.Wait()
 		}
 	}()
-	if config.Instances > 1 {
-		for i := int64(1); i <= config.Instances; i++ {
-			c := config
-			c.HubPrefix = config.HubPrefix + "_" + strconv.FormatInt(i, 10)
-			c.ClientInfoLocation = iterateFileLocation(config.ClientInfoLocation, i)
-			c.ProcessInfoLocation = iterateFileLocation(config.ProcessInfoLocation, i)
-			c.AnalyticInfoLocation = iterateFileLocation(config.AnalyticInfoLocation, i)
-			err = startRetry(ctx, wg, c, 5)
-			if err != nil {
-				return
-			}
+	log.Println("INFO: use run id", config.RunId)
+	tracker, err := tracking.New(config.RunId, config.LatencyConsumer)
+	if err != nil {
+		log.Println("ERROR: invalid latency_consumer", err)
+		return err
+	}
+	if config.LatencyConsumer.Type != "" {
+		consumer, err := tracking.NewConsumer(config.LatencyConsumer, config.RunId)
+		if err != nil {
+			log.Println("ERROR: invalid latency_consumer", err)
+			return err
 		}
-		return nil
+		//the consumer receives the events published on shutdown until the instances waited for their receipts (see shutdown)
+		trackerCtx, stopTracker := context.WithCancel(context.Background())
+		stopAfterShutdown(ctx, wg, stopTracker)
+		err = tracker.Start(trackerCtx, consumer)
+		if err != nil {
+			log.Println("ERROR: unable to start latency_consumer", err)
+			return err
+		}
+	}
+	statisticsSinks, err := sinks.Open(config.StatisticsSinks)
+	if err != nil {
+		log.Println("ERROR: invalid statistics_sinks", err)
+		return err
+	}
+	if len(config.StatisticsSinks) > 0 && (config.StatisticsInterval == "" || config.StatisticsInterval == "-") {
+		log.Println("WARNING: statistics_interval is disabled; statistics_sinks only receive the whole run as one interval at the end")
+	}
+	closeSinks(ctx, wg, statisticsSinks)
+	err = api.Start(ctx, wg, config)
+	if err != nil {
+		log.Println("ERROR: unable to start api", err)
+		return err
+	}
+	limits := budgets{
+		events:        control.NewBudget("max_events", config.MaxEvents),
+		processStarts: control.NewBudget("max_process_starts", config.MaxProcessStarts),
+	}
+	if config.Instances > 1 {
+		return startInstances(ctx, wg, config, tracker, statisticsSinks, limits)
 	} else {
-		return start(ctx, wg, config)
+		return startRetry(ctx, wg, config, tracker, statisticsSinks, limits, nil, 1)
 	}
 }
 
+// closeSinks closes the statistics sinks after the final interval of all instances is written
+func closeSinks(ctx context.Context, wg *sync.WaitGroup, statisticsSinks *sinks.Sinks) {
+	stopAfterShutdown(ctx, wg, statisticsSinks.Close)
+}
+
+// stopAfterShutdown calls stop after ctx is done and the final statistics of all instances are recorded,
+// which the shutdown does after draining the emitters and waiting for the downstream receipts
+func stopAfterShutdown(ctx context.Context, wg *sync.WaitGroup, stop func()) {
+	if wg != nil {
+		wg.Add(1)
+	}
+	go func() {
+		<-ctx.Done()
+		for _, instance := range metrics.Instances() {
+			if instance.Stat != nil {
+				<-instance.Stat.Done()
+			}
+		}
+		stop()
+		if wg != nil {
+			wg.Done()
+		}
+	}()
+}
+
 func iterateFileLocation(location string, i int64) string {
 	dir, file := path.Split(location)
 	parts := strings.Split(file, ".")
@@ -66,50 +170,153 @@ func iterateFileLocation(location string, i int64) string {
 	return path.Join(dir, file)
 }
 
-func startRetry(basectx context.Context, wg *sync.WaitGroup, config configuration.Config, retries int) (err error) {
+// startRetry starts an instance with up to retries attempts, each waiting for the connect limiter right before its mqtt connect,
+// and records the startup duration in its metrics instance (see report.Instance); a failed or aborted startup is recorded with its error
+func startRetry(basectx context.Context, wg *sync.WaitGroup, config configuration.Config, tracker *tracking.Tracker, statisticsSinks *sinks.Sinks, limits budgets, connects *connectLimiter, retries int) (err error) {
+	begin := time.Now()
+	startup := metrics.Startup{}
+	defer func() {
+		startup.Duration = time.Since(begin)
+		instance := metrics.Get(config.HubPrefix)
+		if err != nil {
+			startup.Error = err.Error()
+			if instance == nil {
+				//the instance failed before its statistics were registered
+				instance = metrics.Register(&metrics.Instance{Name: config.HubPrefix})
+			}
+		}
+		if instance != nil {
+			instance.SetStartup(startup)
+		}
+	}()
 	for i := 0; i < retries; i++ {
+		if i > 0 {
+			log.Println("error on start of", config.HubPrefix+"; retry in 10s;", err)
+			select {
+			case <-basectx.Done():
+				return err
+			case <-time.After(10 * time.Second):
+			}
+		}
+		startup.Attempts++
 		ctx, cancel := context.WithCancel(basectx)
-		err = start(ctx, wg, config)
+		connectWait := func() error {
+			waited, err := connects.Wait(ctx)
+			startup.ConnectWait = startup.ConnectWait + waited
+			return err
+		}
+		err = start(ctx, wg, config, tracker, statisticsSinks, limits, connectWait)
 		if err != nil {
-			log.Println("error on start; retry in 10s;", err)
 			cancel()
-			time.Sleep(10 * time.Second)
-		} else {
-			return nil
+			if basectx.Err() != nil {
+				return err
+			}
+			continue
 		}
+		go func() {
+			<-basectx.Done()
+			cancel()
+		}()
+		log.Println("INFO: started", config.HubPrefix, "in", time.Since(begin).String(), "with", startup.Attempts, "attempts and", startup.ConnectWait.String(), "connect_rate wait")
+		return nil
 	}
 	return err
 }
 
-func start(ctx context.Context, wg *sync.WaitGroup, config configuration.Config) (err error) {
+// start provisions and starts an instance; connectWait is called right before the mqtt connect (see client.Factory)
+func start(ctx context.Context, wg *sync.WaitGroup, config configuration.Config, tracker *tracking.Tracker, statisticsSinks *sinks.Sinks, limits budgets, connectWait func() error) (err error) {
 	connector, err := factory.GetConnectorType(config.ConnectorType)
 	if err != nil {
 		return err
 	}
-	file, err := os.OpenFile(config.ClientInfoLocation, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	profile, err := loadprofile.New(config.LoadProfile)
 	if err != nil {
+		log.Println("ERROR: invalid load_profile", err)
 		return err
 	}
-	defer file.Close()
-	clientInfo := ClientInfo{}
-	err = json.NewDecoder(file).Decode(&clientInfo)
+	drainTimeout := DefaultDrainTimeout
+	if config.DrainTimeout != "" && config.DrainTimeout != "-" {
+		drainTimeout, err = time.ParseDuration(config.DrainTimeout)
+		if err != nil {
+			log.Println("ERROR: unable to parse drain_timeout", err)
+			return err
+		}
+	}
+	clientInfo, err := LoadClientInfo(config)
 	if err != nil {
 		log.Println("WARNING: no valid client info stored at", config.ClientInfoLocation, err)
+		clientInfo = ClientInfo{}
 		err = nil
 	}
-	devices := GetDevices(config)
+	fleet, err := GetFleet(config)
+	if err != nil {
+		log.Println("ERROR: invalid device groups", err)
+		return err
+	}
+	if len(clientInfo.Fleet) > 0 {
+		restored, err := RestoreFleet(config, clientInfo.Fleet)
+		if err != nil {
+			log.Println("WARNING: unable to restore scaled fleet from", config.ClientInfoLocation+"; use device groups", err)
+			clientInfo.Fleet = nil
+		} else {
+			log.Println("INFO: restore scaled fleet of", len(restored), "devices from", config.ClientInfoLocation)
+			fleet = restored
+		}
+	}
+	devices := GetDevices(fleet)
 	log.Println("INFO: use", len(devices), "devices; config config.DeviceCount=", config.DeviceCount)
-	c, err := factory.Get(connector)(config.AuthClientId, config.AuthClientSecret, config.MqttUrl, config.DeviceManagerUrl, config.DeviceRepoUrl, config.AuthUrl, config.UserName, config.Password, clientInfo.Id, config.HubPrefix, devices)
+	c, err := factory.Get(connector)(config.AuthClientId, config.AuthClientSecret, config.MqttUrl, config.DeviceManagerUrl, config.DeviceRepoUrl, config.AuthUrl, config.UserName, config.Password, clientInfo.Id, config.HubPrefix, devices, connectWait)
 	if err != nil {
 		return err
 	}
+	statisticsInterval := time.Duration(0)
+	if config.StatisticsInterval != "" && config.StatisticsInterval != "-" {
+		statisticsInterval, err = time.ParseDuration(config.StatisticsInterval)
+		if err != nil {
+			log.Println("WARNING: no valid statistics interval")
+			statisticsInterval = 0
+			err = nil
+		}
+		if statisticsInterval > 0 && statisticsInterval < MinStatisticsInterval {
+			log.Println("WARNING: statistics_interval below", MinStatisticsInterval.String(), "; use", MinStatisticsInterval.String())
+			statisticsInterval = MinStatisticsInterval
+		}
+	}
+	//statistics, processes and analytics are stopped by the shutdown after the emitters are drained
+	statisticsCtx, stopStatistics := context.WithCancel(context.Background())
+	resourcesCtx, stopResources := context.WithCancel(context.Background())
+	resources := &sync.WaitGroup{}
+	stat := statistics.New(statisticsCtx, statisticsInterval, statisticsSinks.For(config.HubPrefix))
+	if config.DeviceStatistics {
+		deviceUris := make([]string, len(devices))
+		for i, device := range devices {
+			deviceUris[i] = device.Uri
+		}
+		stat.TrackDevices(deviceUris, int(config.DeviceStatisticsTopN))
+	}
+	instance := metrics.Register(&metrics.Instance{Name: config.HubPrefix, Stat: stat, Connected: c.IsConnected})
+	instance.SetDevices(len(devices))
+	sim := newSimulation(ctx, config, fleet, c, stat, tracker, limits.events)
+	scaling := &scaler{config: config, c: c, sim: sim, stat: stat, instance: instance, detached: clientInfo.Detached}
+
 	if wg != nil {
 		wg.Add(1)
 	}
 	go func() {
 		<-ctx.Done()
-		cleanup(config, devices, c)
-		c.Stop()
+		(&shutdown{
+			config:         config,
+			timeout:        drainTimeout,
+			c:              c,
+			instance:       instance,
+			stat:           stat,
+			tracker:        tracker,
+			sim:            sim,
+			scaling:        scaling,
+			stopStatistics: stopStatistics,
+			stopResources:  stopResources,
+			resources:      resources,
+		}).run()
 		if wg != nil {
 			wg.Done()
 		}
@@ -118,190 +325,123 @@ func start(ctx context.Context, wg *sync.WaitGroup, config configuration.Config)
 	if c.HubId() != clientInfo.Id {
 		log.Println("store new hub id in file", c.HubId(), config.ClientInfoLocation)
 		clientInfo.Id = c.HubId()
-		err = json.NewEncoder(file).Encode(clientInfo)
+		err = StoreClientInfo(config, clientInfo)
 		if err != nil {
-			log.Println("WARNING: unable to encode client info at", config.ClientInfoLocation, err)
-			err = nil
-		}
-		err = file.Sync()
-		if err != nil {
-			log.Println("WARNING: unable to flush client info to", config.ClientInfoLocation, err)
+			log.Println("WARNING: unable to store client info at", config.ClientInfoLocation, err)
 			err = nil
 		}
 	}
 
-	var stat statistics.Interface
-	if config.StatisticsInterval != "" && config.StatisticsInterval != "-" {
-		statisticsInterval, err := time.ParseDuration(config.StatisticsInterval)
-		if err != nil {
-			log.Println("WARNING: no valid statistics interval")
-			stat = statistics.Void{}
-		} else {
-			stat = statistics.New(ctx, statisticsInterval)
-		}
+	started := time.Now()
+	rate := func() float64 {
+		return profile.Factor(time.Since(started))
 	}
-
-	err = simServices(ctx, config, err, devices, c, stat)
-	if err != nil {
-		return err
+	controller := &control.Instance{Name: config.HubPrefix, Metrics: instance, HubId: c.HubId, BaseRate: config.TargetRate}
+	if config.TargetRate <= 0 {
+		controller.BaseInterval, _ = time.ParseDuration(config.EmitterInterval)
 	}
-	if config.ProcessModelId != "" {
-		processes, err := EnsureProcesses(ctx, wg, config, c.HubId())
-		if err != nil {
-			log.Println("WARNING: unable to create processes", err)
-			return nil
-		}
-		if config.ProcessInterval != "" && config.ProcessInterval != "-" {
-			err = triggerProcesses(ctx, config, processes)
-			if err != nil {
-				return err
-			}
-		}
+	if config.StartPaused {
+		controller.Pause()
 	}
-	if config.AnalyticsFlowId != "" {
-		_, err = EnsureAnalytics(ctx, wg, config, c.HubId())
+	emitterRate := func() float64 {
+		return controller.Rate(rate())
+	}
+
+	commandTimeout := tracking.DefaultTimeout
+	if config.CommandTimeout != "" {
+		commandTimeout, err = time.ParseDuration(config.CommandTimeout)
 		if err != nil {
-			log.Println("WARNING: unable to create analytics", err)
-			return nil
+			log.Println("ERROR: unable to parse command_timeout", err)
+			return err
 		}
 	}
-	return nil
-}
+	commands := tracking.NewCommands(commandTimeout)
 
-func simServices(ctx context.Context, config configuration.Config, err error, devices []senergyclient.DeviceRepresentation, c client.Client, stat statistics.Interface) error {
-	messages := make(chan Message, 10000)
-	interval, err := time.ParseDuration(config.EmitterInterval)
+	err = sim.Start(commands, emitterRate, func() bool {
+		return controller.Enabled(control.Commands)
+	})
 	if err != nil {
-		log.Println("ERROR: unable to parse emitter_interval", config.EmitterInterval, err)
 		return err
 	}
-	for _, d := range devices {
-		err = c.ListenCommandWithQos(d.Uri, config.CommandServiceUri, byte(config.Qos), func(msg platform_connector_lib.CommandRequestMsg) (resp platform_connector_lib.CommandResponseMsg, err error) {
-			if config.Debug {
-				log.Println("DEBUG: receive command")
-			}
-			stat.CommandsHandled()
-			payload := createPayload(config)
-			err = json.Unmarshal([]byte(payload), &resp)
-			return
-		})
-		if err != nil {
-			log.Println("ERROR: unable to listen to device command for", d.Uri, config.CommandServiceUri, err)
-			return err
-		}
-		//create emitter of event messages
-		Emitter(ctx, messages, map[string]string{
-			DeviceUriKey:  d.Uri,
-			ServiceUriKey: config.EventServiceUri,
-		}, interval, func() string {
-			stat.EventEmitted()
-			return createPayload(config)
-		})
-	}
+	controller.Burst = sim.Burst
+	controller.ScaleUp = scaling.Up
+	controller.ScaleDown = scaling.Down
+	control.Register(controller)
 
-	//send event messages created by Emitter()
-	go func() {
-		for m := range messages {
-			event := map[platform_connector_lib.ProtocolSegmentName]string{}
-			err := json.Unmarshal([]byte(m.Message), &event)
+	processRate := func() float64 {
+		return controller.ProcessRate(rate())
+	}
+	deploy := func(subsystem string) error {
+		switch subsystem {
+		case control.Processes:
+			if config.ProcessModelId == "" {
+				return nil
+			}
+			processes, err := scaling.setProcesses(func(fleet []FleetDevice) (*ProcessList, error) {
+				return EnsureProcesses(resourcesCtx, resources, config, fleet, instance)
+			})
 			if err != nil {
-				log.Println("ERROR: unable to unmarshal emitted event", m.Message, err)
-				continue
+				log.Println("WARNING: unable to create processes", err)
+				return nil
+			}
+			instance.SetProcesses(len(processes.Get()))
+			if config.ProcessInterval != "" && config.ProcessInterval != "-" {
+				return triggerProcesses(ctx, config, processes, processRate, limits.processStarts, commands, stat)
 			}
-			start := time.Now()
-			err = c.SendEventWithQos(m.Info[DeviceUriKey], m.Info[ServiceUriKey], event, byte(config.Qos))
+		case control.Analytics:
+			if config.AnalyticsFlowId == "" {
+				return nil
+			}
+			pipelines, err := scaling.setAnalytics(func(fleet []FleetDevice) (*AnalyticsList, error) {
+				return EnsureAnalytics(resourcesCtx, resources, config, fleet, instance)
+			})
 			if err != nil {
-				log.Println("ERROR: unable to send emitted event", m.Message, err)
-				continue
+				log.Println("WARNING: unable to create analytics", err)
+				return nil
 			}
-			stat.EventProduce(time.Since(start))
+			instance.SetPipelines(len(pipelines.Get()))
 		}
-	}()
-	return nil
+		return nil
+	}
+	if config.ScenarioLocation != "" {
+		//the scenario phases deploy processes and analytics when they enable them
+		controller.Deploy = deploy
+		return nil
+	}
+	err = deploy(control.Processes)
+	if err != nil {
+		return err
+	}
+	return deploy(control.Analytics)
 }
 
-func cleanup(config configuration.Config, devices []senergyclient.DeviceRepresentation, c client.Client) {
+// cleanup deletes devices and hub with config.DeleteOnShutdown; deleted is false if they are kept or the deletion could not start
+func cleanup(config configuration.Config, devices []senergyclient.DeviceRepresentation, c client.Client, instance *metrics.Instance) (deleted bool) {
 	if config.DeleteOnShutdown {
 		token, err := security.GetOpenidPasswordToken(config.AuthUrl, config.AuthClientId, config.AuthClientSecret, config.UserName, config.Password)
 		if err != nil {
 			log.Println("ERROR:", err)
 			debug.PrintStack()
-			return
+			return false
+		}
+		instance.AddDeleted("devices", DeleteDevices(config, devices, token.JwtToken()))
+		if DeleteHub(config, c.HubId(), token.JwtToken()) {
+			instance.AddDeleted("hubs", 1)
 		}
-		DeleteDevices(config, devices, token.JwtToken())
-		DeleteHub(config, c.HubId(), token.JwtToken())
+		return true
 	}
-	return
+	return false
 }
 
-func DeleteHub(config configuration.Config, id string, token security.JwtToken) {
+func DeleteHub(config configuration.Config, id string, token security.JwtToken) (deleted bool) {
 	if id == "" {
-		return
+		return false
 	}
 	err := iot.New(config.DeviceManagerUrl, "", "", "").DeleteHub(id, token)
 	if err != nil {
 		log.Println("ERROR:", err)
 		debug.PrintStack()
-		return
-	}
-}
-
-func createPayload(config configuration.Config) (result string) {
-	result = config.ServiceMessage
-	result = strings.ReplaceAll(result, "__TIME_NOW_UNIX_MS__", strconv.FormatInt(time.Now().Unix(), 10))
-	result = strings.ReplaceAll(result, "__RAND_PERCENT__", strconv.FormatUint(rand.Uint64()%101, 10))
-	return
-}
-
-func triggerProcesses(ctx context.Context, config configuration.Config, processes []Process) (err error) {
-	openIdToken, err := security.GetOpenidPasswordToken(config.AuthUrl, config.AuthClientId, config.AuthClientSecret, config.UserName, config.Password)
-	if err != nil {
-		log.Println("ERROR:", err)
-		debug.PrintStack()
-		return err
-	}
-	token := openIdToken.JwtToken()
-	interval, err := time.ParseDuration(config.ProcessInterval)
-	if err != nil {
-		log.Println("ERROR: unable to parse emitter_interval", config.EmitterInterval, err)
-		return err
-	}
-
-	if config.ProcessStartOnce {
-		for _, process := range processes {
-			go func(p Process) {
-				//wait for random time between now and interval to offset emitter
-				r := rand.New(rand.NewSource(time.Now().UnixNano()))
-				if interval <= 1<<31-1 {
-					time.Sleep(time.Duration(r.Int31n(int32(interval))))
-				} else {
-					time.Sleep(time.Duration(r.Int63n(int64(interval))))
-				}
-				TriggerProcess(config, p.Id, token)
-			}(process)
-		}
-		return nil
-	} else {
-		messages := make(chan Message, len(processes))
-		for _, process := range processes {
-			Emitter(ctx, messages, map[string]string{ProcessIdKey: process.Id}, interval, func() string { return "" })
-		}
-		//send event messages created by Emitter()
-		go func() {
-			for m := range messages {
-				processId := m.Info[ProcessIdKey]
-				TriggerProcess(config, processId, token)
-			}
-		}()
-		return nil
-	}
-}
-
-func TriggerProcess(config configuration.Config, processId string, token security.JwtToken) {
-	resp, err := token.Get(config.ProcessEngineWrapperUrl + "/v2/deployments/" + url.QueryEscape(processId) + "/start")
-	if err != nil {
-		log.Println("ERROR:", err)
-		return
+		return false
 	}
-	resp.Body.Close()
+	return true
 }
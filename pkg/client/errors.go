@@ -0,0 +1,12 @@
+package client
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNotConnected = errors.New("mqtt client not connected")
+var ErrPublishTimeout = errors.New("mqtt publish timeout")
+
+// PublishTimeout limits the wait for the broker acknowledgement of a publish
+var PublishTimeout = 30 * time.Second
@@ -5,11 +5,16 @@ import (
 	"github.com/SENERGY-Platform/senergy-platform-connector/test/client"
 )
 
-type Factory = func(authClientId string, authClientSecret string, mqttUrl string, deviceManagerUrl string, deviceRepoUrl string, authUrl string, userName string, password string, hubId string, hubName string, devices []client.DeviceRepresentation) (Client, error)
+// Factory provisions the devices (and the hub) and connects the mqtt client; connectWait is called right before the mqtt connect and aborts the start on error
+type Factory = func(authClientId string, authClientSecret string, mqttUrl string, deviceManagerUrl string, deviceRepoUrl string, authUrl string, userName string, password string, hubId string, hubName string, devices []client.DeviceRepresentation, connectWait func() error) (Client, error)
 
 type Client interface {
 	Stop()
 	HubId() string
-	ListenCommandWithQos(deviceUri string, serviceUri string, qos byte, f func(msg platform_connector_lib.CommandRequestMsg) (resp platform_connector_lib.CommandResponseMsg, err error)) error
+	IsConnected() bool
+	ListenCommandWithQos(deviceUri string, serviceUri string, qos byte, f func(correlationId string, msg platform_connector_lib.CommandRequestMsg) (resp platform_connector_lib.CommandResponseMsg, err error)) error //f receives an empty correlationId if the connector sends none
+	Unsubscribe(deviceUri string, serviceUri string) error
+	AddDevices(devices []client.DeviceRepresentation) error //provisions the devices and adds them to the hub
+	RemoveDevices(deviceUris []string) error                //removes the devices from the hub without deleting them
 	SendEventWithQos(deviceUri string, serviceUri string, event map[platform_connector_lib.ProtocolSegmentName]string, b byte) error
 }
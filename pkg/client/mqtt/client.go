@@ -1,7 +1,6 @@
 package mqtt
 
 import (
-	"errors"
 	platform_connector_lib "github.com/SENERGY-Platform/platform-connector-lib"
 	"github.com/SENERGY-Platform/platform-connector-lib/security"
 	"github.com/SENERGY-Platform/senergy-load-test/pkg/client"
@@ -13,7 +12,7 @@ import (
 	"time"
 )
 
-func Factory(authClientId string, authClientSecret string, mqttUrl string, deviceManagerUrl string, deviceRepoUrl string, authUrl string, userName string, password string, hubId string, hubName string, devices []senergyclient.DeviceRepresentation) (result client.Client, err error) {
+func Factory(authClientId string, authClientSecret string, mqttUrl string, deviceManagerUrl string, deviceRepoUrl string, authUrl string, userName string, password string, hubId string, hubName string, devices []senergyclient.DeviceRepresentation, connectWait func() error) (result client.Client, err error) {
 	log.Println("mqtt client is used --> no hub will be created --> HubId == \"\"")
 	c := &Client{
 		authUrl:          authUrl,
@@ -44,6 +43,10 @@ func Factory(authClientId string, authClientSecret string, mqttUrl string, devic
 	if newDevices {
 		time.Sleep(10 * time.Second) //wait for device creation
 	}
+	err = connectWait()
+	if err != nil {
+		return result, err
+	}
 	err = c.startMqtt()
 	return c, err
 }
@@ -63,6 +66,7 @@ type Client struct {
 	subscriptionsMux sync.Mutex
 	subscriptions    map[string]Subscription
 
+	deviceIdsMux      sync.Mutex
 	deviceLocalIdToId map[string]string
 	mqttClientId      string
 }
@@ -71,19 +75,23 @@ func (this *Client) Stop() {
 	this.mqtt.Disconnect(0)
 }
 
+func (this *Client) IsConnected() bool {
+	return this.mqtt != nil && this.mqtt.IsConnected()
+}
+
 func (this *Client) SendEventWithQos(deviceUri string, serviceUri string, event map[platform_connector_lib.ProtocolSegmentName]string, qos byte) error {
-	topic := "event/" + this.deviceLocalIdToId[deviceUri] + "/" + serviceUri
+	topic := "event/" + this.deviceId(deviceUri) + "/" + serviceUri
 	return this.PublishStr(topic+"/resp", event["data"], qos)
 }
 
-func (this *Client) ListenCommandWithQos(deviceUri string, serviceUri string, qos byte, handler func(msg platform_connector_lib.CommandRequestMsg) (platform_connector_lib.CommandResponseMsg, error)) error {
+func (this *Client) ListenCommandWithQos(deviceUri string, serviceUri string, qos byte, handler func(correlationId string, msg platform_connector_lib.CommandRequestMsg) (platform_connector_lib.CommandResponseMsg, error)) error {
 	if !this.mqtt.IsConnected() {
 		log.Println("WARNING: mqtt client not connected")
-		return errors.New("mqtt client not connected")
+		return client.ErrNotConnected
 	}
-	topic := "command/" + this.deviceLocalIdToId[deviceUri] + "/" + serviceUri
+	topic := "command/" + this.deviceId(deviceUri) + "/" + serviceUri
 	callback := func(client paho.Client, message paho.Message) {
-		respMsg, err := handler(map[platform_connector_lib.ProtocolSegmentName]string{"data": string(message.Payload())})
+		respMsg, err := handler("", map[platform_connector_lib.ProtocolSegmentName]string{"data": string(message.Payload())})
 		if err != nil {
 			log.Println("ERROR: while processing command", err)
 			return
@@ -104,6 +112,21 @@ func (this *Client) ListenCommandWithQos(deviceUri string, serviceUri string, qo
 	return nil
 }
 
+func (this *Client) Unsubscribe(deviceUri string, serviceUri string) error {
+	if !this.mqtt.IsConnected() {
+		log.Println("WARNING: mqtt client not connected")
+		return client.ErrNotConnected
+	}
+	topic := "command/" + this.deviceId(deviceUri) + "/" + serviceUri
+	this.unregisterSubscriptions(topic)
+	token := this.mqtt.Unsubscribe(topic)
+	if token.Wait() && token.Error() != nil {
+		log.Println("Error on Client.Unsubscribe(): ", token.Error())
+		return token.Error()
+	}
+	return nil
+}
+
 func (this *Client) HubId() string {
 	return ""
 }
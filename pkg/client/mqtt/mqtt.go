@@ -3,6 +3,7 @@ package mqtt
 import (
 	"encoding/json"
 	"errors"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/client"
 	paho "github.com/eclipse/paho.mqtt.golang"
 	"log"
 )
@@ -92,10 +93,13 @@ func (this *Client) PublishJson(topic string, msg interface{}, qos byte) (err er
 func (this *Client) PublishStr(topic string, msg string, qos byte) (err error) {
 	if !this.mqtt.IsConnected() {
 		log.Println("WARNING: mqtt client not connected")
-		return errors.New("mqtt client not connected")
+		return client.ErrNotConnected
 	}
 	token := this.mqtt.Publish(topic, qos, false, msg)
-	if token.Wait() && token.Error() != nil {
+	if !token.WaitTimeout(client.PublishTimeout) {
+		return client.ErrPublishTimeout
+	}
+	if token.Error() != nil {
 		log.Println("Error on Client.Publish(): ", token.Error())
 		return token.Error()
 	}
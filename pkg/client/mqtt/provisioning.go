@@ -9,8 +9,12 @@ import (
 )
 
 func (this *Client) provisionDevices(token security.JwtToken) (newDevices bool, err error) {
+	return this.provision(this.devices, token)
+}
+
+func (this *Client) provision(devices []senergyclient.DeviceRepresentation, token security.JwtToken) (newDevices bool, err error) {
 	iotClient := iot.New(this.deviceManagerUrl, this.deviceRepoUrl, "", "")
-	for _, device := range this.devices {
+	for _, device := range devices {
 		d, err := iotClient.GetDeviceByLocalId(device.Uri, token)
 		if err != nil && err != security.ErrorNotFound {
 			log.Println("ERROR: iotClient.DeviceUrlToIotDevice()", err)
@@ -24,7 +28,9 @@ func (this *Client) provisionDevices(token security.JwtToken) (newDevices bool,
 			}
 			newDevices = true
 		}
+		this.deviceIdsMux.Lock()
 		this.deviceLocalIdToId[device.Uri] = d.Id
+		this.deviceIdsMux.Unlock()
 	}
 	return newDevices, nil
 }
@@ -33,3 +39,28 @@ func (this *Client) createIotDevice(representation senergyclient.DeviceRepresent
 	err = token.PostJSON(this.deviceManagerUrl+"/devices", model.Device{LocalId: representation.Uri, DeviceTypeId: representation.IotType, Name: representation.Name}, &device)
 	return
 }
+
+// AddDevices provisions devices; the mqtt client has no hub
+func (this *Client) AddDevices(devices []senergyclient.DeviceRepresentation) error {
+	token, err := security.GetOpenidPasswordToken(this.authUrl, this.authClientId, this.authClientSecret, this.userName, this.password)
+	if err != nil {
+		return err
+	}
+	_, err = this.provision(devices, token.JwtToken())
+	return err
+}
+
+func (this *Client) RemoveDevices(deviceUris []string) error {
+	this.deviceIdsMux.Lock()
+	defer this.deviceIdsMux.Unlock()
+	for _, uri := range deviceUris {
+		delete(this.deviceLocalIdToId, uri)
+	}
+	return nil
+}
+
+func (this *Client) deviceId(localId string) string {
+	this.deviceIdsMux.Lock()
+	defer this.deviceIdsMux.Unlock()
+	return this.deviceLocalIdToId[localId]
+}
@@ -1,23 +1,103 @@
 package senergy
 
 import (
+	"encoding/json"
 	platform_connector_lib "github.com/SENERGY-Platform/platform-connector-lib"
+	"github.com/SENERGY-Platform/platform-connector-lib/iot"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"github.com/SENERGY-Platform/platform-connector-lib/security"
 	"github.com/SENERGY-Platform/senergy-load-test/pkg/client"
+	"github.com/SENERGY-Platform/senergy-platform-connector/lib"
+	"github.com/SENERGY-Platform/senergy-platform-connector/lib/handler/response"
 	senergyclient "github.com/SENERGY-Platform/senergy-platform-connector/test/client"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-func Factory(authClientId string, authClientSecret string, mqttUrl string, deviceManagerUrl string, deviceRepoUrl string, authUrl string, userName string, password string, hubId string, hubName string, devices []senergyclient.DeviceRepresentation) (result client.Client, err error) {
-	senergyclient.Id = authClientId
-	senergyclient.Secret = authClientSecret
-	c, err := senergyclient.New(mqttUrl, deviceManagerUrl, deviceRepoUrl, authUrl, userName, password, hubId, hubName, devices)
+// MaxCommandAge is the age of commands which senergyclient.Client ignores
+const MaxCommandAge = 40 * time.Second
+
+// credentialsMux guards the auth client credentials of senergyclient, which are package variables;
+// they are only written on change because instances may be started concurrently
+var credentialsMux sync.Mutex
+
+func setCredentials(authClientId string, authClientSecret string) {
+	credentialsMux.Lock()
+	defer credentialsMux.Unlock()
+	if senergyclient.Id != authClientId {
+		senergyclient.Id = authClientId
+	}
+	if senergyclient.Secret != authClientSecret {
+		senergyclient.Secret = authClientSecret
+	}
+}
+
+// Factory provisions the devices and the hub like senergyclient.New() but connects through senergyclient.NewWithoutProvisioning() after connectWait,
+// so that connectWait only delays the mqtt connect
+func Factory(authClientId string, authClientSecret string, mqttUrl string, deviceManagerUrl string, deviceRepoUrl string, authUrl string, userName string, password string, hubId string, hubName string, devices []senergyclient.DeviceRepresentation, connectWait func() error) (result client.Client, err error) {
+	setCredentials(authClientId, authClientSecret)
+	token, err := security.GetOpenidPasswordToken(authUrl, authClientId, authClientSecret, userName, password)
+	if err != nil {
+		log.Println("ERROR: unable to login", err)
+		return result, err
+	}
+	iotClient := iot.New(deviceManagerUrl, deviceRepoUrl, "", "")
+	newDevices, err := provisionDevices(iotClient, deviceManagerUrl, devices, token.JwtToken())
+	if err != nil {
+		return result, err
+	}
+	if newDevices {
+		time.Sleep(2 * time.Second) //wait for device creation
+	}
+	hubId, newHub, err := provisionHub(iotClient, hubId, hubName, devices, token.JwtToken())
+	if err != nil {
+		return result, err
+	}
+	if newHub {
+		time.Sleep(2 * time.Second) //wait for hub creation
+	}
+	err = connectWait()
 	if err != nil {
 		return result, err
 	}
-	return &Client{c: c}, nil
+	c, err := senergyclient.NewWithoutProvisioning(mqttUrl, deviceManagerUrl, deviceRepoUrl, authUrl, userName, password, hubId, hubName, devices)
+	if err != nil {
+		return result, err
+	}
+	return &Client{
+		c:                c,
+		authUrl:          authUrl,
+		authClientId:     authClientId,
+		authClientSecret: authClientSecret,
+		userName:         userName,
+		password:         password,
+		deviceManagerUrl: deviceManagerUrl,
+		deviceRepoUrl:    deviceRepoUrl,
+		hubName:          hubName,
+		devices:          append([]senergyclient.DeviceRepresentation{}, devices...),
+	}, nil
 }
 
 type Client struct {
 	c *senergyclient.Client
+
+	authUrl          string
+	authClientId     string
+	authClientSecret string
+	userName         string
+	password         string
+	deviceManagerUrl string
+	deviceRepoUrl    string
+	hubName          string
+
+	devicesMux sync.Mutex
+	devices    []senergyclient.DeviceRepresentation
 }
 
 func (this *Client) Stop() {
@@ -28,10 +108,188 @@ func (this *Client) HubId() string {
 	return this.c.HubId
 }
 
-func (this *Client) ListenCommandWithQos(deviceUri string, serviceUri string, qos byte, f func(msg platform_connector_lib.CommandRequestMsg) (resp platform_connector_lib.CommandResponseMsg, err error)) error {
-	return this.c.ListenCommandWithQos(deviceUri, serviceUri, qos, f)
+func (this *Client) IsConnected() bool {
+	return this.c.Mqtt().IsConnected()
+}
+
+// ListenCommandWithQos subscribes through senergyclient.Client, which subscribes the command again on reconnect,
+// and replaces its message route to pass the correlation id of the request envelope to f.
+// senergyclient.Client sets its own route again on reconnect; the first command after that sets the route back.
+func (this *Client) ListenCommandWithQos(deviceUri string, serviceUri string, qos byte, f func(correlationId string, msg platform_connector_lib.CommandRequestMsg) (resp platform_connector_lib.CommandResponseMsg, err error)) error {
+	if !this.c.Mqtt().IsConnected() {
+		return client.ErrNotConnected
+	}
+	topic := "command/" + deviceUri + "/" + serviceUri
+	callback := func(_ paho.Client, message paho.Message) {
+		request := lib.RequestEnvelope{}
+		err := json.Unmarshal(message.Payload(), &request)
+		if err != nil {
+			log.Println("ERROR: unable to decode request envelope", err)
+			return
+		}
+		if time.Since(time.Unix(request.Time, 0)) > MaxCommandAge {
+			log.Println("WARNING: received old command; do nothing")
+			return
+		}
+		resp, err := f(request.CorrelationId, request.Payload)
+		if err != nil {
+			log.Println("ERROR: while processing command", err)
+			return
+		}
+		go func() {
+			err = this.c.Publish("response/"+deviceUri+"/"+serviceUri, response.ResponseEnvelope{CorrelationId: request.CorrelationId, Payload: resp}, qos)
+			if err != nil {
+				log.Println("ERROR: unable to publish response", err)
+			}
+		}()
+	}
+	err := this.c.ListenCommandWithQos(deviceUri, serviceUri, qos, func(msg platform_connector_lib.CommandRequestMsg) (platform_connector_lib.CommandResponseMsg, error) {
+		this.c.Mqtt().AddRoute(topic, callback)
+		return f("", msg)
+	})
+	if err != nil {
+		return err
+	}
+	this.c.Mqtt().AddRoute(topic, callback)
+	return nil
+}
+
+// Unsubscribe stops the command subscription; senergyclient.Client keeps the subscription registered and subscribes it again on reconnect
+func (this *Client) Unsubscribe(deviceUri string, serviceUri string) error {
+	if !this.c.Mqtt().IsConnected() {
+		return client.ErrNotConnected
+	}
+	return this.c.Unsubscribe(deviceUri, serviceUri)
 }
 
+// SendEventWithQos publishes like senergyclient.Client.SendEventWithQos but with client.PublishTimeout and typed errors
 func (this *Client) SendEventWithQos(deviceUri string, serviceUri string, event map[platform_connector_lib.ProtocolSegmentName]string, qos byte) error {
-	return this.c.SendEventWithQos(deviceUri, serviceUri, event, qos)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if !this.c.Mqtt().IsConnected() {
+		return client.ErrNotConnected
+	}
+	token := this.c.Mqtt().Publish("event/"+deviceUri+"/"+serviceUri, qos, false, string(payload))
+	if !token.WaitTimeout(client.PublishTimeout) {
+		return client.ErrPublishTimeout
+	}
+	return token.Error()
+}
+
+// AddDevices creates missing devices like senergyclient.New() and updates the device list of the hub
+func (this *Client) AddDevices(devices []senergyclient.DeviceRepresentation) error {
+	this.devicesMux.Lock()
+	defer this.devicesMux.Unlock()
+	token, err := security.GetOpenidPasswordToken(this.authUrl, this.authClientId, this.authClientSecret, this.userName, this.password)
+	if err != nil {
+		return err
+	}
+	_, err = provisionDevices(iot.New(this.deviceManagerUrl, this.deviceRepoUrl, "", ""), this.deviceManagerUrl, devices, token.JwtToken())
+	if err != nil {
+		return err
+	}
+	return this.updateHub(append(append([]senergyclient.DeviceRepresentation{}, this.devices...), devices...), token.JwtToken())
+}
+
+func (this *Client) RemoveDevices(deviceUris []string) error {
+	this.devicesMux.Lock()
+	defer this.devicesMux.Unlock()
+	token, err := security.GetOpenidPasswordToken(this.authUrl, this.authClientId, this.authClientSecret, this.userName, this.password)
+	if err != nil {
+		return err
+	}
+	removed := map[string]bool{}
+	for _, uri := range deviceUris {
+		removed[uri] = true
+	}
+	devices := []senergyclient.DeviceRepresentation{}
+	for _, device := range this.devices {
+		if !removed[device.Uri] {
+			devices = append(devices, device)
+		}
+	}
+	return this.updateHub(devices, token.JwtToken())
+}
+
+func (this *Client) updateHub(devices []senergyclient.DeviceRepresentation, token security.JwtToken) error {
+	deviceUris := []string{}
+	for _, device := range devices {
+		deviceUris = append(deviceUris, device.Uri)
+	}
+	_, err := iot.New(this.deviceManagerUrl, this.deviceRepoUrl, "", "").UpdateHub(this.c.HubId, model.Hub{Hash: getHash(deviceUris), Name: this.hubName, DeviceLocalIds: deviceUris}, token)
+	if err != nil {
+		log.Println("ERROR: iotClient.UpdateHub()", err)
+		return err
+	}
+	this.devices = devices
+	return nil
+}
+
+// provisionDevices creates the missing devices
+func provisionDevices(iotClient *iot.Iot, deviceManagerUrl string, devices []senergyclient.DeviceRepresentation, token security.JwtToken) (newDevices bool, err error) {
+	for _, device := range devices {
+		_, err = iotClient.GetDeviceByLocalId(device.Uri, token)
+		if err != nil && err != security.ErrorNotFound {
+			log.Println("ERROR: iotClient.GetDeviceByLocalId()", err)
+			return newDevices, err
+		}
+		if err == security.ErrorNotFound {
+			err = token.PostJSON(deviceManagerUrl+"/devices", model.Device{LocalId: device.Uri, DeviceTypeId: device.IotType, Name: device.Name}, &model.Device{})
+			if err != nil {
+				log.Println("ERROR: unable to create device", device.Uri, err)
+				return newDevices, err
+			}
+			newDevices = true
+		}
+	}
+	return newDevices, nil
+}
+
+// provisionHub creates the hub if hubId is empty or unknown and updates its device list otherwise, like senergyclient.New()
+func provisionHub(iotClient *iot.Iot, hubId string, hubName string, devices []senergyclient.DeviceRepresentation, token security.JwtToken) (resultId string, isNew bool, err error) {
+	deviceUris := []string{}
+	for _, device := range devices {
+		deviceUris = append(deviceUris, device.Uri)
+	}
+	hub := model.Hub{Hash: getHash(deviceUris), Name: hubName, DeviceLocalIds: deviceUris}
+	exists := false
+	if hubId != "" {
+		exists, err = iotClient.ExistsHub(hubId, token)
+		if err != nil {
+			log.Println("ERROR: iotClient.ExistsHub()", err)
+			return hubId, false, err
+		}
+	}
+	if !exists {
+		created, err := iotClient.CreateHub(hub, token)
+		if err != nil {
+			log.Println("ERROR: iotClient.CreateHub()", err)
+			return hubId, true, err
+		}
+		return created.Id, true, nil
+	}
+	old, err := iotClient.GetHub(hubId, token)
+	if err != nil {
+		log.Println("ERROR: iotClient.GetHub()", err)
+		return hubId, false, err
+	}
+	if old.Hash != hub.Hash {
+		_, err = iotClient.UpdateHub(hubId, hub, token)
+		if err != nil {
+			log.Println("ERROR: iotClient.UpdateHub()", err)
+			return hubId, false, err
+		}
+	}
+	return hubId, false, nil
+}
+
+// getHash is the hub hash of senergyclient
+func getHash(deviceUris []string) string {
+	sorted := append([]string{}, deviceUris...)
+	sort.Strings(sorted)
+	h := fnv.New32a()
+	h.Write([]byte(strings.Join(sorted, ";")))
+	return strconv.FormatUint(uint64(h.Sum32()), 10)
 }
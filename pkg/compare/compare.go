@@ -0,0 +1,266 @@
+package compare
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/report"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/slo"
+	"html"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	ExitOk         = 0
+	ExitRegression = 1
+	ExitInvalid    = 2
+)
+
+const DefaultTolerance = 10.0 //percent
+
+// Metric is compared between baseline and candidate; names are the metric names of slo.Threshold
+type Metric struct {
+	Name         string
+	HigherBetter bool
+}
+
+var Metrics = []Metric{
+	{Name: "throughput", HigherBetter: true},
+	{Name: "publish_latency.p50"},
+	{Name: "publish_latency.p95"},
+	{Name: "publish_latency.p99"},
+	{Name: "send_delay.p99"},
+	{Name: "event_latency.p50"},
+	{Name: "event_latency.p99"},
+	{Name: "command_latency.p95"},
+	{Name: "process_completion.p95"},
+	{Name: "error_rate"},
+	{Name: "missed"},
+	{Name: "loss_rate"},
+	{Name: "duplicate"},
+}
+
+type Delta struct {
+	Metric     string  `json:"metric"`
+	Unit       string  `json:"unit"`
+	Baseline   float64 `json:"baseline"`
+	Candidate  float64 `json:"candidate"`
+	Change     float64 `json:"change"` //relative change in percent; 0 if the baseline is 0
+	Tolerance  float64 `json:"tolerance"`
+	Regression bool    `json:"regression"`
+	Skipped    bool    `json:"skipped"` //no samples in baseline or candidate
+}
+
+// Compare computes the deltas of all Metrics; tolerances (percent by metric name) override defaultTolerance
+func Compare(baseline report.Report, candidate report.Report, defaultTolerance float64, tolerances map[string]float64) (result []Delta, err error) {
+	for _, metric := range Metrics {
+		base, category, err := slo.Metric(metric.Name, baseline.Run)
+		if err != nil {
+			return result, err
+		}
+		cand, _, err := slo.Metric(metric.Name, candidate.Run)
+		if err != nil {
+			return result, err
+		}
+		tolerance, ok := tolerances[metric.Name]
+		if !ok {
+			tolerance = defaultTolerance
+		}
+		delta := Delta{
+			Metric:    metric.Name,
+			Unit:      unit(metric.Name, category),
+			Baseline:  base,
+			Candidate: cand,
+			Tolerance: tolerance,
+		}
+		worse := cand - base
+		if metric.HigherBetter {
+			worse = base - cand
+		}
+		switch {
+		case base != 0:
+			delta.Change = (cand - base) / base * 100
+			delta.Regression = worse/base*100 > tolerance
+		case category == slo.LatencyCategory || category == slo.ThroughputCategory:
+			//without baseline samples there is nothing to compare
+			delta.Skipped = true
+		default:
+			//errors and losses which did not occur in the baseline
+			delta.Regression = worse > 0
+		}
+		if category == slo.LatencyCategory && cand == 0 {
+			delta.Skipped = true
+			delta.Regression = false
+		}
+		result = append(result, delta)
+	}
+	return result, nil
+}
+
+func Regression(deltas []Delta) bool {
+	for _, delta := range deltas {
+		if delta.Regression {
+			return true
+		}
+	}
+	return false
+}
+
+func unit(name string, category slo.Category) string {
+	switch {
+	case category == slo.LatencyCategory:
+		return "ms"
+	case category == slo.ThroughputCategory:
+		return "events/s"
+	case strings.HasSuffix(name, "_rate"):
+		return "%"
+	}
+	return ""
+}
+
+func format(value float64, unit string) string {
+	switch unit {
+	case "ms":
+		value = value * 1000
+	case "%":
+		value = value * 100
+	}
+	if unit == "" || unit == "%" {
+		return strconv.FormatFloat(value, 'f', 3, 64) + unit
+	}
+	return strconv.FormatFloat(value, 'f', 3, 64) + " " + unit
+}
+
+func state(delta Delta) string {
+	switch {
+	case delta.Regression:
+		return "REGRESSION"
+	case delta.Skipped:
+		return "skipped"
+	}
+	return "ok"
+}
+
+func WriteMarkdown(writer io.Writer, baseline report.Report, candidate report.Report, deltas []Delta) {
+	fmt.Fprintln(writer, "## Load test comparison")
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "baseline:", baseline.RunId, baseline.Start.Format("2006-01-02 15:04:05"), "| candidate:", candidate.RunId, candidate.Start.Format("2006-01-02 15:04:05"))
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "| metric | baseline | candidate | change | tolerance | result |")
+	fmt.Fprintln(writer, "|---|---:|---:|---:|---:|---|")
+	for _, delta := range deltas {
+		fmt.Fprintf(writer, "| %s | %s | %s | %+.2f%% | %.2f%% | %s |\n", delta.Metric, format(delta.Baseline, delta.Unit), format(delta.Candidate, delta.Unit), delta.Change, delta.Tolerance, state(delta))
+	}
+}
+
+func WriteHtml(writer io.Writer, baseline report.Report, candidate report.Report, deltas []Delta) {
+	fmt.Fprintln(writer, "<html><head><title>Load test comparison</title></head><body>")
+	fmt.Fprintln(writer, "<h2>Load test comparison</h2>")
+	fmt.Fprintf(writer, "<p>baseline: %s %s | candidate: %s %s</p>\n", html.EscapeString(baseline.RunId), baseline.Start.Format("2006-01-02 15:04:05"), html.EscapeString(candidate.RunId), candidate.Start.Format("2006-01-02 15:04:05"))
+	fmt.Fprintln(writer, "<table border=\"1\"><tr><th>metric</th><th>baseline</th><th>candidate</th><th>change</th><th>tolerance</th><th>result</th></tr>")
+	for _, delta := range deltas {
+		color := ""
+		if delta.Regression {
+			color = " style=\"color:red\""
+		}
+		fmt.Fprintf(writer, "<tr%s><td>%s</td><td>%s</td><td>%s</td><td>%+.2f%%</td><td>%.2f%%</td><td>%s</td></tr>\n", color, html.EscapeString(delta.Metric), format(delta.Baseline, delta.Unit), format(delta.Candidate, delta.Unit), delta.Change, delta.Tolerance, state(delta))
+	}
+	fmt.Fprintln(writer, "</table></body></html>")
+}
+
+func Load(location string) (result report.Report, err error) {
+	file, err := os.Open(location)
+	if err != nil {
+		return result, err
+	}
+	defer file.Close()
+	err = json.NewDecoder(file).Decode(&result)
+	return result, err
+}
+
+// Command runs the compare command with args (without the command name) and returns the exit code
+func Command(args []string) int {
+	flags := flag.NewFlagSet("compare", flag.ContinueOnError)
+	baselineLocation := flags.String("baseline", "", "json report of the baseline run")
+	candidateLocation := flags.String("candidate", "", "json report of the candidate run")
+	defaultTolerance := flags.Float64("tolerance", DefaultTolerance, "allowed regression in percent")
+	tolerancesLocation := flags.String("tolerances", "", "json file with allowed regression in percent by metric name, e.g. {\"throughput\": 5}")
+	outputFormat := flags.String("format", "markdown", "markdown or html")
+	outputLocation := flags.String("out", "", "output file; stdout if empty")
+	err := flags.Parse(args)
+	if err != nil {
+		return ExitInvalid
+	}
+	err = command(*baselineLocation, *candidateLocation, *defaultTolerance, *tolerancesLocation, *outputFormat, *outputLocation)
+	if err == errRegression {
+		return ExitRegression
+	}
+	if err != nil {
+		log.Println("ERROR:", err)
+		return ExitInvalid
+	}
+	return ExitOk
+}
+
+var errRegression = errors.New("regression")
+
+func command(baselineLocation string, candidateLocation string, defaultTolerance float64, tolerancesLocation string, outputFormat string, outputLocation string) error {
+	if baselineLocation == "" || candidateLocation == "" {
+		return errors.New("missing -baseline or -candidate")
+	}
+	baseline, err := Load(baselineLocation)
+	if err != nil {
+		return err
+	}
+	candidate, err := Load(candidateLocation)
+	if err != nil {
+		return err
+	}
+	tolerances := map[string]float64{}
+	if tolerancesLocation != "" {
+		file, err := os.Open(tolerancesLocation)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		err = json.NewDecoder(file).Decode(&tolerances)
+		if err != nil {
+			return err
+		}
+	}
+	deltas, err := Compare(baseline, candidate, defaultTolerance, tolerances)
+	if err != nil {
+		return err
+	}
+	var writer io.Writer = os.Stdout
+	if outputLocation != "" {
+		file, err := os.OpenFile(outputLocation, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+	switch outputFormat {
+	case "markdown", "md":
+		WriteMarkdown(writer, baseline, candidate, deltas)
+	case "html":
+		WriteHtml(writer, baseline, candidate, deltas)
+	default:
+		return errors.New("unknown format " + outputFormat)
+	}
+	if Regression(deltas) {
+		for _, delta := range deltas {
+			if delta.Regression {
+				log.Println("REGRESSION:", delta.Metric, format(delta.Baseline, delta.Unit), "-->", format(delta.Candidate, delta.Unit))
+			}
+		}
+		return errRegression
+	}
+	return nil
+}
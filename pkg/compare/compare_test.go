@@ -0,0 +1,69 @@
+package compare
+
+import (
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/report"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"strings"
+	"testing"
+	"time"
+)
+
+func run(throughput float64, p99 time.Duration, missed uint64) report.Report {
+	return report.Report{Run: statistics.RunSummary{
+		Produced:    1000,
+		Throughput:  throughput,
+		ProduceTime: statistics.LatencySummary{Count: 1000, P50: p99 / 2, P95: p99, P99: p99},
+		Missed:      missed,
+	}}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name        string
+		baseline    report.Report
+		candidate   report.Report
+		tolerances  map[string]float64
+		regressions []string
+		skipped     []string
+	}{
+		{"equal", run(100, 10*time.Millisecond, 0), run(100, 10*time.Millisecond, 0), nil, nil, nil},
+		{"within tolerance", run(100, 10*time.Millisecond, 0), run(95, 10500*time.Microsecond, 0), nil, nil, nil},
+		{"improved", run(100, 10*time.Millisecond, 10), run(200, 5*time.Millisecond, 0), nil, nil, nil},
+		{"lower throughput", run(100, 10*time.Millisecond, 0), run(80, 10*time.Millisecond, 0), nil, []string{"throughput"}, nil},
+		{"higher latency", run(100, 10*time.Millisecond, 0), run(100, 20*time.Millisecond, 0), nil, []string{"publish_latency.p50", "publish_latency.p95", "publish_latency.p99"}, nil},
+		{"tolerance override", run(100, 10*time.Millisecond, 0), run(100, 20*time.Millisecond, 0), map[string]float64{"publish_latency.p50": 200, "publish_latency.p95": 200, "publish_latency.p99": 200}, nil, nil},
+		{"new misses", run(100, 10*time.Millisecond, 0), run(100, 10*time.Millisecond, 1), nil, []string{"missed"}, nil},
+		{"no baseline latency", run(100, 0, 0), run(100, 10*time.Millisecond, 0), nil, nil, []string{"publish_latency.p50", "publish_latency.p95", "publish_latency.p99"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deltas, err := Compare(test.baseline, test.candidate, DefaultTolerance, test.tolerances)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deltas) != len(Metrics) {
+				t.Fatalf("expect a delta per metric, got %v", len(deltas))
+			}
+			regressions := map[string]bool{}
+			for _, name := range test.regressions {
+				regressions[name] = true
+			}
+			skipped := map[string]bool{}
+			for _, name := range test.skipped {
+				skipped[name] = true
+			}
+			for _, delta := range deltas {
+				if delta.Regression != regressions[delta.Metric] {
+					t.Errorf("%v: expect regression %v, got %#v", delta.Metric, regressions[delta.Metric], delta)
+				}
+				//the other latencies have no samples in both reports
+				if strings.HasPrefix(delta.Metric, "publish_latency") && delta.Skipped != skipped[delta.Metric] {
+					t.Errorf("%v: expect skipped %v, got %#v", delta.Metric, skipped[delta.Metric], delta)
+				}
+			}
+			if Regression(deltas) != (len(test.regressions) > 0) {
+				t.Error("unexpected overall regression", Regression(deltas))
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/platform-connector-lib/security"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"log"
+	"net/url"
+	"time"
+)
+
+const DefaultProcessCompletionTimeout = 5 * time.Minute
+
+type HistoricProcessInstance struct {
+	Id               string  `json:"id"`
+	EndTime          string  `json:"endTime"`
+	DurationInMillis float64 `json:"durationInMillis"`
+	State            string  `json:"state"`
+}
+
+// completionChecker polls the process engine until a started process instance is finished
+type completionChecker struct {
+	ctx      context.Context
+	config   configuration.Config
+	token    security.JwtToken
+	stat     statistics.Interface
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// newCompletionChecker returns nil if process_completion_check_interval is not set
+func newCompletionChecker(ctx context.Context, config configuration.Config, token security.JwtToken, stat statistics.Interface) (result *completionChecker, err error) {
+	if config.ProcessCompletionCheckInterval == "" || config.ProcessCompletionCheckInterval == "-" {
+		return nil, nil
+	}
+	result = &completionChecker{ctx: ctx, config: config, token: token, stat: stat, timeout: DefaultProcessCompletionTimeout}
+	result.interval, err = time.ParseDuration(config.ProcessCompletionCheckInterval)
+	if err != nil {
+		return nil, err
+	}
+	if config.ProcessCompletionTimeout != "" {
+		result.timeout, err = time.ParseDuration(config.ProcessCompletionTimeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (this *completionChecker) Watch(instanceId string, triggered time.Time) {
+	t := time.NewTicker(this.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-this.ctx.Done():
+			return
+		case now := <-t.C:
+			if now.Sub(triggered) > this.timeout {
+				log.Println("WARNING: process instance not finished within", this.timeout.String(), instanceId)
+				return
+			}
+			instance := HistoricProcessInstance{}
+			err := getJSON(this.token, this.config.ProcessEngineWrapperUrl+"/v2/history/process-instances/"+url.PathEscape(instanceId), &instance, this.stat, "process-engine/history")
+			if err != nil {
+				if this.config.Debug {
+					log.Println("DEBUG: unable to get process instance history", instanceId, err)
+				}
+				continue
+			}
+			if instance.EndTime != "" {
+				if instance.State != "" && instance.State != "COMPLETED" {
+					log.Println("WARNING: process instance", instanceId, "finished with state", instance.State)
+				}
+				//measured locally to include the start request; the resolution is limited by the check interval
+				this.stat.ProcessCompleted(time.Since(triggered))
+				return
+			}
+		}
+	}
+}
@@ -20,6 +20,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/SENERGY-Platform/senergy-load-test/pkg/analytics/model"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/distribution"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/loadprofile"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/sinks"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/slo"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/tracking"
 	"os"
 	"reflect"
 	"regexp"
@@ -48,6 +53,23 @@ type Config struct {
 	ServiceMessage     string `json:"service_message"`
 	DeleteOnShutdown   bool   `json:"delete_on_shutdown"`
 
+	Services     []Service     `json:"services"`      //replaces command_service_uri, event_service_uri and service_message if set
+	DeviceGroups []DeviceGroup `json:"device_groups"` //replaces device_type and device_count if set; percentages refer to device_count
+
+	LoadProfile loadprofile.Config `json:"load_profile"`
+	TargetRate  float64            `json:"target_rate"` //events per second over all devices; replaces emitter_interval if > 0
+
+	EmitterDistribution distribution.Config `json:"emitter_distribution"`
+	ProcessDistribution distribution.Config `json:"process_distribution"`
+	Seed                int64               `json:"seed"` //0 --> random seed; the used seed is logged to reproduce the run
+
+	RunId           string          `json:"run_id"` //embedded in event traces; random if empty
+	LatencyConsumer tracking.Config `json:"latency_consumer"`
+
+	EmitterQueueSize int64 `json:"emitter_queue_size"`
+	SenderWorkers    int64 `json:"sender_workers"`
+	MaxInFlight      int64 `json:"max_in_flight"` //max concurrent publishes per client connection; defaults to and is limited by sender_workers
+
 	ProcessStartOnce        bool   `json:"process_start_once"`
 	ProcessInfoLocation     string `json:"process_info_location"`
 	ProcessDeploymentUrl    string `json:"process_deployment_url"`
@@ -59,6 +81,10 @@ type Config struct {
 	OneProcessEveryNDevices int64  `json:"one_process_every_n_devices"`
 	Qos                     int64  `json:"qos"`
 
+	CommandTimeout                 string `json:"command_timeout"`                   //max time between process trigger and command receipt; defaults to 5m
+	ProcessCompletionCheckInterval string `json:"process_completion_check_interval"` //poll interval for finished process instances; empty or - to disable
+	ProcessCompletionTimeout       string `json:"process_completion_timeout"`        //defaults to 5m
+
 	AnalyticInfoLocation      string             `json:"analytic_info_location"`
 	PublicFlowEngineUrl       string             `json:"public_flow_engine_url"`
 	PublicFlowParserUrl       string             `json:"public_flow_parser_url"`
@@ -71,7 +97,42 @@ type Config struct {
 	IsCleanup           bool   `json:"is_cleanup"`
 	PermissionsQueryUrl string `json:"permissions_query_url"`
 
-	Instances int64 `json:"instances"`
+	Instances          int64   `json:"instances"`
+	StartupParallelism int64   `json:"startup_parallelism"` //instances started concurrently; 0 or 1 starts them one after another
+	ConnectRate        float64 `json:"connect_rate"`        //limits the mqtt connects of all instances per second; provisioning of hubs and devices is not limited; 0 is unlimited
+
+	HttpPort string `json:"http_port"` //serves /metrics and the control api; empty or - to disable
+	ApiToken string `json:"api_token"` //required by the control api as "Authorization: Bearer <token>"; empty to only accept requests from localhost
+
+	StatisticsSinks []sinks.Config `json:"statistics_sinks"` //each statistics interval is written to these sinks (csv_file, influx_file, influx_http, stdout, http)
+
+	ReportLocation    string `json:"report_location"`     //json report written on shutdown; empty to disable
+	ReportCsvLocation string `json:"report_csv_location"` //csv time series of the last 1000 statistics intervals (csv_file sink for all); empty to disable
+
+	Duration         string          `json:"duration"`           //ends the run after the duration; empty or - to run until a shutdown signal
+	Thresholds       []slo.Threshold `json:"thresholds"`         //evaluated against the run statistics; a breach results in a non-zero exit code
+	SloEvaluation    string          `json:"slo_evaluation"`     //"end" (default) or "continuous" to abort the run on the first breach
+	SloCheckInterval string          `json:"slo_check_interval"` //interval of continuous evaluation; defaults to 10s
+	SloWarmup        string          `json:"slo_warmup"`         //time before the first continuous evaluation
+
+	MaxEvents        int64  `json:"max_events"`         //ends the run after this number of emitted events of all instances; 0 is unlimited
+	MaxProcessStarts int64  `json:"max_process_starts"` //ends the run after this number of process starts of all instances; 0 is unlimited
+	DrainTimeout     string `json:"drain_timeout"`      //limits draining the emitter queue and waiting for publishes and command responses on shutdown; defaults to 30s
+
+	DeviceStatistics     bool  `json:"device_statistics"`      //per device counters with top-n/bottom-n and fairness in logs and reports
+	DeviceStatisticsTopN int64 `json:"device_statistics_top_n"` //number of top and bottom devices; defaults to 5
+
+	Mode            string `json:"mode"`             //"" (standalone), "coordinator" or "worker" (see pkg/distributed)
+	CoordinatorUrl  string `json:"coordinator_url"`  //worker: api of the coordinator, e.g. http://coordinator:8080
+	WorkerUrl       string `json:"worker_url"`       //worker: own api url used by the coordinator; defaults to http://<hostname>:<http_port>
+	Workers         int64  `json:"workers"`          //coordinator: number of workers which are started together
+	StartDelay      string `json:"start_delay"`      //coordinator: time between the readiness of all workers and the synchronized start; defaults to 10s
+	RegisterTimeout string `json:"register_timeout"` //coordinator: limits the wait for the registration and the readiness of all workers; defaults to 10m
+	ReportTimeout   string `json:"report_timeout"`   //coordinator: limits the wait for the remaining reports after the first report or a shutdown; defaults to 2m
+	FleetOffset     int64  `json:"fleet_offset"`     //offset of .FleetIndex in device names and payloads; set by the coordinator
+	StartPaused     bool   `json:"start_paused"`     //emitters start paused until POST /emitters/resume (workers resume at the start time of the coordinator)
+
+	ScenarioLocation string `json:"scenario_location"` //json file with ordered phases (see pkg/scenario); the run ends after the last phase
 
 	ConnectorType string `json:"connector_type"`
 }
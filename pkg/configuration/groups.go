@@ -0,0 +1,91 @@
+package configuration
+
+import (
+	"errors"
+	"math"
+)
+
+type DeviceGroup struct {
+	Name                      string    `json:"name"`
+	DeviceType                string    `json:"device_type"`                   //defaults to device_type
+	Count                     int64     `json:"count"`                         //absolute number of devices
+	Percentage                float64   `json:"percentage"`                    //share of device_count; used if count is 0
+	Naming                    string    `json:"naming"`                        //device name/local id template with .Prefix, .Group, .Index and .FleetIndex; defaults to {{.Prefix}}_{{.Group}}_{{.Index}}
+	EmitterInterval           string    `json:"emitter_interval"`              //default interval of the group services; defaults to emitter_interval
+	Services                  []Service `json:"services"`                      //defaults to the global services
+	OneProcessEveryNDevices   int64     `json:"one_process_every_n_devices"`   //0 --> one_process_every_n_devices; < 0 --> no processes
+	OneAnalyticsEveryNDevices int64     `json:"one_analytics_every_n_devices"` //0 --> one_analytics_every_n_devices; < 0 --> no analytics
+	ProcessServiceId          string    `json:"process_service_id"`            //defaults to process_service_id
+	IndexOffset               int64     `json:"index_offset"`                  //offset of .Index in device names and payloads; set by the coordinator
+}
+
+// LegacyNaming keeps the device names of configurations without device_groups
+const LegacyNaming = "{{.Prefix}}_{{.Index}}"
+const DefaultGroupNaming = "{{.Prefix}}_{{.Group}}_{{.Index}}"
+
+// GetDeviceGroups returns the device groups with defaults applied and resolved device counts;
+// without device_groups, one group with device_type and device_count is used
+func (this Config) GetDeviceGroups() (result []DeviceGroup, err error) {
+	groups := this.DeviceGroups
+	if len(groups) == 0 {
+		groups = []DeviceGroup{{
+			Name:       "default",
+			DeviceType: this.DeviceType,
+			Count:      this.DeviceCount,
+			Naming:     LegacyNaming,
+		}}
+	}
+	names := map[string]bool{}
+	percentageGroups := []int{}
+	exact := 0.0
+	rounded := int64(0)
+	for i, group := range groups {
+		if group.Name == "" {
+			return result, errors.New("missing device group name")
+		}
+		if names[group.Name] {
+			return result, errors.New("duplicate device group name: " + group.Name)
+		}
+		names[group.Name] = true
+		if group.DeviceType == "" {
+			group.DeviceType = this.DeviceType
+		}
+		if group.Naming == "" {
+			group.Naming = DefaultGroupNaming
+		}
+		if group.EmitterInterval == "" {
+			group.EmitterInterval = this.EmitterInterval
+		}
+		if len(group.Services) == 0 {
+			group.Services = this.GetServices()
+		} else {
+			group.Services = this.withServiceDefaults(group.Services, group.EmitterInterval)
+		}
+		if group.OneProcessEveryNDevices == 0 {
+			group.OneProcessEveryNDevices = this.OneProcessEveryNDevices
+		}
+		if group.OneAnalyticsEveryNDevices == 0 {
+			group.OneAnalyticsEveryNDevices = this.OneAnalyticsEveryNDevices
+		}
+		if group.ProcessServiceId == "" {
+			group.ProcessServiceId = this.ProcessServiceId
+		}
+		if group.Count == 0 && group.Percentage > 0 {
+			count := float64(this.DeviceCount) * group.Percentage / 100
+			group.Count = int64(math.Floor(count))
+			exact = exact + count
+			rounded = rounded + group.Count
+			percentageGroups = append(percentageGroups, i)
+		}
+		if group.Count < 0 {
+			return result, errors.New("negative device count in group " + group.Name)
+		}
+		result = append(result, group)
+	}
+	//distribute devices lost by rounding down percentages
+	missing := int64(math.Round(exact)) - rounded
+	for i := 0; int64(i) < missing && i < len(percentageGroups); i++ {
+		result[percentageGroups[i]].Count++
+	}
+	return result, nil
+}
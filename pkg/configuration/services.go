@@ -0,0 +1,54 @@
+package configuration
+
+import (
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/distribution"
+)
+
+const EventDirection = "event"
+const CommandDirection = "command"
+
+type Service struct {
+	ServiceUri   string               `json:"service_uri"`
+	Direction    string               `json:"direction"`    //event or command
+	Interval     string               `json:"interval"`     //event: mean emit interval; defaults to emitter_interval
+	Payload      string               `json:"payload"`      //event: message template, command: response template; defaults to service_message
+	Qos          *int64               `json:"qos"`          //defaults to qos
+	Distribution *distribution.Config `json:"distribution"` //event: defaults to emitter_distribution
+}
+
+// GetServices returns the simulated services with defaults applied;
+// without a services list, the legacy event_service_uri and command_service_uri are used
+func (this Config) GetServices() []Service {
+	services := this.Services
+	if len(services) == 0 {
+		services = []Service{
+			{ServiceUri: this.CommandServiceUri, Direction: CommandDirection},
+			{ServiceUri: this.EventServiceUri, Direction: EventDirection},
+		}
+	}
+	return this.withServiceDefaults(services, this.EmitterInterval)
+}
+
+func (this Config) withServiceDefaults(services []Service, interval string) (result []Service) {
+	for _, service := range services {
+		if service.ServiceUri == "" {
+			continue
+		}
+		if service.Interval == "" {
+			service.Interval = interval
+		}
+		if service.Payload == "" {
+			service.Payload = this.ServiceMessage
+		}
+		if service.Qos == nil {
+			qos := this.Qos
+			service.Qos = &qos
+		}
+		if service.Distribution == nil {
+			arrival := this.EmitterDistribution
+			service.Distribution = &arrival
+		}
+		result = append(result, service)
+	}
+	return result
+}
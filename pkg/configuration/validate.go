@@ -0,0 +1,38 @@
+package configuration
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/distribution"
+)
+
+// Validate checks settings which would otherwise only fail after the hub and devices are created
+func (this Config) Validate() error {
+	groups, err := this.GetDeviceGroups()
+	if err != nil {
+		return err
+	}
+	_, err = this.LatencyConsumer.GetTimeout()
+	if err != nil {
+		return errors.New("invalid latency_consumer timeout: " + err.Error())
+	}
+	_, err = distribution.New(this.EmitterDistribution)
+	if err != nil {
+		return errors.New("invalid emitter_distribution: " + err.Error())
+	}
+	_, err = distribution.New(this.ProcessDistribution)
+	if err != nil {
+		return errors.New("invalid process_distribution: " + err.Error())
+	}
+	for _, group := range groups {
+		for _, service := range group.Services {
+			if service.Direction != EventDirection || service.Distribution == nil {
+				continue
+			}
+			_, err = distribution.New(*service.Distribution)
+			if err != nil {
+				return errors.New("invalid distribution of " + service.ServiceUri + " in group " + group.Name + ": " + err.Error())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package configuration
+
+import (
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/distribution"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateDistributions(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	err := os.WriteFile(empty, []byte("# no samples\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{name: "defaults", config: Config{EventServiceUri: "event"}, valid: true},
+		{name: "empty emitter_distribution file", config: Config{EventServiceUri: "event", EmitterDistribution: distribution.Config{Type: "empirical", File: empty}}},
+		{name: "unknown process_distribution", config: Config{ProcessDistribution: distribution.Config{Type: "normal"}}},
+		{name: "empty service distribution file", config: Config{Services: []Service{
+			{ServiceUri: "event", Direction: EventDirection, Distribution: &distribution.Config{Type: "empirical", File: empty}},
+		}}},
+		{name: "empty group service distribution file", config: Config{DeviceGroups: []DeviceGroup{{Name: "sensors", Services: []Service{
+			{ServiceUri: "event", Direction: EventDirection, Distribution: &distribution.Config{Type: "empirical", File: empty}},
+		}}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.config.Validate()
+			if test.valid && err != nil {
+				t.Error(err)
+			}
+			if !test.valid && err == nil {
+				t.Error("expect error")
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package control
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// Budget limits a count of the whole run over all instances (e.g. max_events); taking the last unit ends the run (see Exhausted)
+type Budget struct {
+	Name  string
+	Limit int64 //<= 0 is unlimited
+	used  int64
+}
+
+func NewBudget(name string, limit int64) *Budget {
+	return &Budget{Name: name, Limit: limit}
+}
+
+// Take reserves one unit; false if the budget is already exhausted. a nil budget is unlimited
+func (this *Budget) Take() bool {
+	if this == nil || this.Limit <= 0 {
+		return true
+	}
+	used := atomic.AddInt64(&this.used, 1)
+	if used > this.Limit {
+		return false
+	}
+	if used == this.Limit {
+		log.Println("INFO: budget", this.Name, "of", this.Limit, "exhausted")
+		exhaust()
+	}
+	return true
+}
+
+// Used returns the taken units
+func (this *Budget) Used() int64 {
+	if this == nil {
+		return 0
+	}
+	used := atomic.LoadInt64(&this.used)
+	if this.Limit > 0 && used > this.Limit {
+		return this.Limit
+	}
+	return used
+}
+
+var exhausted = make(chan struct{})
+var exhaustedOnce sync.Once
+
+func exhaust() {
+	exhaustedOnce.Do(func() {
+		close(exhausted)
+	})
+}
+
+// Exhausted is closed when the first budget is used up
+func Exhausted() <-chan struct{} {
+	return exhausted
+}
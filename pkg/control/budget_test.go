@@ -0,0 +1,39 @@
+package control
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestBudget(t *testing.T) {
+	var unlimited *Budget
+	if !unlimited.Take() || !NewBudget("unlimited", 0).Take() {
+		t.Error("expect nil and 0 budgets to be unlimited")
+	}
+	budget := NewBudget("test", 100)
+	taken := int64(0)
+	mux := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if budget.Take() {
+					mux.Lock()
+					taken++
+					mux.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if taken != 100 || budget.Used() != 100 {
+		t.Errorf("expect 100 taken units, got %v (used %v)", taken, budget.Used())
+	}
+	select {
+	case <-Exhausted():
+	default:
+		t.Error("expect exhausted budget to end the run")
+	}
+}
@@ -0,0 +1,309 @@
+package control
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/loadprofile"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/metrics"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrIntervalMode = errors.New("instance has no emitter_interval (target_rate or unparsable interval); change the target rate or factor instead")
+var ErrRateMode = errors.New("instance emits with intervals; change the interval or factor instead")
+var ErrScalingUnsupported = errors.New("instance does not support adding or removing devices")
+
+// subsystems of an instance which can be switched with SetEnabled (e.g. by scenario phases)
+const (
+	Events    = "events"    //emitters; disabled emitters behave like paused ones
+	Commands  = "commands"  //responses of the simulated devices to commands
+	Processes = "processes" //process deployment and triggers
+	Analytics = "analytics" //pipeline deployment; deployed pipelines keep running
+)
+
+var Subsystems = []string{Events, Commands, Processes, Analytics}
+
+// Instance steers the emitters of a started hub (see pkg.start)
+type Instance struct {
+	Name         string
+	Metrics      *metrics.Instance
+	HubId        func() string
+	BaseInterval time.Duration //emitter_interval; 0 if the instance emits with a target rate
+	BaseRate     float64       //target_rate; 0 if the instance emits with intervals
+
+	Burst     func(count int) (queued int)                          //enqueues count events immediately
+	ScaleUp   func(count int) (devices int, err error)              //adds count devices; nil if unsupported
+	ScaleDown func(count int, delete bool) (devices int, err error) //removes count devices; nil if unsupported
+	Deploy    func(subsystem string) error                          //deploys processes or analytics when they are enabled the first time; nil if they are deployed on start
+
+	paused    uint32
+	factor    uint64   //math.Float64bits of the rate multiplier; 0 is read as 1
+	disabled  sync.Map //subsystem -> true
+	profile   atomic.Value
+	deployMux sync.Mutex
+	deployed  map[string]bool
+}
+
+// profileState replaces the load profile of the instance from start on (see SetProfile)
+type profileState struct {
+	profile loadprofile.Profile
+	start   time.Time
+}
+
+// Rate applies pause, disabled events, a profile set by SetProfile and rate changes to the factor of the load profile
+func (this *Instance) Rate(factor float64) float64 {
+	if this.Paused() || !this.Enabled(Events) {
+		return 0
+	}
+	return this.profileFactor(factor) * this.Factor()
+}
+
+// ProcessRate applies disabled processes and a profile set by SetProfile to the factor of the load profile
+func (this *Instance) ProcessRate(factor float64) float64 {
+	if !this.Enabled(Processes) {
+		return 0
+	}
+	return this.profileFactor(factor)
+}
+
+// profileFactor returns the factor of the profile set by SetProfile or factor if none is set
+func (this *Instance) profileFactor(factor float64) float64 {
+	if state, ok := this.profile.Load().(profileState); ok && state.profile != nil {
+		return state.profile.Factor(time.Since(state.start))
+	}
+	return factor
+}
+
+// SetProfile replaces the configured load profile, starting now; nil restores the configured load profile
+func (this *Instance) SetProfile(profile loadprofile.Profile) {
+	this.profile.Store(profileState{profile: profile, start: time.Now()})
+}
+
+// Enabled reports whether subsystem runs; all subsystems are enabled by default
+func (this *Instance) Enabled(subsystem string) bool {
+	_, disabled := this.disabled.Load(subsystem)
+	return !disabled
+}
+
+// SetEnabled switches subsystem; enabling processes or analytics deploys them the first time if Deploy is set
+func (this *Instance) SetEnabled(subsystem string, enabled bool) error {
+	if !IsSubsystem(subsystem) {
+		return errors.New("unknown subsystem " + subsystem)
+	}
+	if enabled && this.Deploy != nil && (subsystem == Processes || subsystem == Analytics) {
+		err := this.deploy(subsystem)
+		if err != nil {
+			return err
+		}
+	}
+	if enabled {
+		this.disabled.Delete(subsystem)
+	} else {
+		this.disabled.Store(subsystem, true)
+	}
+	return nil
+}
+
+// deploy calls Deploy once per subsystem; a failed deployment is not repeated
+func (this *Instance) deploy(subsystem string) error {
+	this.deployMux.Lock()
+	defer this.deployMux.Unlock()
+	if this.deployed[subsystem] {
+		return nil
+	}
+	if this.deployed == nil {
+		this.deployed = map[string]bool{}
+	}
+	this.deployed[subsystem] = true
+	return this.Deploy(subsystem)
+}
+
+// Disabled returns the disabled subsystems
+func (this *Instance) Disabled() (result []string) {
+	for _, subsystem := range Subsystems {
+		if !this.Enabled(subsystem) {
+			result = append(result, subsystem)
+		}
+	}
+	return result
+}
+
+func IsSubsystem(subsystem string) bool {
+	for _, s := range Subsystems {
+		if s == subsystem {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *Instance) Pause() {
+	atomic.StoreUint32(&this.paused, 1)
+}
+
+func (this *Instance) Resume() {
+	atomic.StoreUint32(&this.paused, 0)
+}
+
+func (this *Instance) Paused() bool {
+	return atomic.LoadUint32(&this.paused) == 1
+}
+
+// Factor returns the multiplier of the configured emitter frequency
+func (this *Instance) Factor() float64 {
+	bits := atomic.LoadUint64(&this.factor)
+	if bits == 0 {
+		return 1
+	}
+	return math.Float64frombits(bits)
+}
+
+func (this *Instance) SetFactor(factor float64) error {
+	if factor <= 0 || math.IsInf(factor, 0) || math.IsNaN(factor) {
+		return errors.New("factor must be a positive number")
+	}
+	atomic.StoreUint64(&this.factor, math.Float64bits(factor))
+	return nil
+}
+
+// SetInterval scales all service intervals by interval/emitter_interval
+func (this *Instance) SetInterval(interval time.Duration) error {
+	if this.BaseInterval <= 0 {
+		return ErrIntervalMode
+	}
+	if interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	return this.SetFactor(float64(this.BaseInterval) / float64(interval))
+}
+
+func (this *Instance) SetTargetRate(rate float64) error {
+	if this.BaseRate <= 0 {
+		return ErrRateMode
+	}
+	return this.SetFactor(rate / this.BaseRate)
+}
+
+func (this *Instance) AddDevices(count int) (devices int, err error) {
+	if this.ScaleUp == nil {
+		return 0, ErrScalingUnsupported
+	}
+	return this.ScaleUp(count)
+}
+
+func (this *Instance) RemoveDevices(count int, delete bool) (devices int, err error) {
+	if this.ScaleDown == nil {
+		return 0, ErrScalingUnsupported
+	}
+	return this.ScaleDown(count, delete)
+}
+
+type Status struct {
+	Hub        string            `json:"hub"`
+	HubId      string            `json:"hub_id"`
+	Connected  bool              `json:"connected"`
+	Paused     bool              `json:"paused"`
+	Disabled   []string          `json:"disabled,omitempty"`
+	Phase      string            `json:"phase,omitempty"`
+	Factor     float64           `json:"factor"`
+	Interval   string            `json:"interval,omitempty"`
+	TargetRate float64           `json:"target_rate,omitempty"`
+	Devices    int64             `json:"devices"`
+	Processes  int64             `json:"processes"`
+	Pipelines  int64             `json:"pipelines"`
+	Emitted    uint64            `json:"emitted"`
+	Produced   uint64            `json:"produced"`
+	Failed     uint64            `json:"failed"`
+	Missed     uint64            `json:"missed"`
+	Blocked    uint64            `json:"blocked"`
+	QueueDepth int               `json:"queue_depth"`
+	Errors     map[string]uint64 `json:"errors"`
+}
+
+func (this *Instance) Status() (result Status) {
+	result = Status{
+		Hub:      this.Name,
+		Paused:   this.Paused(),
+		Disabled: this.Disabled(),
+		Factor:   this.Factor(),
+	}
+	if this.HubId != nil {
+		result.HubId = this.HubId()
+	}
+	if this.BaseInterval > 0 {
+		result.Interval = time.Duration(float64(this.BaseInterval) / result.Factor).String()
+	}
+	if this.BaseRate > 0 {
+		result.TargetRate = this.BaseRate * result.Factor
+	}
+	if this.Metrics == nil {
+		return result
+	}
+	if this.Metrics.Connected != nil {
+		result.Connected = this.Metrics.Connected()
+	}
+	result.Devices = this.Metrics.Devices()
+	result.Processes = this.Metrics.Processes()
+	result.Pipelines = this.Metrics.Pipelines()
+	if this.Metrics.Stat != nil {
+		snapshot := this.Metrics.Stat.Snapshot()
+		for _, totals := range snapshot.Services {
+			result.Emitted = result.Emitted + totals.Emitted
+			result.Produced = result.Produced + totals.Produced
+			result.Failed = result.Failed + totals.Failed
+		}
+		result.Missed = snapshot.Missed
+		result.Blocked = snapshot.Blocked
+		result.QueueDepth = snapshot.QueueDepth
+		result.Errors = snapshot.Errors
+		result.Phase = snapshot.Phase
+	}
+	return result
+}
+
+var instancesMux sync.Mutex
+var instances = map[string]*Instance{}
+
+// Register makes instance controllable by the api; an instance with the same name is replaced (e.g. on restart)
+func Register(instance *Instance) *Instance {
+	instancesMux.Lock()
+	defer instancesMux.Unlock()
+	instances[instance.Name] = instance
+	return instance
+}
+
+func Unregister(name string) {
+	instancesMux.Lock()
+	defer instancesMux.Unlock()
+	delete(instances, name)
+}
+
+// Instances returns the registered instances sorted by name
+func Instances() (result []*Instance) {
+	instancesMux.Lock()
+	defer instancesMux.Unlock()
+	for _, instance := range instances {
+		result = append(result, instance)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+var shutdown = make(chan struct{})
+var shutdownOnce sync.Once
+
+// Shutdown requests the end of the run; the run ends like on a shutdown signal (cleanup, report)
+func Shutdown() {
+	shutdownOnce.Do(func() {
+		close(shutdown)
+	})
+}
+
+// ShutdownRequested is closed by Shutdown()
+func ShutdownRequested() <-chan struct{} {
+	return shutdown
+}
@@ -0,0 +1,34 @@
+package control
+
+import (
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/loadprofile"
+	"testing"
+)
+
+func TestProcessRate(t *testing.T) {
+	instance := &Instance{}
+	if rate := instance.ProcessRate(0.5); rate != 0.5 {
+		t.Error("expect the factor of the configured load profile, got", rate)
+	}
+	instance.SetProfile(loadprofile.Constant{Value: 2})
+	if rate := instance.ProcessRate(0.5); rate != 2 {
+		t.Error("expect the factor of the phase profile, got", rate)
+	}
+	instance.Pause()
+	if instance.Rate(0.5) != 0 || instance.ProcessRate(0.5) != 2 {
+		t.Error("expect a pause to stop the events only")
+	}
+	err := instance.SetEnabled(Processes, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate := instance.ProcessRate(0.5); rate != 0 {
+		t.Error("expect no processes while disabled, got", rate)
+	}
+	instance.SetProfile(nil)
+	instance.Resume()
+	_ = instance.SetEnabled(Processes, true)
+	if rate := instance.ProcessRate(0.5); rate != 0.5 {
+		t.Error("expect the configured load profile after the phase, got", rate)
+	}
+}
@@ -1,37 +1,242 @@
 package pkg
 
 import (
+	"bytes"
+	"errors"
+	"github.com/SENERGY-Platform/platform-connector-lib/iot"
 	"github.com/SENERGY-Platform/platform-connector-lib/security"
 	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
 	"github.com/SENERGY-Platform/senergy-platform-connector/test/client"
 	"log"
 	"net/url"
-	"strconv"
+	"text/template"
 )
 
 type Device struct {
 	LocalId string `json:"local_id"`
 }
 
-func GetDevices(config configuration.Config) (devices []client.DeviceRepresentation) {
-	prefix := config.HubPrefix
-	for i := 0; i < int(config.DeviceCount); i++ {
-		devices = append(devices, client.DeviceRepresentation{
-			IotType: config.DeviceType,
-			Uri:     prefix + "_" + strconv.Itoa(i),
-			Name:    prefix + "_" + strconv.Itoa(i),
-		})
+type FleetDevice struct {
+	client.DeviceRepresentation
+	FleetIndex int //index over all devices of the instance, starting at fleet_offset
+	GroupIndex int //index within the device group, starting at index_offset of the group
+	Group      configuration.DeviceGroup
+}
+
+type deviceNaming struct {
+	Prefix     string
+	Group      string
+	Index      int
+	FleetIndex int
+}
+
+// GetFleet returns the simulated devices of all configured device groups
+func GetFleet(config configuration.Config) (fleet []FleetDevice, err error) {
+	groups, err := config.GetDeviceGroups()
+	if err != nil {
+		return fleet, err
+	}
+	names := map[string]bool{}
+	for _, group := range groups {
+		for i := 0; i < int(group.Count); i++ {
+			device, err := newFleetDevice(config, group, int(group.IndexOffset)+i, int(config.FleetOffset)+len(fleet), names)
+			if err != nil {
+				return fleet, err
+			}
+			fleet = append(fleet, device)
+		}
+	}
+	return fleet, nil
+}
+
+// ExtendFleet returns count new devices which continue the naming of fleet;
+// each device is added to the group which is furthest below its share of the configured device groups (see groupShares)
+func ExtendFleet(config configuration.Config, fleet []FleetDevice, count int) (result []FleetDevice, err error) {
+	groups, err := config.GetDeviceGroups()
+	if err != nil {
+		return result, err
+	}
+	if len(groups) == 0 {
+		return result, errors.New("no device group to extend")
+	}
+	shares := groupShares(groups)
+	names := map[string]bool{}
+	groupCounts := map[string]int{}
+	nextIndex := map[string]int{}
+	for _, group := range groups {
+		nextIndex[group.Name] = int(group.IndexOffset)
+	}
+	fleetIndex := int(config.FleetOffset)
+	for _, d := range fleet {
+		names[d.Uri] = true
+		groupCounts[d.Group.Name]++
+		if d.GroupIndex >= nextIndex[d.Group.Name] {
+			nextIndex[d.Group.Name] = d.GroupIndex + 1
+		}
+		if d.FleetIndex >= fleetIndex {
+			fleetIndex = d.FleetIndex + 1
+		}
+	}
+	for i := 0; i < count; i++ {
+		total := float64(len(fleet) + i + 1)
+		best := 0
+		for j, group := range groups {
+			if shares[j]*total-float64(groupCounts[group.Name]) > shares[best]*total-float64(groupCounts[groups[best].Name]) {
+				best = j
+			}
+		}
+		group := groups[best]
+		device, err := newFleetDevice(config, group, nextIndex[group.Name], fleetIndex+i, names)
+		if err != nil {
+			return result, err
+		}
+		groupCounts[group.Name]++
+		nextIndex[group.Name]++
+		result = append(result, device)
+	}
+	return result, nil
+}
+
+// ShrinkFleet returns count devices of fleet which should be removed (see simulation.Remove);
+// each device is the last device of the group which is furthest above its share of the configured device groups (see groupShares).
+// devices of groups which are no longer configured are removed first
+func ShrinkFleet(config configuration.Config, fleet []FleetDevice, count int) (result []FleetDevice, err error) {
+	groups, err := config.GetDeviceGroups()
+	if err != nil {
+		return result, err
+	}
+	shares := map[string]float64{}
+	for i, share := range groupShares(groups) {
+		shares[groups[i].Name] = share
+	}
+	order := []string{}
+	members := map[string][]FleetDevice{}
+	for _, d := range fleet {
+		if _, ok := members[d.Group.Name]; !ok {
+			order = append(order, d.Group.Name)
+		}
+		members[d.Group.Name] = append(members[d.Group.Name], d)
+	}
+	for i := 0; i < count && i < len(fleet); i++ {
+		total := float64(len(fleet) - i - 1)
+		best := ""
+		bestExcess := 0.0
+		for _, name := range order {
+			if len(members[name]) == 0 {
+				continue
+			}
+			excess := float64(len(members[name])) - shares[name]*total
+			if best == "" || excess > bestExcess {
+				best = name
+				bestExcess = excess
+			}
+		}
+		last := len(members[best]) - 1
+		result = append(result, members[best][last])
+		members[best] = members[best][:last]
+	}
+	return result, nil
+}
+
+// groupShares returns the share of each group in the configured fleet; if no group has devices, all groups have the same share
+func groupShares(groups []configuration.DeviceGroup) (result []float64) {
+	total := int64(0)
+	for _, group := range groups {
+		total = total + group.Count
+	}
+	for _, group := range groups {
+		if total > 0 {
+			result = append(result, float64(group.Count)/float64(total))
+		} else {
+			result = append(result, 1/float64(len(groups)))
+		}
+	}
+	return result
+}
+
+// RestoreFleet recreates the devices of entries (see ClientInfo) with the configured device groups
+func RestoreFleet(config configuration.Config, entries []FleetEntry) (fleet []FleetDevice, err error) {
+	groups, err := config.GetDeviceGroups()
+	if err != nil {
+		return fleet, err
+	}
+	byName := map[string]configuration.DeviceGroup{}
+	for _, group := range groups {
+		byName[group.Name] = group
+	}
+	names := map[string]bool{}
+	for _, entry := range entries {
+		group, ok := byName[entry.Group]
+		if !ok {
+			return fleet, errors.New("stored device group " + entry.Group + " is not configured")
+		}
+		device, err := newFleetDevice(config, group, entry.GroupIndex, entry.FleetIndex, names)
+		if err != nil {
+			return fleet, err
+		}
+		fleet = append(fleet, device)
+	}
+	return fleet, nil
+}
+
+// FleetEntry identifies a device of the fleet in the client info (see RestoreFleet)
+type FleetEntry struct {
+	Group      string `json:"group"`
+	GroupIndex int    `json:"group_index"`
+	FleetIndex int    `json:"fleet_index"`
+}
+
+func GetFleetEntries(fleet []FleetDevice) (entries []FleetEntry) {
+	for _, d := range fleet {
+		entries = append(entries, FleetEntry{Group: d.Group.Name, GroupIndex: d.GroupIndex, FleetIndex: d.FleetIndex})
+	}
+	return
+}
+
+func newFleetDevice(config configuration.Config, group configuration.DeviceGroup, index int, fleetIndex int, names map[string]bool) (result FleetDevice, err error) {
+	naming, err := template.New(group.Name).Parse(group.Naming)
+	if err != nil {
+		return result, err
+	}
+	buf := bytes.Buffer{}
+	err = naming.Execute(&buf, deviceNaming{Prefix: config.HubPrefix, Group: group.Name, Index: index, FleetIndex: fleetIndex})
+	if err != nil {
+		return result, err
+	}
+	name := buf.String()
+	if names[name] {
+		return result, errors.New("duplicate device name " + name + "; check naming of device group " + group.Name)
+	}
+	names[name] = true
+	return FleetDevice{
+		DeviceRepresentation: client.DeviceRepresentation{
+			IotType: group.DeviceType,
+			Uri:     name,
+			Name:    name,
+		},
+		FleetIndex: fleetIndex,
+		GroupIndex: index,
+		Group:      group,
+	}, nil
+}
+
+func GetDevices(fleet []FleetDevice) (devices []client.DeviceRepresentation) {
+	for _, d := range fleet {
+		devices = append(devices, d.DeviceRepresentation)
 	}
 	return
 }
 
-func DeleteDevices(config configuration.Config, devices []client.DeviceRepresentation, token security.JwtToken) {
+func DeleteDevices(config configuration.Config, devices []client.DeviceRepresentation, token security.JwtToken) (deleted int) {
 	for _, d := range devices {
 		err := DeleteDevice(config, d.Uri, token)
 		if err != nil {
 			log.Println("ERROR: ", err)
+		} else {
+			deleted++
 		}
 	}
+	return deleted
 }
 
 func DeleteDevice(config configuration.Config, id string, token security.JwtToken) (err error) {
@@ -52,3 +257,92 @@ func GetHubDeviceIds(config configuration.Config, hubId string, token security.J
 	err = token.GetJSON(endpoint, &ids)
 	return
 }
+
+type SelectedDevice struct {
+	Id      string
+	LocalId string
+	Group   configuration.DeviceGroup
+}
+
+// SelectBatchSize limits the local ids of one permissions search query of GetDeviceIds
+const SelectBatchSize = 500
+
+// SelectDevices returns every n-th device of each group with its platform id; n is returned by every(group), n <= 0 selects no device of the group.
+// the ids are looked up in batches (see GetDeviceIds); devices which are not yet indexed by the permissions search are requested one by one
+func SelectDevices(config configuration.Config, fleet []FleetDevice, every func(group configuration.DeviceGroup) int64, token security.JwtToken) (result []SelectedDevice, err error) {
+	selected := []FleetDevice{}
+	localIds := []string{}
+	for _, d := range fleet {
+		n := every(d.Group)
+		if n <= 0 || int64(d.GroupIndex)%n != 0 {
+			continue
+		}
+		selected = append(selected, d)
+		localIds = append(localIds, d.Uri)
+	}
+	if len(selected) == 0 {
+		return result, nil
+	}
+	ids, err := GetDeviceIds(config, localIds, token)
+	if err != nil {
+		log.Println("WARNING: unable to search device ids; request devices one by one", err)
+		err = nil
+	}
+	iotClient := iot.New(config.DeviceManagerUrl, config.DeviceRepoUrl, "", "")
+	for _, d := range selected {
+		id, ok := ids[d.Uri]
+		if !ok {
+			device, err := iotClient.GetDeviceByLocalId(d.Uri, token)
+			if err != nil {
+				log.Println("ERROR: unable to find device", d.Uri, err)
+				return result, err
+			}
+			id = device.Id
+		}
+		result = append(result, SelectedDevice{Id: id, LocalId: d.Uri, Group: d.Group})
+	}
+	return result, nil
+}
+
+type searchDevice struct {
+	Id      string `json:"id"`
+	LocalId string `json:"local_id"`
+}
+
+// GetDeviceIds returns the platform ids of the devices with localIds by local id, searched with one permissions search query per SelectBatchSize local ids;
+// devices which are not (yet) indexed are missing in result
+func GetDeviceIds(config configuration.Config, localIds []string, token security.JwtToken) (result map[string]string, err error) {
+	result = map[string]string{}
+	for start := 0; start < len(localIds); start = start + SelectBatchSize {
+		end := start + SelectBatchSize
+		if end > len(localIds) {
+			end = len(localIds)
+		}
+		devices := []searchDevice{}
+		err, _ = QueryPermissionsSearch(config, token, QueryMessage{
+			Resource: "devices",
+			Find: &QueryFind{
+				QueryListCommons: QueryListCommons{
+					Limit:  end - start,
+					Rights: "r",
+				},
+				Filter: &Selection{
+					Condition: ConditionConfig{
+						Feature:   "features.local_id",
+						Operation: QueryAnyValueInFeatureOperation,
+						Value:     localIds[start:end],
+					},
+				},
+			},
+		}, &devices)
+		if err != nil {
+			return result, err
+		}
+		for _, device := range devices {
+			if device.LocalId != "" {
+				result[device.LocalId] = device.Id
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,227 @@
+package pkg
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSelectDevices(t *testing.T) {
+	var queries, lookups int64
+	search := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt64(&queries, 1)
+		query := struct {
+			Find struct {
+				Filter struct {
+					Condition struct {
+						Operation string   `json:"operation"`
+						Value     []string `json:"value"`
+					} `json:"condition"`
+				} `json:"filter"`
+			} `json:"find"`
+		}{}
+		err := json.NewDecoder(request.Body).Decode(&query)
+		if err != nil || query.Find.Filter.Condition.Operation != string(QueryAnyValueInFeatureOperation) {
+			http.Error(writer, "unexpected query", http.StatusBadRequest)
+			return
+		}
+		result := []searchDevice{}
+		for _, localId := range query.Find.Filter.Condition.Value {
+			result = append(result, searchDevice{Id: "id:" + localId, LocalId: localId})
+		}
+		json.NewEncoder(writer).Encode(result)
+	}))
+	defer search.Close()
+	manager := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt64(&lookups, 1)
+		localId := strings.TrimPrefix(request.URL.Path, "/local-devices/")
+		json.NewEncoder(writer).Encode(map[string]string{"id": "id:" + localId, "local_id": localId})
+	}))
+	defer manager.Close()
+
+	config := configuration.Config{
+		HubPrefix:           "hub",
+		PermissionsQueryUrl: search.URL,
+		DeviceManagerUrl:    manager.URL,
+		DeviceGroups: []configuration.DeviceGroup{
+			{Name: "sensors", DeviceType: "sensor", Count: 5, OneProcessEveryNDevices: 2},
+			{Name: "lamps", DeviceType: "lamp", Count: 3, OneProcessEveryNDevices: -1},
+		},
+	}
+	fleet, err := GetFleet(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selected, err := SelectDevices(config, fleet, func(group configuration.DeviceGroup) int64 {
+		return group.OneProcessEveryNDevices
+	}, "Bearer test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"hub_sensors_0", "hub_sensors_2", "hub_sensors_4"}
+	if len(selected) != len(expect) {
+		t.Fatalf("expect %v, got %#v", expect, selected)
+	}
+	for i, device := range selected {
+		if device.LocalId != expect[i] || device.Id != "id:"+expect[i] || device.Group.Name != "sensors" {
+			t.Errorf("expect %v, got %#v", expect[i], device)
+		}
+	}
+	if queries != 1 {
+		t.Error("expect one search query, got", queries)
+	}
+	if lookups != 0 {
+		t.Error("expect no single device lookup for indexed devices, got", lookups)
+	}
+}
+
+func TestSelectDevicesNotIndexed(t *testing.T) {
+	search := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		json.NewEncoder(writer).Encode([]searchDevice{})
+	}))
+	defer search.Close()
+	var lookups int64
+	manager := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt64(&lookups, 1)
+		localId := strings.TrimPrefix(request.URL.Path, "/local-devices/")
+		json.NewEncoder(writer).Encode(map[string]string{"id": "id:" + localId, "local_id": localId})
+	}))
+	defer manager.Close()
+
+	config := configuration.Config{
+		HubPrefix:           "hub",
+		PermissionsQueryUrl: search.URL,
+		DeviceManagerUrl:    manager.URL,
+		DeviceType:          "sensor",
+		DeviceCount:         4,
+	}
+	fleet, err := GetFleet(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selected, err := SelectDevices(config, fleet, func(group configuration.DeviceGroup) int64 {
+		return 1
+	}, "Bearer test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 4 || selected[3].Id != "id:hub_3" {
+		t.Errorf("unexpected selection %#v", selected)
+	}
+	if lookups != 4 {
+		t.Error("expect a single device lookup per device which is not indexed, got", lookups)
+	}
+}
+
+func groupSizes(fleet []FleetDevice) map[string]int {
+	result := map[string]int{}
+	for _, d := range fleet {
+		result[d.Group.Name]++
+	}
+	return result
+}
+
+func TestExtendFleet(t *testing.T) {
+	config := configuration.Config{
+		HubPrefix: "hub",
+		DeviceGroups: []configuration.DeviceGroup{
+			{Name: "sensors", DeviceType: "sensor", Count: 6},
+			{Name: "lamps", DeviceType: "lamp", Count: 2},
+		},
+	}
+	fleet, err := GetFleet(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := ExtendFleet(config, fleet, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := groupSizes(append(fleet, added...))
+	if sizes["sensors"] != 9 || sizes["lamps"] != 3 {
+		t.Errorf("expect the group proportions to be kept, got %v", sizes)
+	}
+	for i, d := range added {
+		if d.FleetIndex != len(fleet)+i {
+			t.Errorf("expect fleet index %v, got %#v", len(fleet)+i, d)
+		}
+	}
+	if added[0].Uri != "hub_sensors_6" {
+		t.Error("expect the naming of the group to be continued, got", added[0].Uri)
+	}
+}
+
+func TestShrinkFleet(t *testing.T) {
+	config := configuration.Config{
+		HubPrefix: "hub",
+		DeviceGroups: []configuration.DeviceGroup{
+			{Name: "sensors", DeviceType: "sensor", Count: 6},
+			{Name: "lamps", DeviceType: "lamp", Count: 2},
+		},
+	}
+	fleet, err := GetFleet(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := ShrinkFleet(config, fleet, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := groupSizes(removed)
+	if sizes["sensors"] != 3 || sizes["lamps"] != 1 {
+		t.Errorf("expect the group proportions to be kept, got %v", sizes)
+	}
+	if removed[0].Uri != "hub_sensors_5" {
+		t.Error("expect the last device of a group to be removed first, got", removed[0].Uri)
+	}
+
+	//devices of groups which are no longer configured are removed first
+	config.DeviceGroups = config.DeviceGroups[:1]
+	removed, err = ShrinkFleet(config, fleet, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupSizes(removed)["lamps"] != 2 {
+		t.Errorf("expect the devices of the removed group, got %#v", removed)
+	}
+}
+
+func TestRestoreFleet(t *testing.T) {
+	config := configuration.Config{
+		HubPrefix: "hub",
+		DeviceGroups: []configuration.DeviceGroup{
+			{Name: "sensors", DeviceType: "sensor", Count: 2},
+			{Name: "lamps", DeviceType: "lamp", Count: 1},
+		},
+	}
+	fleet, err := GetFleet(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := ExtendFleet(config, fleet, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fleet = append(fleet, added...)
+	restored, err := RestoreFleet(config, GetFleetEntries(fleet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != len(fleet) {
+		t.Fatalf("expect %v devices, got %v", len(fleet), len(restored))
+	}
+	for i, d := range restored {
+		if d.Uri != fleet[i].Uri || d.IotType != fleet[i].IotType || d.FleetIndex != fleet[i].FleetIndex {
+			t.Errorf("expect %#v, got %#v", fleet[i], d)
+		}
+	}
+	config.DeviceGroups = config.DeviceGroups[:1]
+	_, err = RestoreFleet(config, GetFleetEntries(fleet))
+	if err == nil {
+		t.Error("expect error for a stored group which is not configured")
+	}
+}
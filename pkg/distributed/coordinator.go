@@ -0,0 +1,396 @@
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/api"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/report"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/sinks"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ShutdownTimeout = 10 * time.Second
+
+// Coordinator assigns the fleet to config.Workers workers, starts them in sync and combines their statistics and reports.
+// its api is restricted like the control api (see api.Authorize); workers send the shared api_token
+type Coordinator struct {
+	config          configuration.Config
+	assignments     []Assignment
+	startDelay      time.Duration
+	registerTimeout time.Duration
+	reportTimeout   time.Duration
+	sinks           *sinks.Sinks
+
+	mux        sync.Mutex
+	workers    []Worker
+	registered chan struct{} //closed when all workers are registered
+	ready      map[int]bool
+	startAt    time.Time
+	started    chan struct{} //closed when all workers are ready and startAt is set
+	reports    map[int]report.Report
+	reported   chan struct{} //closed with the first report
+	complete   chan struct{} //closed when all reports are received
+	latest     map[string]statistics.IntervalSummary
+}
+
+func NewCoordinator(config configuration.Config) (result *Coordinator, err error) {
+	if config.Workers <= 0 {
+		return nil, errors.New("coordinator needs workers > 0")
+	}
+	if config.HttpPort == "" || config.HttpPort == "-" {
+		return nil, errors.New("coordinator needs a http_port")
+	}
+	result = &Coordinator{
+		config:     config,
+		registered: make(chan struct{}),
+		ready:      map[int]bool{},
+		started:    make(chan struct{}),
+		reports:    map[int]report.Report{},
+		reported:   make(chan struct{}),
+		complete:   make(chan struct{}),
+		latest:     map[string]statistics.IntervalSummary{},
+	}
+	result.startDelay, err = optionalDuration(config.StartDelay, DefaultStartDelay)
+	if err != nil {
+		return nil, errors.New("invalid start_delay: " + err.Error())
+	}
+	result.registerTimeout, err = optionalDuration(config.RegisterTimeout, DefaultRegisterTimeout)
+	if err == nil && result.registerTimeout <= 0 {
+		err = errors.New("expect positive timeout")
+	}
+	if err != nil {
+		return nil, errors.New("invalid register_timeout: " + err.Error())
+	}
+	result.reportTimeout, err = optionalDuration(config.ReportTimeout, DefaultReportTimeout)
+	if err == nil && result.reportTimeout <= 0 {
+		err = errors.New("expect positive timeout")
+	}
+	if err != nil {
+		return nil, errors.New("invalid report_timeout: " + err.Error())
+	}
+	result.assignments, err = Assign(config, int(config.Workers))
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Run serves the coordinator api until all workers sent their report (see await)
+func (this *Coordinator) Run(stop context.Context) (result report.Report, err error) {
+	this.sinks, err = sinks.Open(this.config.StatisticsSinks)
+	if err != nil {
+		return result, err
+	}
+	defer this.sinks.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	defer cancel()
+	if this.config.ApiToken == "" {
+		log.Println("INFO: no api_token; the coordinator api only accepts workers on localhost")
+	}
+	err = api.Serve(ctx, wg, this.config.HttpPort, this.Handler())
+	if err != nil {
+		return result, err
+	}
+	this.logCombined(ctx)
+	return this.await(stop)
+}
+
+// await waits up to register_timeout until all workers are registered and ready and then for their reports;
+// after the first report or, if stop is done, after forwarding the shutdown to the workers the remaining reports are awaited for report_timeout.
+// the returned report combines all received reports
+func (this *Coordinator) await(stop context.Context) (result report.Report, err error) {
+	log.Println("INFO: coordinator waits for", this.config.Workers, "workers")
+	select {
+	case <-this.started:
+	case <-time.After(this.registerTimeout):
+		registered, ready := this.counts()
+		this.shutdownWorkers()
+		return result, errors.New(strconv.Itoa(registered) + " workers registered and " + strconv.Itoa(ready) + " ready after " + this.registerTimeout.String() + "; expected " + strconv.Itoa(len(this.assignments)))
+	case <-stop.Done():
+		this.shutdownWorkers()
+		return result, errors.New("stopped before the start of the workers")
+	}
+	select {
+	case <-this.complete:
+		return this.Report(), nil
+	case <-this.reported:
+	case <-stop.Done():
+		log.Println("INFO: forward shutdown to workers")
+		this.shutdownWorkers()
+	}
+	select {
+	case <-this.complete:
+	case <-time.After(this.reportTimeout):
+		log.Println("WARNING: missing reports of workers after", this.reportTimeout.String()+"; combine received reports")
+	}
+	return this.Report(), nil
+}
+
+func (this *Coordinator) counts() (registered int, ready int) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return len(this.workers), len(this.ready)
+}
+
+// Handler returns the coordinator api restricted by api.Authorize
+func (this *Coordinator) Handler() http.Handler {
+	return api.Authorize(this.config.ApiToken, this.Router())
+}
+
+func (this *Coordinator) Router() *http.ServeMux {
+	router := http.NewServeMux()
+	router.HandleFunc("/coordinator/workers", this.register)
+	router.HandleFunc("/coordinator/workers/", this.worker)
+	router.HandleFunc("/coordinator/statistics", this.statistics)
+	return router
+}
+
+// register handles POST /coordinator/workers
+func (this *Coordinator) register(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	worker := Worker{}
+	err := json.NewDecoder(request.Body).Decode(&worker)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if len(this.workers) >= len(this.assignments) {
+		http.Error(writer, "all workers registered", http.StatusConflict)
+		return
+	}
+	id := len(this.workers)
+	this.workers = append(this.workers, worker)
+	log.Println("INFO: registered worker", id, worker.Name, worker.Url)
+	if len(this.workers) == len(this.assignments) {
+		log.Println("INFO: all workers registered")
+		close(this.registered)
+	}
+	respond(writer, Registration{Id: id})
+}
+
+// worker handles GET /coordinator/workers/{id}/assignment, POST /coordinator/workers/{id}/ready, GET /coordinator/workers/{id}/start
+// and POST /coordinator/workers/{id}/report; assignment and start respond with 204 until all workers are registered or ready
+func (this *Coordinator) worker(writer http.ResponseWriter, request *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(request.URL.Path, "/coordinator/workers/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(writer, request)
+		return
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id < 0 || id >= len(this.assignments) {
+		http.Error(writer, "unknown worker", http.StatusNotFound)
+		return
+	}
+	switch {
+	case parts[1] == "assignment" && request.Method == http.MethodGet:
+		select {
+		case <-this.registered:
+		default:
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+		respond(writer, this.assignments[id])
+	case parts[1] == "ready" && request.Method == http.MethodPost:
+		this.setReady(id)
+		writer.WriteHeader(http.StatusOK)
+	case parts[1] == "start" && request.Method == http.MethodGet:
+		select {
+		case <-this.started:
+		default:
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+		respond(writer, Start{StartIn: time.Until(this.startAt).Milliseconds()})
+	case parts[1] == "report" && request.Method == http.MethodPost:
+		result := report.Report{}
+		err = json.NewDecoder(request.Body).Decode(&result)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		this.addReport(id, result)
+		writer.WriteHeader(http.StatusOK)
+	default:
+		http.Error(writer, "not found", http.StatusNotFound)
+	}
+}
+
+// setReady records a started worker; when all workers are ready, the start time is set to now + start_delay
+func (this *Coordinator) setReady(id int) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.ready[id] {
+		return
+	}
+	this.ready[id] = true
+	log.Println("INFO: worker", id, "ready (", len(this.ready), "/", len(this.assignments), ")")
+	if len(this.ready) == len(this.assignments) {
+		this.startAt = time.Now().Add(this.startDelay)
+		log.Println("INFO: all workers ready; start at", this.startAt.Format(time.RFC3339))
+		close(this.started)
+	}
+}
+
+func (this *Coordinator) addReport(id int, result report.Report) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if _, ok := this.reports[id]; ok {
+		return
+	}
+	this.reports[id] = result
+	log.Println("INFO: received report of worker", id, "(", len(this.reports), "/", len(this.assignments), ")")
+	if len(this.reports) == 1 {
+		close(this.reported)
+	}
+	if len(this.reports) == len(this.assignments) {
+		close(this.complete)
+	}
+}
+
+// statistics handles POST /coordinator/statistics with intervals of the workers (see sinks.HttpType)
+func (this *Coordinator) statistics(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	line := sinks.Line{}
+	err := json.NewDecoder(request.Body).Decode(&line)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, sink := range this.sinks.For(line.Hub) {
+		err = sink.Write(line.IntervalSummary)
+		if err != nil {
+			log.Println("WARNING: unable to write worker statistics to sink", err)
+		}
+	}
+	this.mux.Lock()
+	this.latest[line.Hub] = line.IntervalSummary
+	this.mux.Unlock()
+	writer.WriteHeader(http.StatusOK)
+}
+
+// logCombined logs the sum of the latest intervals of all hubs every statistics_interval
+func (this *Coordinator) logCombined(ctx context.Context) {
+	interval, err := time.ParseDuration(this.config.StatisticsInterval)
+	if err != nil || interval <= 0 {
+		return
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				this.mux.Lock()
+				hubs := len(this.latest)
+				combined := statistics.IntervalSummary{}
+				for _, summary := range this.latest {
+					combined.Emitted = combined.Emitted + summary.Emitted
+					combined.Produced = combined.Produced + summary.Produced
+					combined.Failed = combined.Failed + summary.Failed
+					combined.Missed = combined.Missed + summary.Missed
+					combined.Throughput = combined.Throughput + summary.Throughput
+				}
+				this.mux.Unlock()
+				if hubs > 0 {
+					log.Println("LOG: combined latest interval of", hubs, "hubs:", "emitted=", combined.Emitted, "produced=", combined.Produced, "failed=", combined.Failed, "missed=", combined.Missed, "throughput=", strconv.FormatFloat(combined.Throughput, 'f', 2, 64), "events/s")
+				}
+			}
+		}
+	}()
+}
+
+func (this *Coordinator) shutdownWorkers() {
+	this.mux.Lock()
+	workers := append([]Worker{}, this.workers...)
+	this.mux.Unlock()
+	client := &http.Client{Timeout: ShutdownTimeout}
+	for _, worker := range workers {
+		if worker.Url == "" {
+			continue
+		}
+		_, err := send(client, http.MethodPost, worker.Url+"/shutdown", this.config.ApiToken, nil, nil)
+		if err != nil {
+			log.Println("WARNING: unable to shutdown worker", worker.Name, err)
+		}
+	}
+}
+
+// Report combines the received reports of the workers
+func (this *Coordinator) Report() (result report.Report) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result = report.Report{
+		RunId:     this.config.RunId,
+		Seed:      this.config.Seed,
+		Config:    report.Redact(this.config),
+		Instances: []report.Instance{},
+	}
+	ids := []int{}
+	for id := range this.reports {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	hubs := []string{}
+	hubRuns := []statistics.RunSummary{}
+	workerRuns := []statistics.RunSummary{}
+	for _, id := range ids {
+		worker := this.reports[id]
+		if result.Start.IsZero() || worker.Start.Before(result.Start) {
+			result.Start = worker.Start
+		}
+		if worker.End.After(result.End) {
+			result.End = worker.End
+		}
+		//the run of a worker contains the histograms of all its instances
+		workerRuns = append(workerRuns, worker.Run)
+		for _, instance := range worker.Instances {
+			result.Instances = append(result.Instances, instance)
+			if instance.Run.Start.IsZero() {
+				continue //failed before its statistics were registered (see report.New)
+			}
+			hubs = append(hubs, instance.Hub)
+			hubRuns = append(hubRuns, instance.Run)
+		}
+	}
+	result.DurationSeconds = result.End.Sub(result.Start).Seconds()
+	result.Run = statistics.Combine(workerRuns)
+	result.Run.Hubs = statistics.HubBreakdown(hubs, hubRuns)
+	return result
+}
+
+// optionalDuration parses value; empty or - results in the default
+func optionalDuration(value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" || value == "-" {
+		return defaultValue, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func respond(writer http.ResponseWriter, result interface{}) {
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	err := json.NewEncoder(writer).Encode(result)
+	if err != nil {
+		log.Println("WARNING: unable to encode coordinator response", err)
+	}
+}
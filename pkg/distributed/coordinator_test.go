@@ -0,0 +1,203 @@
+package distributed
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/report"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/sinks"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testToken = "secret"
+
+// testWorker is the api of a worker which records the shutdowns forwarded by the coordinator
+type testWorker struct {
+	*httptest.Server
+	mux       sync.Mutex
+	shutdowns int
+	shutdown  chan struct{}
+}
+
+func newTestWorker(t *testing.T) *testWorker {
+	worker := &testWorker{shutdown: make(chan struct{})}
+	worker.Server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/shutdown" || request.Header.Get("Authorization") != "Bearer "+testToken {
+			t.Error("unexpected worker request", request.URL.Path, request.Header.Get("Authorization"))
+			return
+		}
+		worker.mux.Lock()
+		defer worker.mux.Unlock()
+		worker.shutdowns++
+		if worker.shutdowns == 1 {
+			close(worker.shutdown)
+		}
+	}))
+	return worker
+}
+
+func (this *testWorker) count() int {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.shutdowns
+}
+
+func newTestCoordinator(t *testing.T, config configuration.Config) (*Coordinator, *httptest.Server) {
+	t.Helper()
+	coordinator, err := NewCoordinator(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coordinator.sinks, err = sinks.Open(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return coordinator, httptest.NewServer(coordinator.Handler())
+}
+
+func testConfig() configuration.Config {
+	return configuration.Config{
+		HubPrefix:     "hub",
+		DeviceType:    "sensor",
+		DeviceCount:   4,
+		Workers:       2,
+		HttpPort:      "8080",
+		ApiToken:      testToken,
+		StartDelay:    "10ms",
+		ReportTimeout: "10s",
+	}
+}
+
+// workerReport returns a report whose produce times are count times latency
+func workerReport(hub string, latency time.Duration, count int) report.Report {
+	histograms := statistics.NewRunHistograms()
+	for i := 0; i < count; i++ {
+		histograms.ProduceTime.Record(latency)
+	}
+	run := statistics.RunSummary{Produced: uint64(count), ProduceTime: histograms.ProduceTime.Summary(), Histograms: histograms}
+	return report.Report{Run: run, Instances: []report.Instance{{Hub: hub, Run: run}}}
+}
+
+// work joins the coordinator, waits for the start and submits result after stop is closed (nil: immediately)
+func work(t *testing.T, wg *sync.WaitGroup, coordinator string, worker *testWorker, stop chan struct{}, result report.Report) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		config, id, err := Join(context.Background(), configuration.Config{CoordinatorUrl: coordinator, WorkerUrl: worker.URL, ApiToken: testToken})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if config.DeviceCount != 2 || len(config.StatisticsSinks) != 1 || config.StatisticsSinks[0].Token != testToken {
+			t.Errorf("unexpected assignment %#v", config)
+		}
+		startAt, err := Ready(context.Background(), config, id)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if time.Until(startAt) > time.Second {
+			t.Error("unexpected start time", startAt)
+		}
+		if stop != nil {
+			<-stop
+		}
+		err = Submit(config, id, result)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+}
+
+func TestCoordinator(t *testing.T) {
+	coordinator, server := newTestCoordinator(t, testConfig())
+	defer server.Close()
+	a, b := newTestWorker(t), newTestWorker(t)
+	defer a.Close()
+	defer b.Close()
+
+	wg := &sync.WaitGroup{}
+	work(t, wg, server.URL, a, nil, workerReport("hub_1", time.Millisecond, 100))
+	work(t, wg, server.URL, b, nil, workerReport("hub_2", 100*time.Millisecond, 100))
+	result, err := coordinator.await(context.Background())
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Instances) != 2 || result.Run.Produced != 200 || result.Run.ProduceTime.Count != 200 {
+		t.Fatalf("unexpected combined report %#v", result.Run)
+	}
+	//percentiles of the merged histograms, not the maximum of the workers
+	if result.Run.ProduceTime.P50 > 2*time.Millisecond {
+		t.Error("expect p50 of the merged histograms, got", result.Run.ProduceTime.P50)
+	}
+	if result.Run.ProduceTime.P99 < 99*time.Millisecond {
+		t.Error("expect p99 of the merged histograms, got", result.Run.ProduceTime.P99)
+	}
+	if result.Run.Histograms != nil {
+		t.Error("expect no histograms in the combined report")
+	}
+	if a.count() != 0 || b.count() != 0 {
+		t.Error("expect no shutdown of finished workers")
+	}
+}
+
+func TestCoordinatorForwardsShutdown(t *testing.T) {
+	coordinator, server := newTestCoordinator(t, testConfig())
+	defer server.Close()
+	a, b := newTestWorker(t), newTestWorker(t)
+	defer a.Close()
+	defer b.Close()
+
+	wg := &sync.WaitGroup{}
+	work(t, wg, server.URL, a, a.shutdown, workerReport("hub_1", time.Millisecond, 1))
+	work(t, wg, server.URL, b, b.shutdown, workerReport("hub_2", time.Millisecond, 1))
+	stop, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-coordinator.started
+		cancel()
+	}()
+	result, err := coordinator.await(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Instances) != 2 || a.count() != 1 || b.count() != 1 {
+		t.Errorf("expect the reports of both workers after the shutdown, got %v reports and %v/%v shutdowns", len(result.Instances), a.count(), b.count())
+	}
+}
+
+func TestCoordinatorRegisterTimeout(t *testing.T) {
+	config := testConfig()
+	config.RegisterTimeout = "100ms"
+	coordinator, server := newTestCoordinator(t, config)
+	defer server.Close()
+	a := newTestWorker(t)
+	defer a.Close()
+
+	registration := Registration{}
+	_, err := send(http.DefaultClient, http.MethodPost, server.URL+"/coordinator/workers", testToken, Worker{Url: a.URL}, &registration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = coordinator.await(context.Background())
+	if err == nil {
+		t.Error("expect error if not all workers register in time")
+	}
+	if a.count() != 1 {
+		t.Error("expect shutdown of the registered worker")
+	}
+}
+
+func TestCoordinatorAuth(t *testing.T) {
+	_, server := newTestCoordinator(t, testConfig())
+	defer server.Close()
+	status, err := send(http.DefaultClient, http.MethodPost, server.URL+"/coordinator/workers", "wrong", Worker{}, nil)
+	if err == nil || status != http.StatusUnauthorized {
+		t.Error("expect 401 for a wrong token, got", status, err)
+	}
+}
@@ -0,0 +1,129 @@
+package distributed
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"strconv"
+	"time"
+)
+
+const (
+	CoordinatorMode = "coordinator"
+	WorkerMode      = "worker"
+)
+
+const DefaultStartDelay = 10 * time.Second
+
+// DefaultRegisterTimeout limits the wait for the registration and the readiness of all workers (see configuration.Config.RegisterTimeout)
+const DefaultRegisterTimeout = 10 * time.Minute
+
+// DefaultReportTimeout limits the wait for the remaining reports after the first report or a forwarded shutdown (see configuration.Config.ReportTimeout)
+const DefaultReportTimeout = 2 * time.Minute
+
+// Worker is a registered worker; Url is its api (see pkg/api) which receives the shutdown of the coordinator
+type Worker struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+type Registration struct {
+	Id int `json:"id"`
+}
+
+// Assignment is the share of the fleet of a worker
+type Assignment struct {
+	Worker           int                         `json:"worker"`
+	HubPrefix        string                      `json:"hub_prefix"`
+	DeviceCount      int64                       `json:"device_count"`
+	DeviceGroups     []configuration.DeviceGroup `json:"device_groups"`
+	FleetOffset      int64                       `json:"fleet_offset"`
+	TargetRate       float64                     `json:"target_rate"`
+	Seed             int64                       `json:"seed"`
+	RunId            string                      `json:"run_id"`
+	Duration         string                      `json:"duration"`
+	MaxEvents        int64                       `json:"max_events"`
+	MaxProcessStarts int64                       `json:"max_process_starts"`
+}
+
+// Start is the start time of the workers, which is sent when all workers are ready
+type Start struct {
+	StartIn int64 `json:"start_in_ms"` //relative to the response to be independent of clock offsets
+}
+
+// Apply replaces the fleet, rate and run settings of config with the assignment
+func (this Assignment) Apply(config configuration.Config) configuration.Config {
+	config.HubPrefix = this.HubPrefix
+	config.DeviceCount = this.DeviceCount
+	config.DeviceGroups = this.DeviceGroups
+	config.FleetOffset = this.FleetOffset
+	config.TargetRate = this.TargetRate
+	config.Seed = this.Seed
+	config.RunId = this.RunId
+	config.Duration = this.Duration
+	config.MaxEvents = this.MaxEvents
+	config.MaxProcessStarts = this.MaxProcessStarts
+	return config
+}
+
+// Assign splits the device groups of config into continuous index ranges of workers;
+// each worker gets its own hub prefix (<hub_prefix>_<worker+1>), a target rate proportional to its devices and an equal share of the budgets
+func Assign(config configuration.Config, workers int) (result []Assignment, err error) {
+	if workers <= 0 {
+		return result, errors.New("workers must be positive")
+	}
+	//a share of 0 would be unlimited
+	if config.MaxEvents > 0 && config.MaxEvents < int64(workers) {
+		return result, errors.New("max_events must not be less than the number of workers")
+	}
+	if config.MaxProcessStarts > 0 && config.MaxProcessStarts < int64(workers) {
+		return result, errors.New("max_process_starts must not be less than the number of workers")
+	}
+	groups, err := config.GetDeviceGroups()
+	if err != nil {
+		return result, err
+	}
+	total := int64(0)
+	for _, group := range groups {
+		total = total + group.Count
+	}
+	offsets := make([]int64, len(groups))
+	fleetOffset := config.FleetOffset
+	for w := 0; w < workers; w++ {
+		assignment := Assignment{
+			Worker:           w,
+			HubPrefix:        config.HubPrefix + "_" + strconv.Itoa(w+1),
+			FleetOffset:      fleetOffset,
+			Seed:             config.Seed,
+			RunId:            config.RunId,
+			Duration:         config.Duration,
+			MaxEvents:        share(config.MaxEvents, w, workers),
+			MaxProcessStarts: share(config.MaxProcessStarts, w, workers),
+		}
+		for i, group := range groups {
+			count := share(group.Count, w, workers)
+			group.Count = count
+			group.Percentage = 0
+			group.IndexOffset = group.IndexOffset + offsets[i]
+			offsets[i] = offsets[i] + count
+			assignment.DeviceCount = assignment.DeviceCount + count
+			assignment.DeviceGroups = append(assignment.DeviceGroups, group)
+		}
+		if total > 0 {
+			assignment.TargetRate = config.TargetRate * float64(assignment.DeviceCount) / float64(total)
+		} else {
+			assignment.TargetRate = config.TargetRate / float64(workers)
+		}
+		fleetOffset = fleetOffset + assignment.DeviceCount
+		result = append(result, assignment)
+	}
+	return result, nil
+}
+
+// share returns the part of count of worker w; the remainder is distributed over the first workers
+func share(count int64, w int, workers int) int64 {
+	result := count / int64(workers)
+	if int64(w) < count%int64(workers) {
+		result++
+	}
+	return result
+}
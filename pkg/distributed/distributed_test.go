@@ -0,0 +1,41 @@
+package distributed
+
+import (
+	"testing"
+)
+
+func TestAssignBudgets(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int64
+		workers int
+		shares  []int64
+	}{
+		{"unlimited", 0, 3, []int64{0, 0, 0}},
+		{"even", 9, 3, []int64{3, 3, 3}},
+		{"remainder", 10, 3, []int64{4, 3, 3}},
+		{"one each", 3, 3, []int64{1, 1, 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := testConfig()
+			config.MaxEvents = test.limit
+			config.MaxProcessStarts = test.limit
+			assignments, err := Assign(config, test.workers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, assignment := range assignments {
+				if assignment.MaxEvents != test.shares[i] || assignment.MaxProcessStarts != test.shares[i] {
+					t.Errorf("expect budget %v of worker %v, got %v/%v", test.shares[i], i, assignment.MaxEvents, assignment.MaxProcessStarts)
+				}
+			}
+		})
+	}
+	config := testConfig()
+	config.MaxEvents = 2
+	_, err := Assign(config, 3)
+	if err == nil {
+		t.Error("expect error for a budget less than the number of workers")
+	}
+}
@@ -0,0 +1,132 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/report"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/sinks"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PollInterval is the wait between two requests of a worker for its assignment
+const PollInterval = time.Second
+
+const RequestTimeout = 30 * time.Second
+
+// Join registers the worker at config.CoordinatorUrl and waits for its assignment;
+// the returned config contains the assignment and a statistics sink which sends the intervals to the coordinator.
+// all requests to the coordinator send the api_token, which is shared by coordinator and workers
+func Join(ctx context.Context, config configuration.Config) (result configuration.Config, id int, err error) {
+	coordinator := strings.TrimSuffix(config.CoordinatorUrl, "/")
+	if coordinator == "" {
+		return result, id, errors.New("worker needs a coordinator_url")
+	}
+	worker := Worker{Url: config.WorkerUrl}
+	worker.Name, _ = os.Hostname()
+	if worker.Url == "" && config.HttpPort != "" && config.HttpPort != "-" {
+		worker.Url = "http://" + worker.Name + ":" + config.HttpPort
+	}
+	client := &http.Client{Timeout: RequestTimeout}
+	registration := Registration{}
+	_, err = send(client, http.MethodPost, coordinator+"/coordinator/workers", config.ApiToken, worker, &registration)
+	if err != nil {
+		return result, id, err
+	}
+	id = registration.Id
+	log.Println("INFO: registered as worker", id, "at", coordinator)
+	assignment := Assignment{}
+	err = poll(ctx, client, coordinator+"/coordinator/workers/"+strconv.Itoa(id)+"/assignment", config.ApiToken, &assignment)
+	if err != nil {
+		return result, id, err
+	}
+	result = assignment.Apply(config)
+	result.StatisticsSinks = append(append([]sinks.Config{}, config.StatisticsSinks...), sinks.Config{Type: sinks.HttpType, Location: coordinator + "/coordinator/statistics", Token: config.ApiToken})
+	log.Println("INFO: assigned", result.DeviceCount, "devices as", result.HubPrefix, "starting at fleet index", result.FleetOffset)
+	return result, id, nil
+}
+
+// Ready reports the started worker id to the coordinator and waits for the start time, which is sent when all workers are ready
+func Ready(ctx context.Context, config configuration.Config, id int) (startAt time.Time, err error) {
+	coordinator := strings.TrimSuffix(config.CoordinatorUrl, "/")
+	client := &http.Client{Timeout: RequestTimeout}
+	_, err = send(client, http.MethodPost, coordinator+"/coordinator/workers/"+strconv.Itoa(id)+"/ready", config.ApiToken, nil, nil)
+	if err != nil {
+		return startAt, err
+	}
+	start := Start{}
+	err = poll(ctx, client, coordinator+"/coordinator/workers/"+strconv.Itoa(id)+"/start", config.ApiToken, &start)
+	if err != nil {
+		return startAt, err
+	}
+	startAt = time.Now().Add(time.Duration(start.StartIn) * time.Millisecond)
+	log.Println("INFO: all workers ready; start at", startAt.Format(time.RFC3339))
+	return startAt, nil
+}
+
+// Submit sends the report of worker id to the coordinator
+func Submit(config configuration.Config, id int, result report.Report) error {
+	coordinator := strings.TrimSuffix(config.CoordinatorUrl, "/")
+	_, err := send(&http.Client{Timeout: RequestTimeout}, http.MethodPost, coordinator+"/coordinator/workers/"+strconv.Itoa(id)+"/report", config.ApiToken, result, nil)
+	return err
+}
+
+// poll requests url every PollInterval until the coordinator responds with a result instead of 204
+func poll(ctx context.Context, client *http.Client, url string, token string, result interface{}) error {
+	for {
+		status, err := send(client, http.MethodGet, url, token, nil, result)
+		if err != nil {
+			return err
+		}
+		if status != http.StatusNoContent {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(PollInterval):
+		}
+	}
+}
+
+// send requests url with body as json (if not nil) and the api token; the response is decoded into result unless its status is 204
+func send(client *http.Client, method string, url string, token string, body interface{}, result interface{}) (status int, err error) {
+	var payload io.Reader
+	if body != nil {
+		temp, err := json.Marshal(body)
+		if err != nil {
+			return status, err
+		}
+		payload = bytes.NewBuffer(temp)
+	}
+	request, err := http.NewRequest(method, url, payload)
+	if err != nil {
+		return status, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return status, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		temp, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, errors.New("unexpected response of " + url + ": " + resp.Status + ": " + string(temp))
+	}
+	if result != nil && resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(result)
+	}
+	return resp.StatusCode, err
+}
@@ -0,0 +1,135 @@
+package distribution
+
+import (
+	"bufio"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Distribution returns the time until the next arrival for a given mean inter-arrival time
+type Distribution interface {
+	Next(r *rand.Rand, mean time.Duration) time.Duration
+}
+
+type Config struct {
+	Type   string  `json:"type"`   //fixed, uniform, exponential (or poisson), empirical
+	Jitter float64 `json:"jitter"` //uniform: max relative deviation from the mean (0.2 --> +-20%)
+	File   string  `json:"file"`   //empirical: one inter-arrival sample per line as go duration (e.g. 1.5s) or milliseconds
+}
+
+func New(config Config) (Distribution, error) {
+	switch config.Type {
+	case "", "fixed":
+		return Fixed{}, nil
+	case "uniform":
+		if config.Jitter < 0 || config.Jitter > 1 {
+			return nil, errors.New("uniform distribution expects 0 <= jitter <= 1")
+		}
+		return Uniform{Jitter: config.Jitter}, nil
+	case "exponential", "poisson":
+		return Exponential{}, nil
+	case "empirical":
+		return LoadEmpirical(config.File)
+	default:
+		return nil, errors.New("unknown distribution type: " + config.Type)
+	}
+}
+
+// Seed derives a reproducible seed for a single emitter from the run seed
+func Seed(runSeed int64, key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return runSeed ^ int64(h.Sum64())
+}
+
+type Fixed struct{}
+
+func (this Fixed) Next(r *rand.Rand, mean time.Duration) time.Duration {
+	return mean
+}
+
+type Uniform struct {
+	Jitter float64
+}
+
+func (this Uniform) Next(r *rand.Rand, mean time.Duration) time.Duration {
+	return time.Duration(float64(mean) * (1 + this.Jitter*(2*r.Float64()-1)))
+}
+
+// Exponential inter-arrival times result in a poisson process
+type Exponential struct{}
+
+func (this Exponential) Next(r *rand.Rand, mean time.Duration) time.Duration {
+	return time.Duration(r.ExpFloat64() * float64(mean))
+}
+
+// Empirical draws from recorded samples, scaled so that the sample mean matches the requested mean
+type Empirical struct {
+	Samples []float64 //relative to the sample mean; LoadEmpirical rejects files without samples
+}
+
+func (this Empirical) Next(r *rand.Rand, mean time.Duration) time.Duration {
+	if len(this.Samples) == 0 {
+		return mean
+	}
+	return time.Duration(this.Samples[r.Intn(len(this.Samples))] * float64(mean))
+}
+
+func LoadEmpirical(location string) (result Empirical, err error) {
+	if location == "" {
+		return result, errors.New("empirical distribution expects file")
+	}
+	file, err := os.Open(location)
+	if err != nil {
+		return result, err
+	}
+	defer file.Close()
+	sum := 0.0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sample, err := parseSample(line)
+		if err != nil {
+			return result, err
+		}
+		result.Samples = append(result.Samples, sample)
+		sum = sum + sample
+	}
+	if err = scanner.Err(); err != nil {
+		return result, err
+	}
+	if len(result.Samples) == 0 || sum <= 0 {
+		return result, errors.New("empirical distribution file contains no positive samples: " + location)
+	}
+	mean := sum / float64(len(result.Samples))
+	for i, sample := range result.Samples {
+		result.Samples[i] = sample / mean
+	}
+	return result, nil
+}
+
+func parseSample(line string) (float64, error) {
+	ms, err := strconv.ParseFloat(line, 64)
+	if err == nil {
+		if ms < 0 {
+			return 0, errors.New("negative inter-arrival sample: " + line)
+		}
+		return ms * float64(time.Millisecond), nil
+	}
+	d, err := time.ParseDuration(line)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, errors.New("negative inter-arrival sample: " + line)
+	}
+	return float64(d), nil
+}
@@ -0,0 +1,126 @@
+package distribution
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMean(t *testing.T) {
+	empirical := writeSamples(t, "# recorded gaps\n100ms\n\n300\n0.2s\n")
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "default", config: Config{}},
+		{name: "fixed", config: Config{Type: "fixed"}},
+		{name: "uniform", config: Config{Type: "uniform", Jitter: 0.5}},
+		{name: "exponential", config: Config{Type: "exponential"}},
+		{name: "poisson", config: Config{Type: "poisson"}},
+		{name: "empirical", config: Config{Type: "empirical", File: empirical}},
+	}
+	mean := time.Second
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := New(test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := rand.New(rand.NewSource(1))
+			n := 100000
+			sum := 0.0
+			for i := 0; i < n; i++ {
+				next := d.Next(r, mean)
+				if next < 0 {
+					t.Fatal("negative inter-arrival time", next)
+				}
+				sum = sum + float64(next)
+			}
+			actual := sum / float64(n)
+			if math.Abs(actual-float64(mean))/float64(mean) > 0.02 {
+				t.Errorf("expect mean %v, got %v", mean, time.Duration(actual))
+			}
+		})
+	}
+}
+
+func TestUniformRange(t *testing.T) {
+	d := Uniform{Jitter: 0.2}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		next := d.Next(r, time.Second)
+		if next < 800*time.Millisecond || next > 1200*time.Millisecond {
+			t.Fatal("inter-arrival time out of jitter range", next)
+		}
+	}
+}
+
+func TestEmpiricalSamples(t *testing.T) {
+	d, err := LoadEmpirical(writeSamples(t, "1s\n3s\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		next := d.Next(r, 2*time.Second)
+		if next != time.Second && next != 3*time.Second {
+			t.Fatal("unexpected sample", next)
+		}
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	d := Exponential{}
+	a := rand.New(rand.NewSource(Seed(42, "device/service")))
+	b := rand.New(rand.NewSource(Seed(42, "device/service")))
+	for i := 0; i < 100; i++ {
+		if d.Next(a, time.Second) != d.Next(b, time.Second) {
+			t.Fatal("same seed and key results in different inter-arrival times")
+		}
+	}
+	if Seed(42, "a") == Seed(42, "b") {
+		t.Error("expect different seeds for different keys")
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "unknown type", config: Config{Type: "normal"}},
+		{name: "negative jitter", config: Config{Type: "uniform", Jitter: -0.1}},
+		{name: "jitter above 1", config: Config{Type: "uniform", Jitter: 1.5}},
+		{name: "empirical without file", config: Config{Type: "empirical"}},
+		{name: "empirical with missing file", config: Config{Type: "empirical", File: filepath.Join(t.TempDir(), "missing.txt")}},
+		{name: "empirical without samples", config: Config{Type: "empirical", File: writeSamples(t, "# no samples\n\n")}},
+		{name: "empirical with zero samples", config: Config{Type: "empirical", File: writeSamples(t, "0\n0s\n")}},
+		{name: "empirical with negative sample", config: Config{Type: "empirical", File: writeSamples(t, "1s\n-1s\n")}},
+		{name: "empirical with invalid sample", config: Config{Type: "empirical", File: writeSamples(t, "1s\nsoon\n")}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := New(test.config)
+			if err == nil {
+				t.Error("expect error")
+			}
+		})
+	}
+}
+
+func writeSamples(t *testing.T, content string) string {
+	t.Helper()
+	file, err := os.CreateTemp(t.TempDir(), "samples-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	_, err = file.WriteString(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file.Name()
+}
@@ -2,42 +2,154 @@ package pkg
 
 import (
 	"context"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/distribution"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
 	"math/rand"
+	"sync"
 	"time"
 )
 
 type Message struct {
-	Info    map[string]string
-	Message string
+	Info      map[string]string
+	Message   string
+	Qos       byte
+	Scheduled time.Time
+	Stream    int
+	Seq       uint64
 }
 
-func Emitter(ctx context.Context, out chan<- Message, info map[string]string, interval time.Duration, message func() string) {
+// newMessage creates the next message of source; false if the budget of source is exhausted or the message could not be rendered
+// the budget is only taken for rendered messages
+func newMessage(source Source) (result Message, ok bool) {
+	result = Message{
+		Info:   source.Info,
+		Qos:    source.Qos,
+		Stream: source.Stream,
+	}
+	var err error
+	result.Message, result.Seq, err = source.Message()
+	if err != nil {
+		return result, false
+	}
+	if !source.Budget.Take() {
+		return result, false
+	}
+	return result, true
+}
+
+// RateCheckInterval is the longest time an emitter waits before it rechecks the load profile,
+// so that a resume or a higher rate takes effect even if the current factor is 0 or very small
+const RateCheckInterval = time.Second
+
+// Emitter sends a message with the mean interval, the time between two messages is drawn from arrival using r;
+// rate() (see loadprofile.Profile) scales the frequency over time and is rechecked at least every RateCheckInterval;
+// if out is full, the emitter blocks and the event is counted as blocked.
+// the emitter stops when ctx is done; wg (may be nil) waits for it
+func Emitter(ctx context.Context, wg *sync.WaitGroup, out chan<- Message, source Source, interval time.Duration, arrival distribution.Distribution, r *rand.Rand, rate func() float64, stat statistics.Interface) {
 	if interval > 0 {
+		if wg != nil {
+			wg.Add(1)
+		}
 		go func() {
-			//wait for random time between now and interval to offset emitter
-			r := rand.New(rand.NewSource(time.Now().UnixNano()))
-			if interval <= 1<<31-1 {
-				time.Sleep(time.Duration(r.Int31n(int32(interval))))
-			} else {
-				time.Sleep(time.Duration(r.Int63n(int64(interval))))
+			if wg != nil {
+				defer wg.Done()
 			}
-			emit(out, info, message)
-			t := time.NewTicker(interval)
+			//remaining is the unscaled time to the next message, it elapses factor times faster than the wall clock;
+			//the first message is offset by a random time between now and interval
+			remaining := randomOffset(r, interval)
+			factor := rate()
+			last := time.Now()
+			t := time.NewTimer(wait(remaining, factor))
+			defer t.Stop()
 			for {
 				select {
 				case <-ctx.Done():
 					return
-				case <-t.C:
-					emit(out, info, message)
+				case now := <-t.C:
+					if factor > 0 {
+						remaining = remaining - time.Duration(float64(now.Sub(last))*factor)
+					}
+					last = now
+					if remaining <= 0 {
+						emit(ctx, out, source, stat)
+						remaining = arrival.Next(r, interval)
+					}
+					factor = rate()
+					t.Reset(wait(remaining, factor))
 				}
 			}
 		}()
 	}
 }
 
-func emit(out chan<- Message, info map[string]string, message func() string) {
-	out <- Message{
-		Info:    info,
-		Message: message(),
+// emitted counts the enqueued message m; messages which do not reach the queue are counted as missed instead
+func emitted(m Message, stat statistics.Interface) {
+	stat.EventEmitted(m.Info[DeviceUriKey], m.Info[ServiceUriKey])
+}
+
+// Burst immediately enqueues count messages spread round-robin over sources; messages that do not fit into out are counted as missed.
+// count is limited to the capacity of out
+func Burst(out chan<- Message, sources []Source, count int, stat statistics.Interface) (queued int) {
+	if len(sources) == 0 {
+		return 0
+	}
+	if count > cap(out) {
+		count = cap(out)
+	}
+	for i := 0; i < count; i++ {
+		m, ok := newMessage(sources[i%len(sources)])
+		if !ok {
+			continue
+		}
+		m.Scheduled = time.Now()
+		select {
+		case out <- m:
+			emitted(m, stat)
+			queued++
+		default:
+			stat.EventMissed()
+		}
+	}
+	return queued
+}
+
+func randomOffset(r *rand.Rand, interval time.Duration) time.Duration {
+	if interval <= 1<<31-1 {
+		return time.Duration(r.Int31n(int32(interval)))
+	} else {
+		return time.Duration(r.Int63n(int64(interval)))
+	}
+}
+
+// wait returns the wall clock time until the unscaled remaining time is elapsed with factor, at most RateCheckInterval
+func wait(remaining time.Duration, factor float64) time.Duration {
+	if factor <= 0 {
+		return RateCheckInterval
+	}
+	scaled := float64(remaining) / factor
+	if scaled > float64(RateCheckInterval) {
+		return RateCheckInterval
+	}
+	return time.Duration(scaled)
+}
+
+// emit enqueues the next message of source; a message which is still blocked when ctx is done is counted as missed
+func emit(ctx context.Context, out chan<- Message, source Source, stat statistics.Interface) {
+	m, ok := newMessage(source)
+	if !ok {
+		return
+	}
+	m.Scheduled = time.Now()
+	select {
+	case out <- m:
+		emitted(m, stat)
+	default:
+		stat.EventBlocked()
+		select {
+		case out <- m:
+			emitted(m, stat)
+		case <-ctx.Done():
+			stat.EventMissed()
+		}
 	}
 }
@@ -0,0 +1,36 @@
+package pkg
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/control"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"testing"
+)
+
+func TestBurst(t *testing.T) {
+	created := 0
+	sources := []Source{{Message: func() (string, uint64, error) {
+		created++
+		return "{}", uint64(created), nil
+	}}}
+	out := make(chan Message, 3)
+	queued := Burst(out, sources, 1000000, statistics.Void{})
+	if queued != 3 || created != 3 {
+		t.Errorf("expect a burst limited to the queue capacity, got %v queued and %v created messages", queued, created)
+	}
+	if Burst(out, sources, 2, statistics.Void{}) != 0 {
+		t.Error("expect no queued messages in a full queue")
+	}
+}
+
+func TestNewMessageBudget(t *testing.T) {
+	budget := control.NewBudget("test", 10)
+	_, ok := newMessage(Source{Message: func() (string, uint64, error) { return "", 0, errors.New("template error") }, Budget: budget})
+	if ok || budget.Used() != 0 {
+		t.Error("expect no budget for a message which could not be rendered, used", budget.Used())
+	}
+	_, ok = newMessage(Source{Message: func() (string, uint64, error) { return "{}", 1, nil }, Budget: budget})
+	if !ok || budget.Used() != 1 {
+		t.Error("expect the budget of a rendered message, used", budget.Used())
+	}
+}
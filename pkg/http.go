@@ -0,0 +1,42 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/SENERGY-Platform/platform-connector-lib/security"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"net/http"
+)
+
+// getJSON is like security.JwtToken.GetJSON but counts failures in stat by endpoint
+func getJSON(token security.JwtToken, url string, result interface{}, stat statistics.Interface, endpoint string) (err error) {
+	resp, err := token.Get(url)
+	return decodeResponse(resp, err, result, stat, endpoint)
+}
+
+// postJSON is like security.JwtToken.PostJSON but counts failures in stat by endpoint
+func postJSON(token security.JwtToken, url string, body interface{}, result interface{}, stat statistics.Interface, endpoint string) (err error) {
+	b := new(bytes.Buffer)
+	err = json.NewEncoder(b).Encode(body)
+	if err != nil {
+		return err
+	}
+	resp, err := token.Post(url, "application/json", b)
+	return decodeResponse(resp, err, result, stat, endpoint)
+}
+
+func decodeResponse(resp *http.Response, err error, result interface{}, stat statistics.Interface, endpoint string) error {
+	if err != nil {
+		//on unexpected status codes the response is returned with a closed body
+		stat.Error(statistics.HttpErrorCategory(resp), endpoint)
+		return err
+	}
+	defer resp.Body.Close()
+	if result != nil {
+		err = json.NewDecoder(resp.Body).Decode(result)
+		if err != nil {
+			stat.Error(statistics.Unmarshal, endpoint)
+		}
+	}
+	return err
+}
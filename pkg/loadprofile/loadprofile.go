@@ -0,0 +1,203 @@
+package loadprofile
+
+import (
+	"errors"
+	"log"
+	"math"
+	"time"
+)
+
+// Profile returns the factor by which the configured base rate is multiplied after elapsed time since the run start.
+// 1 means the configured rate, 0 pauses the emitter.
+type Profile interface {
+	Factor(elapsed time.Duration) float64
+}
+
+type Config struct {
+	Type     string  `json:"type"`     //constant, ramp, step, spike, sinus, soak
+	From     float64 `json:"from"`     //start factor (ramp, step, soak), base factor (spike), min factor (sinus)
+	To       float64 `json:"to"`       //end factor (ramp, step, soak), peak factor (spike), max factor (sinus), factor (constant, 0 or missing is 1)
+	Duration string  `json:"duration"` //ramp-up time (ramp, step, soak) or spike length (spike)
+	Steps    int64   `json:"steps"`    //number of stairs (step)
+	Start    string  `json:"start"`    //offset of first spike (spike)
+	Period   string  `json:"period"`   //repetition of spikes (spike, optional) or length of one wave (sinus)
+	Hold     string  `json:"hold"`     //time to hold the end factor before stopping (soak)
+}
+
+func New(config Config) (Profile, error) {
+	switch config.Type {
+	case "", "constant":
+		if config.Type == "" {
+			return Constant{Value: 1}, nil
+		}
+		if config.To == 0 {
+			log.Println("WARNING: constant load profile without to; use factor 1")
+			return Constant{Value: 1}, nil
+		}
+		return Constant{Value: config.To}, nil
+	case "ramp":
+		duration, err := parseDuration(config.Duration, "duration")
+		if err != nil {
+			return nil, err
+		}
+		return Ramp{From: config.From, To: config.To, Duration: duration}, nil
+	case "step":
+		duration, err := parseDuration(config.Duration, "duration")
+		if err != nil {
+			return nil, err
+		}
+		if config.Steps < 1 {
+			return nil, errors.New("step load profile expects steps >= 1")
+		}
+		return Step{From: config.From, To: config.To, Steps: config.Steps, Duration: duration}, nil
+	case "spike":
+		start, err := parseOptionalDuration(config.Start)
+		if err != nil {
+			return nil, err
+		}
+		duration, err := parseDuration(config.Duration, "duration")
+		if err != nil {
+			return nil, err
+		}
+		period, err := parseOptionalDuration(config.Period)
+		if err != nil {
+			return nil, err
+		}
+		if period != 0 && period < duration {
+			return nil, errors.New("spike load profile expects period >= duration")
+		}
+		return Spike{Base: config.From, Peak: config.To, Start: start, Duration: duration, Period: period}, nil
+	case "sinus":
+		period, err := parseDuration(config.Period, "period")
+		if err != nil {
+			return nil, err
+		}
+		return Sinus{Min: config.From, Max: config.To, Period: period}, nil
+	case "soak":
+		duration, err := parseOptionalDuration(config.Duration)
+		if err != nil {
+			return nil, err
+		}
+		hold, err := parseDuration(config.Hold, "hold")
+		if err != nil {
+			return nil, err
+		}
+		return Soak{Ramp: Ramp{From: config.From, To: config.To, Duration: duration}, Hold: hold}, nil
+	default:
+		return nil, errors.New("unknown load profile type: " + config.Type)
+	}
+}
+
+func parseDuration(value string, field string) (time.Duration, error) {
+	if value == "" {
+		return 0, errors.New("missing load profile field " + field)
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, errors.New("expect positive load profile field " + field)
+	}
+	return d, nil
+}
+
+func parseOptionalDuration(value string) (time.Duration, error) {
+	if value == "" || value == "-" {
+		return 0, nil
+	}
+	return time.ParseDuration(value)
+}
+
+type Constant struct {
+	Value float64
+}
+
+func (this Constant) Factor(elapsed time.Duration) float64 {
+	return this.Value
+}
+
+// Ramp changes the factor linearly from From to To within Duration and holds To afterwards.
+type Ramp struct {
+	From     float64
+	To       float64
+	Duration time.Duration
+}
+
+func (this Ramp) Factor(elapsed time.Duration) float64 {
+	if elapsed >= this.Duration {
+		return this.To
+	}
+	return this.From + (this.To-this.From)*float64(elapsed)/float64(this.Duration)
+}
+
+// Step changes the factor from From to To in Steps equal stairs within Duration.
+type Step struct {
+	From     float64
+	To       float64
+	Steps    int64
+	Duration time.Duration
+}
+
+func (this Step) Factor(elapsed time.Duration) float64 {
+	if this.Steps <= 1 {
+		if elapsed >= this.Duration {
+			return this.To
+		}
+		return this.From
+	}
+	stepDuration := this.Duration / time.Duration(this.Steps)
+	step := int64(elapsed / stepDuration)
+	if step >= this.Steps {
+		step = this.Steps - 1
+	}
+	return this.From + (this.To-this.From)*float64(step)/float64(this.Steps-1)
+}
+
+// Spike holds Base and switches to Peak for Duration after Start; with a Period the spike repeats.
+type Spike struct {
+	Base     float64
+	Peak     float64
+	Start    time.Duration
+	Duration time.Duration
+	Period   time.Duration
+}
+
+func (this Spike) Factor(elapsed time.Duration) float64 {
+	if elapsed < this.Start {
+		return this.Base
+	}
+	offset := elapsed - this.Start
+	if this.Period > 0 {
+		offset = offset % this.Period
+	}
+	if offset < this.Duration {
+		return this.Peak
+	}
+	return this.Base
+}
+
+// Sinus follows a day curve between Min and Max, starting at Min.
+type Sinus struct {
+	Min    float64
+	Max    float64
+	Period time.Duration
+}
+
+func (this Sinus) Factor(elapsed time.Duration) float64 {
+	phase := 2 * math.Pi * float64(elapsed%this.Period) / float64(this.Period)
+	return this.Min + (this.Max-this.Min)*(1-math.Cos(phase))/2
+}
+
+// Soak ramps up like Ramp, holds the end factor for Hold and stops emitting afterwards.
+type Soak struct {
+	Ramp Ramp
+	Hold time.Duration
+}
+
+func (this Soak) Factor(elapsed time.Duration) float64 {
+	if elapsed >= this.Ramp.Duration+this.Hold {
+		return 0
+	}
+	return this.Ramp.Factor(elapsed)
+}
@@ -0,0 +1,75 @@
+package loadprofile
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFactor(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		elapsed time.Duration
+		expect  float64
+	}{
+		{name: "default", config: Config{}, elapsed: time.Hour, expect: 1},
+		{name: "default ignores to", config: Config{To: 3}, elapsed: time.Hour, expect: 1},
+		{name: "constant", config: Config{Type: "constant", To: 2.5}, elapsed: time.Hour, expect: 2.5},
+		{name: "constant without to", config: Config{Type: "constant"}, elapsed: time.Hour, expect: 1},
+		{name: "ramp start", config: Config{Type: "ramp", From: 0, To: 2, Duration: "10m"}, elapsed: 0, expect: 0},
+		{name: "ramp middle", config: Config{Type: "ramp", From: 0, To: 2, Duration: "10m"}, elapsed: 5 * time.Minute, expect: 1},
+		{name: "ramp end", config: Config{Type: "ramp", From: 0, To: 2, Duration: "10m"}, elapsed: time.Hour, expect: 2},
+		{name: "ramp down", config: Config{Type: "ramp", From: 1, To: 0.5, Duration: "10m"}, elapsed: 5 * time.Minute, expect: 0.75},
+		{name: "step first", config: Config{Type: "step", From: 1, To: 3, Steps: 3, Duration: "3m"}, elapsed: 59 * time.Second, expect: 1},
+		{name: "step second", config: Config{Type: "step", From: 1, To: 3, Steps: 3, Duration: "3m"}, elapsed: 90 * time.Second, expect: 2},
+		{name: "step last", config: Config{Type: "step", From: 1, To: 3, Steps: 3, Duration: "3m"}, elapsed: time.Hour, expect: 3},
+		{name: "single step", config: Config{Type: "step", From: 1, To: 3, Steps: 1, Duration: "3m"}, elapsed: time.Minute, expect: 1},
+		{name: "spike before start", config: Config{Type: "spike", From: 1, To: 5, Start: "1m", Duration: "10s"}, elapsed: 30 * time.Second, expect: 1},
+		{name: "spike peak", config: Config{Type: "spike", From: 1, To: 5, Start: "1m", Duration: "10s"}, elapsed: 65 * time.Second, expect: 5},
+		{name: "spike after", config: Config{Type: "spike", From: 1, To: 5, Start: "1m", Duration: "10s"}, elapsed: 2 * time.Minute, expect: 1},
+		{name: "spike repeated", config: Config{Type: "spike", From: 1, To: 5, Start: "1m", Duration: "10s", Period: "1m"}, elapsed: 125 * time.Second, expect: 5},
+		{name: "sinus min", config: Config{Type: "sinus", From: 1, To: 3, Period: "24h"}, elapsed: 0, expect: 1},
+		{name: "sinus max", config: Config{Type: "sinus", From: 1, To: 3, Period: "24h"}, elapsed: 12 * time.Hour, expect: 3},
+		{name: "sinus next period", config: Config{Type: "sinus", From: 1, To: 3, Period: "24h"}, elapsed: 24 * time.Hour, expect: 1},
+		{name: "soak ramp", config: Config{Type: "soak", From: 0, To: 2, Duration: "10m", Hold: "1h"}, elapsed: 5 * time.Minute, expect: 1},
+		{name: "soak hold", config: Config{Type: "soak", From: 0, To: 2, Duration: "10m", Hold: "1h"}, elapsed: time.Hour, expect: 2},
+		{name: "soak stopped", config: Config{Type: "soak", From: 0, To: 2, Duration: "10m", Hold: "1h"}, elapsed: 71 * time.Minute, expect: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			profile, err := New(test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual := profile.Factor(test.elapsed)
+			if math.Abs(actual-test.expect) > 1e-9 {
+				t.Errorf("expect %v, got %v", test.expect, actual)
+			}
+		})
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "unknown type", config: Config{Type: "wave"}},
+		{name: "ramp without duration", config: Config{Type: "ramp", To: 1}},
+		{name: "ramp with negative duration", config: Config{Type: "ramp", To: 1, Duration: "-1m"}},
+		{name: "step without steps", config: Config{Type: "step", To: 1, Duration: "1m"}},
+		{name: "spike period shorter than duration", config: Config{Type: "spike", To: 1, Duration: "1m", Period: "10s"}},
+		{name: "sinus without period", config: Config{Type: "sinus", To: 1}},
+		{name: "soak without hold", config: Config{Type: "soak", To: 1, Duration: "1m"}},
+		{name: "invalid duration", config: Config{Type: "ramp", To: 1, Duration: "ten minutes"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := New(test.config)
+			if err == nil {
+				t.Error("expect error")
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const Prefix = "senergy_load_test_"
+
+// Buckets are the upper bounds of the exported latency histograms
+var Buckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Instance is a started hub (or mqtt client) whose state is exported
+type Instance struct {
+	Name       string
+	Stat       *statistics.Implementation
+	Connected  func() bool
+	devices    int64
+	processes  int64
+	pipelines  int64
+	deleted    sync.Map //resource name -> *int64
+	stepsMux   sync.Mutex
+	steps      []ShutdownStep
+	startupMux sync.Mutex
+	startup    Startup
+}
+
+// Startup is the time to start an instance including connect rate limit waits and retries (see pkg.startRetry)
+type Startup struct {
+	Duration    time.Duration `json:"duration"`
+	ConnectWait time.Duration `json:"connect_wait"` //waited for the connect_rate
+	Attempts    int           `json:"attempts"`
+	Error       string        `json:"error,omitempty"` //the instance could not be started or the run ended during the startup
+}
+
+func (this *Instance) SetStartup(startup Startup) {
+	this.startupMux.Lock()
+	defer this.startupMux.Unlock()
+	this.startup = startup
+}
+
+func (this *Instance) Startup() Startup {
+	this.startupMux.Lock()
+	defer this.startupMux.Unlock()
+	return this.startup
+}
+
+// ShutdownStep is a timed step of the ordered shutdown of an instance (see pkg.shutdown)
+type ShutdownStep struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	Result   string        `json:"result,omitempty"`
+}
+
+func (this *Instance) AddShutdownStep(step ShutdownStep) {
+	this.stepsMux.Lock()
+	defer this.stepsMux.Unlock()
+	this.steps = append(this.steps, step)
+}
+
+// ShutdownSteps returns the finished shutdown steps in order
+func (this *Instance) ShutdownSteps() []ShutdownStep {
+	this.stepsMux.Lock()
+	defer this.stepsMux.Unlock()
+	return append([]ShutdownStep{}, this.steps...)
+}
+
+func (this *Instance) Devices() int64 {
+	return atomic.LoadInt64(&this.devices)
+}
+
+func (this *Instance) Processes() int64 {
+	return atomic.LoadInt64(&this.processes)
+}
+
+func (this *Instance) Pipelines() int64 {
+	return atomic.LoadInt64(&this.pipelines)
+}
+
+// AddDeleted counts resources (devices, hubs, processes, pipelines) removed on cleanup
+func (this *Instance) AddDeleted(resource string, count int) {
+	counter, _ := this.deleted.LoadOrStore(resource, new(int64))
+	atomic.AddInt64(counter.(*int64), int64(count))
+}
+
+// Deleted returns the count of removed resources by resource name
+func (this *Instance) Deleted() map[string]int64 {
+	result := map[string]int64{}
+	this.deleted.Range(func(key, value interface{}) bool {
+		result[key.(string)] = atomic.LoadInt64(value.(*int64))
+		return true
+	})
+	return result
+}
+
+func (this *Instance) SetDevices(count int) {
+	atomic.StoreInt64(&this.devices, int64(count))
+}
+
+func (this *Instance) SetProcesses(count int) {
+	atomic.StoreInt64(&this.processes, int64(count))
+}
+
+func (this *Instance) SetPipelines(count int) {
+	atomic.StoreInt64(&this.pipelines, int64(count))
+}
+
+var instancesMux sync.Mutex
+var instances = map[string]*Instance{}
+
+// Register adds instance to the exported metrics; an instance with the same name is replaced (e.g. on restart)
+func Register(instance *Instance) *Instance {
+	instancesMux.Lock()
+	defer instancesMux.Unlock()
+	instances[instance.Name] = instance
+	return instance
+}
+
+func Unregister(name string) {
+	instancesMux.Lock()
+	defer instancesMux.Unlock()
+	delete(instances, name)
+}
+
+// Get returns the registered instance with name or nil
+func Get(name string) *Instance {
+	instancesMux.Lock()
+	defer instancesMux.Unlock()
+	return instances[name]
+}
+
+// Instances returns the registered instances sorted by name
+func Instances() (result []*Instance) {
+	instancesMux.Lock()
+	defer instancesMux.Unlock()
+	for _, instance := range instances {
+		result = append(result, instance)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Summary merges the run statistics of all registered instances
+func Summary() statistics.RunSummary {
+	stats := []*statistics.Implementation{}
+	hubs := []string{}
+	runs := []statistics.RunSummary{}
+	for _, instance := range Instances() {
+		if instance.Stat != nil {
+			stats = append(stats, instance.Stat)
+			hubs = append(hubs, instance.Name)
+			runs = append(runs, instance.Stat.Run())
+		}
+	}
+	result := statistics.Summarize(stats)
+	result.Hubs = statistics.HubBreakdown(hubs, runs)
+	return result
+}
+
+// Handler writes the metrics of all registered instances in the prometheus text format
+func Handler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	Write(writer, Instances())
+}
+
+func Write(writer io.Writer, instances []*Instance) {
+	snapshots := make([]statistics.Snapshot, len(instances))
+	for i, instance := range instances {
+		if instance.Stat != nil {
+			snapshots[i] = instance.Stat.Snapshot()
+		}
+	}
+
+	counter := func(name string, help string, value func(totals *statistics.ServiceTotals) uint64) {
+		header(writer, name, help, "counter")
+		for i, instance := range instances {
+			for _, service := range services(snapshots[i]) {
+				sample(writer, name, labels(instance.Name, service), strconv.FormatUint(value(snapshots[i].Services[service]), 10))
+			}
+		}
+	}
+	counter(Prefix+"events_emitted_total", "events enqueued by the emitters", func(totals *statistics.ServiceTotals) uint64 {
+		return totals.Emitted
+	})
+	counter(Prefix+"events_produced_total", "events successfully published", func(totals *statistics.ServiceTotals) uint64 {
+		return totals.Produced
+	})
+	counter(Prefix+"events_failed_total", "events which could not be published", func(totals *statistics.ServiceTotals) uint64 {
+		return totals.Failed
+	})
+	counter(Prefix+"commands_handled_total", "commands received by the simulated devices", func(totals *statistics.ServiceTotals) uint64 {
+		return totals.CommandsHandled
+	})
+
+	header(writer, Prefix+"publish_latency_seconds", "time to publish an event", "histogram")
+	for i, instance := range instances {
+		for _, service := range services(snapshots[i]) {
+			if _, ok := snapshots[i].ProduceTimes[service]; !ok {
+				continue
+			}
+			histogram(writer, Prefix+"publish_latency_seconds", instance.Name, service, snapshots[i].ProduceTimes[service])
+		}
+	}
+
+	gauge := func(name string, help string, value func(i int, instance *Instance) float64) {
+		header(writer, name, help, "gauge")
+		for i, instance := range instances {
+			sample(writer, name, labels(instance.Name, ""), strconv.FormatFloat(value(i, instance), 'f', -1, 64))
+		}
+	}
+	gauge(Prefix+"connected_clients", "connected mqtt clients", func(i int, instance *Instance) float64 {
+		if instance.Connected != nil && instance.Connected() {
+			return 1
+		}
+		return 0
+	})
+	gauge(Prefix+"devices_provisioned", "provisioned devices", func(i int, instance *Instance) float64 {
+		return float64(instance.Devices())
+	})
+	gauge(Prefix+"processes_deployed", "deployed processes", func(i int, instance *Instance) float64 {
+		return float64(instance.Processes())
+	})
+	gauge(Prefix+"pipelines_deployed", "deployed analytics pipelines", func(i int, instance *Instance) float64 {
+		return float64(instance.Pipelines())
+	})
+	gauge(Prefix+"startup_seconds", "time to start the instance including connect rate limit waits and retries", func(i int, instance *Instance) float64 {
+		return instance.Startup().Duration.Seconds()
+	})
+	gauge(Prefix+"emitter_queue_depth", "messages waiting in the emitter queue", func(i int, instance *Instance) float64 {
+		return float64(snapshots[i].QueueDepth)
+	})
+	gauge(Prefix+"emitter_queue_capacity", "capacity of the emitter queue", func(i int, instance *Instance) float64 {
+		return float64(snapshots[i].QueueCapacity)
+	})
+
+	header(writer, Prefix+"scenario_phase", "1 based index of the running scenario phase labeled with its name", "gauge")
+	for i, instance := range instances {
+		if snapshots[i].PhaseIndex > 0 {
+			sample(writer, Prefix+"scenario_phase", labels(instance.Name, "", "phase", snapshots[i].Phase), strconv.Itoa(snapshots[i].PhaseIndex))
+		}
+	}
+
+	header(writer, Prefix+"errors_total", "errors by category and endpoint", "counter")
+	for i, instance := range instances {
+		keys := []string{}
+		for key := range snapshots[i].Errors {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			parts := strings.SplitN(key, ":", 2)
+			endpoint := ""
+			if len(parts) == 2 {
+				endpoint = parts[1]
+			}
+			sample(writer, Prefix+"errors_total", labels(instance.Name, "", "category", parts[0], "endpoint", endpoint), strconv.FormatUint(snapshots[i].Errors[key], 10))
+		}
+	}
+
+	header(writer, Prefix+"resources_deleted_total", "resources removed on cleanup", "counter")
+	for _, instance := range instances {
+		deleted := instance.Deleted()
+		resources := []string{}
+		for resource := range deleted {
+			resources = append(resources, resource)
+		}
+		sort.Strings(resources)
+		for _, resource := range resources {
+			sample(writer, Prefix+"resources_deleted_total", labels(instance.Name, "", "resource", resource), strconv.FormatInt(deleted[resource], 10))
+		}
+	}
+}
+
+func header(writer io.Writer, name string, help string, kind string) {
+	fmt.Fprintln(writer, "# HELP", name, help)
+	fmt.Fprintln(writer, "# TYPE", name, kind)
+}
+
+func sample(writer io.Writer, name string, labels string, value string) {
+	fmt.Fprintln(writer, name+labels, value)
+}
+
+func histogram(writer io.Writer, name string, instance string, service string, h *statistics.Histogram) {
+	for _, bucket := range Buckets {
+		sample(writer, name+"_bucket", labels(instance, service, "le", strconv.FormatFloat(bucket.Seconds(), 'f', -1, 64)), strconv.FormatUint(h.CountUpTo(bucket), 10))
+	}
+	sample(writer, name+"_bucket", labels(instance, service, "le", "+Inf"), strconv.FormatUint(h.Count(), 10))
+	sample(writer, name+"_sum", labels(instance, service), strconv.FormatFloat(h.Sum().Seconds(), 'f', -1, 64))
+	sample(writer, name+"_count", labels(instance, service), strconv.FormatUint(h.Count(), 10))
+}
+
+// labels formats the hub (instance name) and service label followed by additional key value pairs
+func labels(instance string, service string, additional ...string) string {
+	pairs := []string{"hub", instance}
+	if service != "" {
+		pairs = append(pairs, "service", service)
+	}
+	pairs = append(pairs, additional...)
+	parts := []string{}
+	for i := 0; i+1 < len(pairs); i = i + 2 {
+		parts = append(parts, pairs[i]+"=\""+escape(pairs[i+1])+"\"")
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var escaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+func escape(value string) string {
+	return escaper.Replace(value)
+}
+
+func services(snapshot statistics.Snapshot) (result []string) {
+	for key := range snapshot.Services {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stat := statistics.New(ctx, 0, nil)
+	for _, d := range []time.Duration{3 * time.Millisecond, 3 * time.Millisecond, 200 * time.Millisecond, 20 * time.Second} {
+		stat.EventEmitted("device", "svc")
+		stat.EventProduce("device", "svc", d)
+	}
+	instance := &Instance{Name: `a"b\c`, Stat: stat}
+	instance.SetDevices(2)
+	instance.AddDeleted("devices", 2)
+
+	buffer := &bytes.Buffer{}
+	Write(buffer, []*Instance{instance})
+
+	expected := strings.Join([]string{
+		`# HELP senergy_load_test_events_emitted_total events enqueued by the emitters`,
+		`# TYPE senergy_load_test_events_emitted_total counter`,
+		`senergy_load_test_events_emitted_total{hub="a\"b\\c",service="svc"} 4`,
+		`# HELP senergy_load_test_events_produced_total events successfully published`,
+		`# TYPE senergy_load_test_events_produced_total counter`,
+		`senergy_load_test_events_produced_total{hub="a\"b\\c",service="svc"} 4`,
+		`# HELP senergy_load_test_events_failed_total events which could not be published`,
+		`# TYPE senergy_load_test_events_failed_total counter`,
+		`senergy_load_test_events_failed_total{hub="a\"b\\c",service="svc"} 0`,
+		`# HELP senergy_load_test_commands_handled_total commands received by the simulated devices`,
+		`# TYPE senergy_load_test_commands_handled_total counter`,
+		`senergy_load_test_commands_handled_total{hub="a\"b\\c",service="svc"} 0`,
+		`# HELP senergy_load_test_publish_latency_seconds time to publish an event`,
+		`# TYPE senergy_load_test_publish_latency_seconds histogram`,
+		`senergy_load_test_publish_latency_seconds_bucket{hub="a\"b\\c",service="svc",le="0.001"} 0`,
+		`senergy_load_test_publish_latency_seconds_bucket{hub="a\"b\\c",service="svc",le="0.005"} 2`,
+		`senergy_load_test_publish_latency_seconds_bucket{hub="a\"b\\c",service="svc",le="0.01"} 2`,
+		`senergy_load_test_publish_latency_seconds_bucket{hub="a\"b\\c",service="svc",le="0.025"} 2`,
+		`senergy_load_test_publish_latency_seconds_bucket{hub="a\"b\\c",service="svc",le="0.05"} 2`,
+		`senergy_load_test_publish_latency_seconds_bucket{hub="a\"b\\c",service="svc",le="0.1"} 2`,
+		`senergy_load_test_publish_latency_seconds_bucket{hub="a\"b\\c",service="svc",le="0.25"} 3`,
+		`senergy_load_test_publish_latency_seconds_bucket{hub="a\"b\\c",service="svc",le="0.5"} 3`,
+		`senergy_load_test_publish_latency_seconds_bucket{hub="a\"b\\c",service="svc",le="1"} 3`,
+		`senergy_load_test_publish_latency_seconds_bucket{hub="a\"b\\c",service="svc",le="2.5"} 3`,
+		`senergy_load_test_publish_latency_seconds_bucket{hub="a\"b\\c",service="svc",le="5"} 3`,
+		`senergy_load_test_publish_latency_seconds_bucket{hub="a\"b\\c",service="svc",le="10"} 3`,
+		`senergy_load_test_publish_latency_seconds_bucket{hub="a\"b\\c",service="svc",le="+Inf"} 4`,
+		`senergy_load_test_publish_latency_seconds_sum{hub="a\"b\\c",service="svc"} 20.206`,
+		`senergy_load_test_publish_latency_seconds_count{hub="a\"b\\c",service="svc"} 4`,
+		`# HELP senergy_load_test_connected_clients connected mqtt clients`,
+		`# TYPE senergy_load_test_connected_clients gauge`,
+		`senergy_load_test_connected_clients{hub="a\"b\\c"} 0`,
+		`# HELP senergy_load_test_devices_provisioned provisioned devices`,
+		`# TYPE senergy_load_test_devices_provisioned gauge`,
+		`senergy_load_test_devices_provisioned{hub="a\"b\\c"} 2`,
+		`# HELP senergy_load_test_processes_deployed deployed processes`,
+		`# TYPE senergy_load_test_processes_deployed gauge`,
+		`senergy_load_test_processes_deployed{hub="a\"b\\c"} 0`,
+		`# HELP senergy_load_test_pipelines_deployed deployed analytics pipelines`,
+		`# TYPE senergy_load_test_pipelines_deployed gauge`,
+		`senergy_load_test_pipelines_deployed{hub="a\"b\\c"} 0`,
+		`# HELP senergy_load_test_startup_seconds time to start the instance including connect rate limit waits and retries`,
+		`# TYPE senergy_load_test_startup_seconds gauge`,
+		`senergy_load_test_startup_seconds{hub="a\"b\\c"} 0`,
+		`# HELP senergy_load_test_emitter_queue_depth messages waiting in the emitter queue`,
+		`# TYPE senergy_load_test_emitter_queue_depth gauge`,
+		`senergy_load_test_emitter_queue_depth{hub="a\"b\\c"} 0`,
+		`# HELP senergy_load_test_emitter_queue_capacity capacity of the emitter queue`,
+		`# TYPE senergy_load_test_emitter_queue_capacity gauge`,
+		`senergy_load_test_emitter_queue_capacity{hub="a\"b\\c"} 0`,
+		`# HELP senergy_load_test_scenario_phase 1 based index of the running scenario phase labeled with its name`,
+		`# TYPE senergy_load_test_scenario_phase gauge`,
+		`# HELP senergy_load_test_errors_total errors by category and endpoint`,
+		`# TYPE senergy_load_test_errors_total counter`,
+		`# HELP senergy_load_test_resources_deleted_total resources removed on cleanup`,
+		`# TYPE senergy_load_test_resources_deleted_total counter`,
+		`senergy_load_test_resources_deleted_total{hub="a\"b\\c",resource="devices"} 2`,
+	}, "\n") + "\n"
+	if buffer.String() != expected {
+		t.Errorf("unexpected metrics\n%v\nexpected\n%v", buffer.String(), expected)
+	}
+}
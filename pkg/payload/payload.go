@@ -0,0 +1,147 @@
+package payload
+
+import (
+	"bytes"
+	uuid "github.com/satori/go.uuid"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// legacy placeholders of service_message and their template equivalent
+var legacyPlaceholders = strings.NewReplacer(
+	"__TIME_NOW_UNIX_MS__", "{{nowMs}}",
+	"__RAND_PERCENT__", "{{.RandInt 0 100}}",
+)
+
+var funcs = template.FuncMap{
+	"nowMs": func() int64 {
+		return time.Now().UnixNano() / int64(time.Millisecond)
+	},
+	"nowS": func() int64 {
+		return time.Now().Unix()
+	},
+	"nowRFC3339": func() string {
+		return time.Now().Format(time.RFC3339)
+	},
+	"uuid": func() string {
+		return uuid.NewV4().String()
+	},
+}
+
+type Template struct {
+	tmpl *template.Template
+}
+
+// New parses a payload template (text/template syntax).
+// functions: nowMs, nowS, nowRFC3339, uuid
+// data: .Device.Id, .Device.Name, .Device.Index, .Device.Group, .Device.GroupIndex, .HubId, .Seq, .Trace, .RandInt, .RandFloat, .Normal, .Choice, .Walk, .Counter
+func New(text string) (*Template, error) {
+	tmpl, err := template.New("payload").Funcs(funcs).Option("missingkey=error").Parse(legacyPlaceholders.Replace(text))
+	if err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+type Device struct {
+	Id         string
+	Name       string
+	Index      int
+	Group      string
+	GroupIndex int
+}
+
+// ForDevice creates a generator with its own state (sequence number, random walks, counters) for one device;
+// trace creates the value of .Trace for a sequence number
+func (this *Template) ForDevice(device Device, hubId string, r *rand.Rand, trace func(seq uint64) string) *Generator {
+	return &Generator{
+		template: this,
+		data: &Data{
+			Device: device,
+			HubId:  hubId,
+			r:      r,
+			state:  map[string]float64{},
+			trace:  trace,
+		},
+	}
+}
+
+type Generator struct {
+	template *Template
+	mux      sync.Mutex
+	data     *Data
+}
+
+// Next returns the next payload and its sequence number
+func (this *Generator) Next() (string, uint64, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.data.Seq++
+	buf := bytes.Buffer{}
+	err := this.template.tmpl.Execute(&buf, this.data)
+	return buf.String(), this.data.Seq, err
+}
+
+// Data is the template input; its methods are not thread safe and are only used while Generator.mux is locked
+type Data struct {
+	Device Device
+	HubId  string
+	Seq    uint64 //per device sequence number, starting at 1
+	r      *rand.Rand
+	state  map[string]float64
+	trace  func(seq uint64) string
+}
+
+// Trace identifies the event downstream to measure latency and loss (see tracking.Tracker)
+func (this *Data) Trace() string {
+	return this.trace(this.Seq)
+}
+
+// RandInt returns a random int in [min, max]
+func (this *Data) RandInt(min int64, max int64) int64 {
+	if max <= min {
+		return min
+	}
+	return min + this.r.Int63n(max-min+1)
+}
+
+// RandFloat returns a random float in [min, max)
+func (this *Data) RandFloat(min float64, max float64) float64 {
+	return min + this.r.Float64()*(max-min)
+}
+
+func (this *Data) Normal(mean float64, stddev float64) float64 {
+	return mean + this.r.NormFloat64()*stddev
+}
+
+func (this *Data) Choice(options ...interface{}) interface{} {
+	if len(options) == 0 {
+		return ""
+	}
+	return options[this.r.Intn(len(options))]
+}
+
+// Walk returns the next value of the named random walk: each call moves the value by a random step in [-step, step], limited to [min, max]
+func (this *Data) Walk(name string, start float64, step float64, min float64, max float64) float64 {
+	key := "walk." + name
+	value, ok := this.state[key]
+	if !ok {
+		value = start
+	} else {
+		value = value + (2*this.r.Float64()-1)*step
+	}
+	value = math.Max(min, math.Min(max, value))
+	this.state[key] = value
+	return value
+}
+
+// Counter returns the named counter increased by increment, starting at increment
+func (this *Data) Counter(name string, increment float64) float64 {
+	key := "counter." + name
+	this.state[key] = this.state[key] + increment
+	return this.state[key]
+}
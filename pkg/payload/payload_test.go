@@ -0,0 +1,141 @@
+package payload
+
+import (
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var device = Device{Id: "urn:device:1", Name: "sensor_7", Index: 7, Group: "sensors", GroupIndex: 2}
+
+func generator(t *testing.T, text string) *Generator {
+	t.Helper()
+	tmpl, err := New(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tmpl.ForDevice(device, "hub-1", rand.New(rand.NewSource(1)), func(seq uint64) string {
+		return "trace-" + strconv.FormatUint(seq, 10)
+	})
+}
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		expect *regexp.Regexp
+	}{
+		{name: "device", text: "{{.Device.Id}} {{.Device.Name}} {{.Device.Index}} {{.Device.Group}} {{.Device.GroupIndex}}", expect: regexp.MustCompile(`^urn:device:1 sensor_7 7 sensors 2$`)},
+		{name: "hub id", text: "{{.HubId}}", expect: regexp.MustCompile(`^hub-1$`)},
+		{name: "seq and trace", text: "{{.Seq}} {{.Trace}}", expect: regexp.MustCompile(`^1 trace-1$`)},
+		{name: "milliseconds", text: "{{nowMs}}", expect: regexp.MustCompile(`^\d{13}$`)},
+		{name: "seconds", text: "{{nowS}}", expect: regexp.MustCompile(`^\d{10}$`)},
+		{name: "rfc3339", text: "{{nowRFC3339}}", expect: regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}`)},
+		{name: "uuid", text: "{{uuid}}", expect: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)},
+		{name: "legacy time", text: "__TIME_NOW_UNIX_MS__", expect: regexp.MustCompile(`^\d{13}$`)},
+		{name: "legacy percent", text: "__RAND_PERCENT__", expect: regexp.MustCompile(`^(\d|[1-9]\d|100)$`)},
+		{name: "choice", text: `{{.Choice "on" "off"}}`, expect: regexp.MustCompile(`^(on|off)$`)},
+		{name: "empty choice", text: `{{.Choice}}`, expect: regexp.MustCompile(`^$`)},
+		{name: "counter", text: `{{.Counter "energy" 0.5}} {{.Counter "energy" 0.5}}`, expect: regexp.MustCompile(`^0.5 1$`)},
+		{name: "walk start", text: `{{.Walk "temp" 20 1 10 30}}`, expect: regexp.MustCompile(`^20$`)},
+		{name: "json", text: `{"value": {{.RandInt 5 5}}, "device": "{{.Device.Name}}"}`, expect: regexp.MustCompile(`^\{"value": 5, "device": "sensor_7"\}$`)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, _, err := generator(t, test.text).Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.expect.MatchString(actual) {
+				t.Errorf("expect %v, got %v", test.expect.String(), actual)
+			}
+		})
+	}
+}
+
+func TestNowMs(t *testing.T) {
+	before := time.Now().UnixNano() / int64(time.Millisecond)
+	actual, _, err := generator(t, "__TIME_NOW_UNIX_MS__").Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now().UnixNano() / int64(time.Millisecond)
+	ms, err := strconv.ParseInt(actual, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms < before || ms > after {
+		t.Errorf("expect milliseconds between %v and %v, got %v", before, after, ms)
+	}
+}
+
+func TestRanges(t *testing.T) {
+	g := generator(t, `{{.RandInt -2 2}} {{.RandFloat 1.5 2.5}} {{.Walk "w" 0 5 -1 1}}`)
+	for i := 0; i < 1000; i++ {
+		actual, _, err := g.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := strings.Fields(actual)
+		i, _ := strconv.ParseInt(values[0], 10, 64)
+		if i < -2 || i > 2 {
+			t.Fatal("RandInt out of range", values[0])
+		}
+		f, _ := strconv.ParseFloat(values[1], 64)
+		if f < 1.5 || f >= 2.5 {
+			t.Fatal("RandFloat out of range", values[1])
+		}
+		w, _ := strconv.ParseFloat(values[2], 64)
+		if w < -1 || w > 1 {
+			t.Fatal("Walk out of range", values[2])
+		}
+	}
+}
+
+func TestSeq(t *testing.T) {
+	g := generator(t, "{{.Seq}}")
+	for i := uint64(1); i <= 3; i++ {
+		actual, seq, err := g.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq != i || actual != strconv.FormatUint(i, 10) {
+			t.Errorf("expect seq %v, got %v (%v)", i, seq, actual)
+		}
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	text := `{{.RandInt 0 1000}} {{.Normal 20 2}} {{.Walk "w" 0 1 -10 10}}`
+	a := generator(t, text)
+	b := generator(t, text)
+	for i := 0; i < 10; i++ {
+		x, _, _ := a.Next()
+		y, _, _ := b.Next()
+		if x != y {
+			t.Fatalf("same seed results in different payloads: %v != %v", x, y)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, err := New("{{.Device.Id")
+	if err == nil {
+		t.Error("expect parse error")
+	}
+	_, err = New("{{unknownFunc}}")
+	if err == nil {
+		t.Error("expect error for unknown function")
+	}
+	_, _, err = generator(t, "{{.Unknown}}").Next()
+	if err == nil {
+		t.Error("expect render error for unknown field")
+	}
+	_, _, err = generator(t, `{{index .Device.Id 99}}`).Next()
+	if err == nil {
+		t.Error("expect render error for index out of range")
+	}
+}
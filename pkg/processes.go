@@ -6,7 +6,13 @@ import (
 	"github.com/SENERGY-Platform/platform-connector-lib/security"
 	"github.com/SENERGY-Platform/process-deployment/lib/model/deploymentmodel/v2"
 	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/control"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/distribution"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/metrics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/tracking"
 	"log"
+	"math/rand"
 	"net/url"
 	"os"
 	"runtime/debug"
@@ -16,20 +22,147 @@ import (
 )
 
 type Process struct {
-	Id string `json:"id"`
+	Id       string `json:"id"`
+	DeviceId string `json:"device_id"`
+	LocalId  string `json:"local_id"`
+}
+
+// ProcessList are the deployed processes of an instance, stored at config.ProcessInfoLocation
+type ProcessList struct {
+	config  configuration.Config
+	mux     sync.Mutex
+	list    []Process
+	trigger func(processes []Process)     //starts triggering added processes; set by triggerProcesses
+	cancels map[string]context.CancelFunc //stop triggering the processes by id (see triggered)
+}
+
+func (this *ProcessList) Get() []Process {
+	if this == nil {
+		return nil
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return append([]Process{}, this.list...)
+}
+
+func (this *ProcessList) find(id string) (result Process, ok bool) {
+	if this == nil {
+		return result, false
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, p := range this.list {
+		if p.Id == id {
+			return p, true
+		}
+	}
+	return result, false
+}
+
+// AddDevices creates the processes of devices added by scaling (see CreateProcesses), stores them with the other processes and starts to trigger them
+func (this *ProcessList) AddDevices(fleet []FleetDevice, stat statistics.Interface) (created int, err error) {
+	if this == nil {
+		return 0, nil
+	}
+	processes, err := CreateProcesses(this.config, fleet, stat)
+	if len(processes) == 0 {
+		return 0, err
+	}
+	this.mux.Lock()
+	this.list = append(this.list, processes...)
+	storeErr := StoreProcesses(this.config, this.list)
+	trigger := this.trigger
+	this.mux.Unlock()
+	if err == nil {
+		err = storeErr
+	}
+	if trigger != nil {
+		trigger(processes)
+	}
+	return len(processes), err
+}
+
+// triggered returns the context of triggering the process with id, which is canceled when the process is removed (see RemoveDevices)
+func (this *ProcessList) triggered(ctx context.Context, id string) context.Context {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	ctx, cancel := context.WithCancel(ctx)
+	if this.cancels == nil {
+		this.cancels = map[string]context.CancelFunc{}
+	}
+	this.cancels[id] = cancel
+	return ctx
 }
 
-func EnsureProcesses(ctx context.Context, wg *sync.WaitGroup, config configuration.Config, hubId string) (processes []Process, err error) {
-	processes, err = LoadProcesses(config)
+// active returns the process with id if it is triggered and not removed
+func (this *ProcessList) active(id string) (result Process, ok bool) {
+	this.mux.Lock()
+	_, ok = this.cancels[id]
+	this.mux.Unlock()
+	if !ok {
+		return result, false
+	}
+	return this.find(id)
+}
+
+// setTrigger sets the trigger of added processes and returns the current processes, which have to be triggered by the caller
+func (this *ProcessList) setTrigger(trigger func(processes []Process)) []Process {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.trigger = trigger
+	return append([]Process{}, this.list...)
+}
+
+// RemoveDevices deletes the processes of the devices (see simulation.Remove) and stores the remaining processes
+func (this *ProcessList) RemoveDevices(localIds []string) (deleted int, err error) {
+	if this == nil {
+		return 0, nil
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	removed := map[string]bool{}
+	for _, id := range localIds {
+		removed[id] = true
+	}
+	remaining := []Process{}
+	obsolete := []Process{}
+	for _, p := range this.list {
+		if removed[p.LocalId] {
+			obsolete = append(obsolete, p)
+		} else {
+			remaining = append(remaining, p)
+		}
+	}
+	if len(obsolete) == 0 {
+		return 0, nil
+	}
+	for _, p := range obsolete {
+		if cancel, ok := this.cancels[p.Id]; ok {
+			cancel()
+			delete(this.cancels, p.Id)
+		}
+	}
+	deleted, err = DeleteProcesses(this.config, obsolete)
+	//keep processes that could not be deleted in the list to retry on shutdown
+	this.list = append(remaining, obsolete[deleted:]...)
+	storeErr := StoreProcesses(this.config, this.list)
+	if err == nil {
+		err = storeErr
+	}
+	return deleted, err
+}
+
+func EnsureProcesses(ctx context.Context, wg *sync.WaitGroup, config configuration.Config, fleet []FleetDevice, instance *metrics.Instance) (result *ProcessList, err error) {
+	processes, err := LoadProcesses(config)
 	if err != nil {
-		processes, err = CreateProcesses(config, hubId)
+		processes, err = CreateProcesses(config, fleet, instance.Stat)
 		if err != nil {
 			log.Println("ERROR: unable to create processes")
 			return
 		}
 		err = StoreProcesses(config, processes)
 		if err != nil {
-			deleteErr := DeleteProcesses(config, processes)
+			_, deleteErr := DeleteProcesses(config, processes)
 			log.Println("ERROR: unable to store processes", err, deleteErr)
 			return
 		}
@@ -37,12 +170,14 @@ func EnsureProcesses(ctx context.Context, wg *sync.WaitGroup, config configurati
 	if wg != nil {
 		wg.Add(1)
 	}
+	result = &ProcessList{config: config, list: processes}
 	go func() {
 		<-ctx.Done()
-		err := DeleteProcesses(config, processes)
+		deleted, err := DeleteProcesses(config, result.Get())
 		if err != nil {
 			log.Println("ERROR: unable to delete process", err)
 		}
+		instance.AddDeleted("processes", deleted)
 		if wg != nil {
 			wg.Done()
 		}
@@ -50,45 +185,46 @@ func EnsureProcesses(ctx context.Context, wg *sync.WaitGroup, config configurati
 	return
 }
 
-func CreateProcesses(config configuration.Config, hubId string) (processes []Process, err error) {
+func CreateProcesses(config configuration.Config, fleet []FleetDevice, stat statistics.Interface) (processes []Process, err error) {
 	token, err := security.GetOpenidPasswordToken(config.AuthUrl, config.AuthClientId, config.AuthClientSecret, config.UserName, config.Password)
 	if err != nil {
+		stat.Error(statistics.AuthFailed, "auth/token")
 		log.Println("ERROR:", err)
 		debug.PrintStack()
 		return processes, err
 	}
-	devices, err := GetHubDeviceIds(config, hubId, token.JwtToken(), false)
+	devices, err := SelectDevices(config, fleet, func(group configuration.DeviceGroup) int64 {
+		return group.OneProcessEveryNDevices
+	}, token.JwtToken())
 	if err != nil {
 		log.Println("ERROR:", err)
 		debug.PrintStack()
 		return processes, err
 	}
-	prepared, err := GetPreparedProcess(config, token.JwtToken())
+	prepared, err := GetPreparedProcess(config, token.JwtToken(), stat)
 	if err != nil {
 		log.Println("ERROR:", err)
 		debug.PrintStack()
 		return processes, err
 	}
-	for i, device := range devices {
-		if int64(i)%config.OneProcessEveryNDevices == 0 {
-			deployedProcess, err := CreateProcess(config, prepared, device, token.JwtToken())
-			if err != nil {
-				log.Println("ERROR:", err)
-				debug.PrintStack()
-				return processes, err
-			}
-			processes = append(processes, Process{Id: deployedProcess.Id})
+	for _, device := range devices {
+		deployedProcess, err := CreateProcess(config, prepared, device.Id, device.Group.ProcessServiceId, token.JwtToken(), stat)
+		if err != nil {
+			log.Println("ERROR:", err)
+			debug.PrintStack()
+			return processes, err
 		}
+		processes = append(processes, Process{Id: deployedProcess.Id, DeviceId: device.Id, LocalId: device.LocalId})
 	}
 	return
 }
 
-func DeleteProcesses(config configuration.Config, processes []Process) (err error) {
+func DeleteProcesses(config configuration.Config, processes []Process) (deleted int, err error) {
 	openidToken, err := security.GetOpenidPasswordToken(config.AuthUrl, config.AuthClientId, config.AuthClientSecret, config.UserName, config.Password)
 	if err != nil {
 		log.Println("ERROR:", err)
 		debug.PrintStack()
-		return err
+		return deleted, err
 	}
 	token := openidToken.JwtToken()
 	for _, p := range processes {
@@ -96,11 +232,12 @@ func DeleteProcesses(config configuration.Config, processes []Process) (err erro
 		if err != nil {
 			log.Println("ERROR:", err)
 			debug.PrintStack()
-			return err
+			return deleted, err
 		}
 		resp.Body.Close()
+		deleted++
 	}
-	return nil
+	return deleted, nil
 }
 
 func StoreProcesses(config configuration.Config, processes []Process) (err error) {
@@ -122,17 +259,17 @@ func LoadProcesses(config configuration.Config) (processes []Process, err error)
 	return
 }
 
-func GetPreparedProcess(config configuration.Config, token security.JwtToken) (result deploymentmodel.Deployment, err error) {
-	err = token.GetJSON(config.ProcessDeploymentUrl+"/v2/prepared-deployments/"+url.QueryEscape(config.ProcessModelId)+"?with_options=false", &result)
+func GetPreparedProcess(config configuration.Config, token security.JwtToken, stat statistics.Interface) (result deploymentmodel.Deployment, err error) {
+	err = getJSON(token, config.ProcessDeploymentUrl+"/v2/prepared-deployments/"+url.QueryEscape(config.ProcessModelId)+"?with_options=false", &result, stat, "process-deployment/prepared")
 	return
 }
 
-func CreateProcess(config configuration.Config, prepared deploymentmodel.Deployment, device string, token security.JwtToken) (result deploymentmodel.Deployment, err error) {
+func CreateProcess(config configuration.Config, prepared deploymentmodel.Deployment, device string, serviceId string, token security.JwtToken, stat statistics.Interface) (result deploymentmodel.Deployment, err error) {
 	prepared.Name = device
 	for i, element := range prepared.Elements {
 		if element.Task != nil {
 			element.Task.Selection.SelectedDeviceId = &device
-			element.Task.Selection.SelectedServiceId = &config.ProcessServiceId
+			element.Task.Selection.SelectedServiceId = &serviceId
 			prepared.Elements[i] = element
 		}
 		if element.TimeEvent != nil {
@@ -145,10 +282,112 @@ func CreateProcess(config configuration.Config, prepared deploymentmodel.Deploym
 			}
 		}
 	}
-	err = token.PostJSON(config.ProcessDeploymentUrl+"/v2/deployments", prepared, &result)
+	err = postJSON(token, config.ProcessDeploymentUrl+"/v2/deployments", prepared, &result, stat, "process-deployment/deploy")
 	return
 }
 
 func formatIsoDuration(dur time.Duration) string {
 	return "PT" + strings.ToUpper(dur.Truncate(time.Millisecond).String())
 }
+
+// triggerProcesses starts the processes with config.ProcessInterval until budget is exhausted; removed processes (see ProcessList.RemoveDevices) stop
+// and processes added by scaling (see ProcessList.AddDevices) are triggered as well. only started processes take from budget
+func triggerProcesses(ctx context.Context, config configuration.Config, list *ProcessList, rate func() float64, budget *control.Budget, commands *tracking.Commands, stat statistics.Interface) (err error) {
+	openIdToken, err := security.GetOpenidPasswordToken(config.AuthUrl, config.AuthClientId, config.AuthClientSecret, config.UserName, config.Password)
+	if err != nil {
+		stat.Error(statistics.AuthFailed, "auth/token")
+		log.Println("ERROR:", err)
+		debug.PrintStack()
+		return err
+	}
+	token := openIdToken.JwtToken()
+	interval, err := time.ParseDuration(config.ProcessInterval)
+	if err != nil {
+		log.Println("ERROR: unable to parse emitter_interval", config.EmitterInterval, err)
+		return err
+	}
+
+	arrival, err := distribution.New(config.ProcessDistribution)
+	if err != nil {
+		log.Println("ERROR: invalid process_distribution", err)
+		return err
+	}
+
+	checker, err := newCompletionChecker(ctx, config, token, stat)
+	if err != nil {
+		log.Println("ERROR: invalid process completion check config", err)
+		return err
+	}
+
+	var trigger func(processes []Process)
+	if config.ProcessStartOnce {
+		trigger = func(processes []Process) {
+			for _, process := range processes {
+				processCtx := list.triggered(ctx, process.Id)
+				go func(p Process) {
+					//wait for random time between now and interval to offset emitter
+					r := rand.New(rand.NewSource(distribution.Seed(config.Seed, p.Id)))
+					select {
+					case <-processCtx.Done():
+						return
+					case <-time.After(randomOffset(r, interval)):
+					}
+					if budget.Take() {
+						TriggerProcess(config, p, token, commands, checker, stat)
+					}
+				}(process)
+			}
+		}
+	} else {
+		messages := make(chan Message, len(list.Get()))
+		trigger = func(processes []Process) {
+			for _, process := range processes {
+				r := rand.New(rand.NewSource(distribution.Seed(config.Seed, process.Id)))
+				Emitter(list.triggered(ctx, process.Id), nil, messages, Source{Info: map[string]string{ProcessIdKey: process.Id}, Message: func() (string, uint64, error) { return "", 0, nil }}, interval, arrival, r, rate, statistics.Void{})
+			}
+		}
+		//send event messages created by Emitter()
+		go func() {
+			for m := range messages {
+				process, ok := list.active(m.Info[ProcessIdKey])
+				if !ok || !budget.Take() {
+					continue
+				}
+				TriggerProcess(config, process, token, commands, checker, stat)
+			}
+		}()
+	}
+	trigger(list.setTrigger(trigger))
+	return nil
+}
+
+type ProcessInstance struct {
+	Id string `json:"id"`
+}
+
+// TriggerProcess starts a process instance; the expected command to the device of the process is registered in commands
+func TriggerProcess(config configuration.Config, process Process, token security.JwtToken, commands *tracking.Commands, checker *completionChecker, stat statistics.Interface) {
+	triggered := time.Now()
+	if process.LocalId != "" {
+		commands.Triggered(process.LocalId, triggered)
+	}
+	resp, err := token.Get(config.ProcessEngineWrapperUrl + "/v2/deployments/" + url.QueryEscape(process.Id) + "/start")
+	if err != nil {
+		//on unexpected status codes the response is returned with a closed body
+		log.Println("ERROR: unable to start process", process.Id, err)
+		stat.Error(statistics.HttpErrorCategory(resp), "process-engine/start")
+		return
+	}
+	defer resp.Body.Close()
+	stat.ProcessTriggered()
+	if checker != nil {
+		instance := ProcessInstance{}
+		err = json.NewDecoder(resp.Body).Decode(&instance)
+		if err != nil || instance.Id == "" {
+			log.Println("WARNING: unable to read started process instance; no completion check", err)
+			stat.Error(statistics.Unmarshal, "process-engine/start")
+			return
+		}
+		go checker.Watch(instance.Id, triggered)
+	}
+}
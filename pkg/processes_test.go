@@ -0,0 +1,32 @@
+package pkg
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemovedProcessesStop(t *testing.T) {
+	config := configuration.Config{AuthUrl: "http://127.0.0.1:1", ProcessInfoLocation: filepath.Join(t.TempDir(), "processes.json")}
+	list := &ProcessList{config: config, list: []Process{{Id: "a", LocalId: "device_a"}, {Id: "b", LocalId: "device_b"}}}
+	a := list.triggered(context.Background(), "a")
+	b := list.triggered(context.Background(), "b")
+	_, err := list.RemoveDevices([]string{"device_a"})
+	if err == nil {
+		t.Error("expect error of the unreachable process deployment")
+	}
+	if a.Err() == nil || b.Err() != nil {
+		t.Error("expect only the trigger of the removed process to stop")
+	}
+	//a process which could not be deleted stays in the list for the cleanup but is no longer triggered
+	if _, ok := list.find("a"); !ok {
+		t.Error("expect the undeleted process in the list")
+	}
+	if _, ok := list.active("a"); ok {
+		t.Error("expect no trigger of a removed process")
+	}
+	if _, ok := list.active("b"); !ok {
+		t.Error("expect the trigger of the remaining process")
+	}
+}
@@ -0,0 +1,31 @@
+package report
+
+import (
+	"encoding/csv"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/sinks"
+	"os"
+)
+
+// WriteCsv writes the interval time series of all instances
+func (this Report) WriteCsv(location string) error {
+	file, err := os.OpenFile(location, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	err = writer.Write(sinks.CsvHeader)
+	if err != nil {
+		return err
+	}
+	for _, instance := range this.Instances {
+		for _, interval := range instance.Intervals {
+			err = writer.Write(sinks.CsvRow(instance.Hub, interval))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
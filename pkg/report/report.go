@@ -0,0 +1,175 @@
+package report
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/metrics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/scenario"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/sinks"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/slo"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"log"
+	"os"
+	"time"
+)
+
+// MaxStatisticsWait limits the wait for the final statistics of the instances
+const MaxStatisticsWait = 10 * time.Second
+
+const Redacted = "***"
+
+type Report struct {
+	RunId           string                `json:"run_id"`
+	Seed            int64                 `json:"seed"`
+	Start           time.Time             `json:"start"`
+	End             time.Time             `json:"end"`
+	DurationSeconds float64               `json:"duration_seconds"`
+	Config          configuration.Config  `json:"config"`
+	Run             statistics.RunSummary `json:"run"` //merged over all instances
+	Instances       []Instance            `json:"instances"`
+	Phases          []scenario.Result     `json:"phases,omitempty"` //if a scenario is configured
+	Slo             []slo.Result          `json:"slo"`
+}
+
+// ExitCode returns the exit code of the first failed result of the run or, if the run passed, of the phases;
+// ExitScenario if all passed but a phase could not be set up
+func (this Report) ExitCode() int {
+	code := slo.ExitCode(this.Slo)
+	for _, phase := range this.Phases {
+		if code != slo.ExitOk {
+			break
+		}
+		code = slo.ExitCode(phase.Slo)
+	}
+	for _, phase := range this.Phases {
+		if code != slo.ExitOk {
+			break
+		}
+		if len(phase.Errors) > 0 {
+			code = slo.ExitScenario
+		}
+	}
+	return code
+}
+
+type Instance struct {
+	Hub       string                       `json:"hub"`
+	Devices   int64                        `json:"devices"`
+	Processes int64                        `json:"processes"`
+	Pipelines int64                        `json:"pipelines"`
+	Deleted   map[string]int64             `json:"deleted"`
+	Startup   metrics.Startup              `json:"startup"`
+	Shutdown  []metrics.ShutdownStep       `json:"shutdown"`
+	Run       statistics.RunSummary        `json:"run"`
+	Intervals []statistics.IntervalSummary `json:"intervals"`
+}
+
+// New collects the report of all registered instances; it waits for the final statistics which are recorded when the run context is done
+func New(config configuration.Config, start time.Time, end time.Time) (result Report) {
+	result = Report{
+		RunId:           config.RunId,
+		Seed:            config.Seed,
+		Start:           start,
+		End:             end,
+		DurationSeconds: end.Sub(start).Seconds(),
+		Config:          Redact(config),
+		Instances:       []Instance{},
+	}
+	stats := []*statistics.Implementation{}
+	timeout := time.After(MaxStatisticsWait)
+	hubs := []string{}
+	runs := []statistics.RunSummary{}
+	for _, instance := range metrics.Instances() {
+		if instance.Stat == nil {
+			//an instance which failed before its statistics were registered only has its startup
+			result.Instances = append(result.Instances, Instance{Hub: instance.Name, Startup: instance.Startup()})
+			continue
+		}
+		select {
+		case <-instance.Stat.Done():
+		case <-timeout:
+			log.Println("WARNING: final statistics of", instance.Name, "not available; report current state")
+		}
+		stats = append(stats, instance.Stat)
+		result.Instances = append(result.Instances, Instance{
+			Hub:       instance.Name,
+			Devices:   instance.Devices(),
+			Processes: instance.Processes(),
+			Pipelines: instance.Pipelines(),
+			Deleted:   instance.Deleted(),
+			Startup:   instance.Startup(),
+			Shutdown:  instance.ShutdownSteps(),
+			Run:       instance.Stat.Run(),
+			Intervals: instance.Stat.Intervals(),
+		})
+		hubs = append(hubs, instance.Name)
+		runs = append(runs, result.Instances[len(result.Instances)-1].Run)
+	}
+	result.Run = statistics.Summarize(stats)
+	result.Run.Hubs = statistics.HubBreakdown(hubs, runs)
+	result.Run.Hubs.Log("run hub breakdown")
+	return result
+}
+
+// Histograms returns the latency histograms of all registered instances, which a distributed worker sends with its report (see statistics.Combine)
+func Histograms() *statistics.RunHistograms {
+	stats := []*statistics.Implementation{}
+	for _, instance := range metrics.Instances() {
+		if instance.Stat != nil {
+			stats = append(stats, instance.Stat)
+		}
+	}
+	return statistics.SummarizeHistograms(stats)
+}
+
+// Redact removes secrets from config
+func Redact(config configuration.Config) configuration.Config {
+	if config.Password != "" {
+		config.Password = Redacted
+	}
+	if config.AuthClientSecret != "" {
+		config.AuthClientSecret = Redacted
+	}
+	if config.ApiToken != "" {
+		config.ApiToken = Redacted
+	}
+	statisticsSinks := []sinks.Config{}
+	for _, sink := range config.StatisticsSinks {
+		if sink.Token != "" {
+			sink.Token = Redacted
+		}
+		statisticsSinks = append(statisticsSinks, sink)
+	}
+	config.StatisticsSinks = statisticsSinks
+	return config
+}
+
+func (this Report) WriteJson(location string) error {
+	file, err := os.OpenFile(location, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(this)
+}
+
+// Write writes the json report to config.ReportLocation and the csv time series to config.ReportCsvLocation, if configured
+func (this Report) Write(config configuration.Config) (err error) {
+	if config.ReportLocation != "" {
+		err = this.WriteJson(config.ReportLocation)
+		if err != nil {
+			return err
+		}
+		log.Println("INFO: report written to", config.ReportLocation)
+	}
+	if config.ReportCsvLocation != "" {
+		err = this.WriteCsv(config.ReportCsvLocation)
+		if err != nil {
+			return err
+		}
+		log.Println("INFO: report time series written to", config.ReportCsvLocation)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package report
+
+import (
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/scenario"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/slo"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	failed := []slo.Result{{Category: slo.LossCategory, Passed: false}}
+	tests := []struct {
+		name   string
+		report Report
+		code   int
+	}{
+		{"ok", Report{Phases: []scenario.Result{{Name: "a"}}}, slo.ExitOk},
+		{"phase setup error", Report{Phases: []scenario.Result{{Name: "a"}, {Name: "b", Errors: []string{"unable to add devices"}}}}, slo.ExitScenario},
+		{"run slo before setup error", Report{Slo: failed, Phases: []scenario.Result{{Name: "a", Errors: []string{"error"}}}}, slo.ExitLoss},
+		{"phase slo before setup error", Report{Phases: []scenario.Result{{Name: "a", Errors: []string{"error"}}, {Name: "b", Slo: failed}}}, slo.ExitLoss},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if code := test.report.ExitCode(); code != test.code {
+				t.Errorf("expect exit code %v, got %v", test.code, code)
+			}
+		})
+	}
+}
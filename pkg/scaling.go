@@ -0,0 +1,230 @@
+package pkg
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/platform-connector-lib/security"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/client"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/metrics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	senergyclient "github.com/SENERGY-Platform/senergy-platform-connector/test/client"
+	"log"
+	"sync"
+)
+
+// scaler adds and removes devices of a running instance (see control.Instance.ScaleUp and control.Instance.ScaleDown)
+// and stores the resulting fleet in the client info, which is restored on restarts (see RestoreFleet)
+type scaler struct {
+	config    configuration.Config
+	c         client.Client
+	sim       *simulation
+	stat      *statistics.Implementation
+	instance  *metrics.Instance
+	processes *ProcessList                         //nil without processes
+	analytics *AnalyticsList                       //nil without analytics
+	detached  []senergyclient.DeviceRepresentation //removed from the hub but not deleted
+	mux       sync.Mutex
+}
+
+// Devices returns all devices created by the instance: the current fleet and the removed but not deleted devices
+func (this *scaler) Devices() []senergyclient.DeviceRepresentation {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return append(GetDevices(this.sim.Fleet()), this.detached...)
+}
+
+// setProcesses calls ensure with the current fleet and uses the resulting processes for scaling;
+// scaling waits until the processes are created, so that no added device is missed
+func (this *scaler) setProcesses(ensure func(fleet []FleetDevice) (*ProcessList, error)) (processes *ProcessList, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	processes, err = ensure(this.sim.Fleet())
+	if err != nil {
+		return nil, err
+	}
+	this.processes = processes
+	return processes, nil
+}
+
+// setAnalytics calls ensure with the current fleet and uses the resulting pipelines for scaling (see setProcesses)
+func (this *scaler) setAnalytics(ensure func(fleet []FleetDevice) (*AnalyticsList, error)) (analytics *AnalyticsList, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	analytics, err = ensure(this.sim.Fleet())
+	if err != nil {
+		return nil, err
+	}
+	this.analytics = analytics
+	return analytics, nil
+}
+
+// Up provisions count new devices, adds them to the hub, starts their command listeners and emitters and creates their processes and analytics
+func (this *scaler) Up(count int) (devices int, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if count <= 0 {
+		return len(this.sim.Fleet()), errors.New("count must be positive")
+	}
+	added, err := ExtendFleet(this.config, this.sim.Fleet(), count)
+	if err != nil {
+		return len(this.sim.Fleet()), err
+	}
+	this.attach(added)
+	defer this.store()
+	err = this.c.AddDevices(GetDevices(added))
+	if err != nil {
+		log.Println("ERROR: unable to provision", count, "devices", err)
+		//devices may be partially created
+		this.detached = append(this.detached, GetDevices(added)...)
+		return len(this.sim.Fleet()), err
+	}
+	uris := []string{}
+	for _, d := range added {
+		uris = append(uris, d.Uri)
+	}
+	this.stat.AddTrackedDevices(uris)
+	err = this.sim.Add(added)
+	fleet := this.sim.Fleet()
+	devices = len(fleet)
+	this.instance.SetDevices(devices)
+	if err != nil {
+		log.Println("ERROR: unable to start added devices", err)
+		this.detachMissing(added, fleet)
+		return devices, err
+	}
+	created, err := this.processes.AddDevices(added, this.stat)
+	this.instance.SetProcesses(len(this.processes.Get()))
+	if err != nil {
+		log.Println("ERROR: unable to create processes of added devices", err)
+		return devices, err
+	}
+	pipelines, err := this.analytics.AddDevices(added, this.stat)
+	this.instance.SetPipelines(len(this.analytics.Get()))
+	if err != nil {
+		log.Println("ERROR: unable to create analytics of added devices", err)
+		return devices, err
+	}
+	log.Println("INFO: scaled", this.config.HubPrefix, "up by", count, "to", devices, "devices with", created, "processes and", pipelines, "pipelines")
+	return devices, nil
+}
+
+// Down stops count devices (see ShrinkFleet), removes them from the hub and deletes their processes and analytics; with remove the devices are deleted
+func (this *scaler) Down(count int, remove bool) (devices int, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if count <= 0 {
+		return len(this.sim.Fleet()), errors.New("count must be positive")
+	}
+	selected, err := ShrinkFleet(this.config, this.sim.Fleet(), count)
+	if err != nil {
+		return len(this.sim.Fleet()), err
+	}
+	removed := GetDevices(this.sim.Remove(selected))
+	devices = len(this.sim.Fleet())
+	this.instance.SetDevices(devices)
+	//removed devices stay detached until they are deleted, so that they are deleted on shutdown if a step fails
+	this.detached = append(this.detached, removed...)
+	defer this.store()
+	uris := []string{}
+	for _, d := range removed {
+		uris = append(uris, d.Uri)
+	}
+	err = this.c.RemoveDevices(uris)
+	if err != nil {
+		log.Println("ERROR: unable to remove devices from hub", err)
+		return devices, err
+	}
+	deleted, err := this.processes.RemoveDevices(uris)
+	this.instance.AddDeleted("processes", deleted)
+	this.instance.SetProcesses(len(this.processes.Get()))
+	if err != nil {
+		log.Println("ERROR: unable to delete processes of removed devices", err)
+		return devices, err
+	}
+	deleted, err = this.analytics.RemoveDevices(uris, this.stat)
+	this.instance.AddDeleted("pipelines", deleted)
+	this.instance.SetPipelines(len(this.analytics.Get()))
+	if err != nil {
+		log.Println("ERROR: unable to delete analytics of removed devices", err)
+		return devices, err
+	}
+	if remove {
+		token, err := security.GetOpenidPasswordToken(this.config.AuthUrl, this.config.AuthClientId, this.config.AuthClientSecret, this.config.UserName, this.config.Password)
+		if err != nil {
+			this.stat.Error(statistics.AuthFailed, "auth/token")
+			log.Println("ERROR:", err)
+			return devices, err
+		}
+		this.delete(removed, token.JwtToken())
+	}
+	log.Println("INFO: scaled", this.config.HubPrefix, "down by", len(removed), "to", devices, "devices")
+	return devices, nil
+}
+
+// delete deletes the devices and keeps the devices which could not be deleted detached
+func (this *scaler) delete(devices []senergyclient.DeviceRepresentation, token security.JwtToken) {
+	deleted := map[string]bool{}
+	for _, d := range devices {
+		err := DeleteDevice(this.config, d.Uri, token)
+		if err != nil {
+			log.Println("ERROR: ", err)
+		} else {
+			deleted[d.Uri] = true
+		}
+	}
+	this.instance.AddDeleted("devices", len(deleted))
+	detached := []senergyclient.DeviceRepresentation{}
+	for _, d := range this.detached {
+		if !deleted[d.Uri] {
+			detached = append(detached, d)
+		}
+	}
+	this.detached = detached
+}
+
+// attach removes re-added devices from the detached devices
+func (this *scaler) attach(devices []FleetDevice) {
+	added := map[string]bool{}
+	for _, d := range devices {
+		added[d.Uri] = true
+	}
+	detached := []senergyclient.DeviceRepresentation{}
+	for _, d := range this.detached {
+		if !added[d.Uri] {
+			detached = append(detached, d)
+		}
+	}
+	this.detached = detached
+}
+
+// detachMissing detaches the provisioned devices which could not be added to the fleet
+func (this *scaler) detachMissing(devices []FleetDevice, fleet []FleetDevice) {
+	running := map[string]bool{}
+	for _, d := range fleet {
+		running[d.Uri] = true
+	}
+	for _, d := range devices {
+		if !running[d.Uri] {
+			this.detached = append(this.detached, d.DeviceRepresentation)
+		}
+	}
+}
+
+// store writes the current fleet and the detached devices to the client info
+func (this *scaler) store() {
+	err := StoreClientInfo(this.config, ClientInfo{Id: this.c.HubId(), Fleet: GetFleetEntries(this.sim.Fleet()), Detached: this.detached})
+	if err != nil {
+		log.Println("WARNING: unable to store scaled fleet at", this.config.ClientInfoLocation, err)
+	}
+}
+
+// forget resets the client info to the configured device groups after the devices are deleted on shutdown
+func (this *scaler) forget() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.detached = nil
+	err := StoreClientInfo(this.config, ClientInfo{Id: this.c.HubId()})
+	if err != nil {
+		log.Println("WARNING: unable to reset client info at", this.config.ClientInfoLocation, err)
+	}
+}
@@ -0,0 +1,169 @@
+package scenario
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/control"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/metrics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/slo"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of a phase in the report
+type Result struct {
+	Index     int                   `json:"index"` //1 based
+	Name      string                `json:"name"`
+	Start     time.Time             `json:"start"`
+	End       time.Time             `json:"end"`
+	SetupTime time.Duration         `json:"setup_time"` //provisioning and deployment at the start of the phase
+	Aborted   bool                  `json:"aborted"`    //the run ended during the phase
+	Errors    []string              `json:"errors,omitempty"`
+	Run       statistics.RunSummary `json:"run"` //merged over all instances
+	Slo       []slo.Result          `json:"slo"`
+}
+
+// Runner switches the registered instances (see pkg/control) through the phases of a scenario
+type Runner struct {
+	scenario Scenario
+	mux      sync.Mutex
+	results  []Result
+	done     chan struct{}
+}
+
+func NewRunner(scenario Scenario) *Runner {
+	return &Runner{scenario: scenario, done: make(chan struct{})}
+}
+
+// Run starts the phases in order; Done is closed after the last phase or, if ctx is done first, after the result of the current phase is recorded
+func (this *Runner) Run(ctx context.Context) {
+	go func() {
+		defer close(this.done)
+		for i, phase := range this.scenario.Phases {
+			if ctx.Err() != nil {
+				return
+			}
+			result := this.run(ctx, i, phase)
+			this.mux.Lock()
+			this.results = append(this.results, result)
+			this.mux.Unlock()
+		}
+		log.Println("INFO: scenario finished")
+	}()
+}
+
+func (this *Runner) Done() <-chan struct{} {
+	return this.done
+}
+
+// Results returns the results of the finished phases
+func (this *Runner) Results() []Result {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return append([]Result{}, this.results...)
+}
+
+func (this *Runner) run(ctx context.Context, index int, phase Phase) (result Result) {
+	result = Result{Index: index + 1, Name: phase.name(index), Start: time.Now()}
+	label := "scenario phase " + strconv.Itoa(result.Index) + "/" + strconv.Itoa(len(this.scenario.Phases)) + " " + result.Name
+	log.Println("INFO: start", label)
+	for _, instance := range metrics.Instances() {
+		if instance.Stat != nil {
+			instance.Stat.StartPhase(result.Index, result.Name)
+		}
+	}
+	result.Errors = setup(phase)
+	result.SetupTime = time.Since(result.Start)
+	for _, err := range result.Errors {
+		log.Println("WARNING:", label, err)
+	}
+
+	duration, _ := phase.duration()
+	if duration > 0 {
+		log.Println("INFO: run", label, "for", duration.String(), "after setup of", result.SetupTime.String())
+		t := time.NewTimer(duration)
+		select {
+		case <-ctx.Done():
+			result.Aborted = true
+			t.Stop()
+		case <-t.C:
+		}
+	}
+	result.End = time.Now()
+	result.Run = summary()
+	var err error
+	result.Slo, err = slo.Evaluate(phase.Thresholds, result.Run)
+	if err != nil {
+		log.Println("ERROR: unable to evaluate thresholds of", label, err)
+	}
+	for _, r := range result.Slo {
+		log.Println("SLO:", result.Name+":", r.String())
+	}
+	if result.Aborted {
+		log.Println("WARNING:", label, "aborted after", result.End.Sub(result.Start).String())
+	} else {
+		log.Println("INFO: finished", label, "after", result.End.Sub(result.Start).String())
+	}
+	return result
+}
+
+// setup scales the fleet, switches the subsystems and sets the load profile of all instances concurrently
+func setup(phase Phase) (errors []string) {
+	profile, _ := phase.profile()
+	mux := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, instance := range control.Instances() {
+		instance := instance
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			failed := func(action string, err error) {
+				mux.Lock()
+				defer mux.Unlock()
+				errors = append(errors, instance.Name+": "+action+": "+err.Error())
+			}
+			if phase.Devices > 0 {
+				err := scale(instance, int(phase.Devices))
+				if err != nil {
+					failed("scale to "+strconv.FormatInt(phase.Devices, 10)+" devices", err)
+				}
+			}
+			for _, subsystem := range control.Subsystems {
+				err := instance.SetEnabled(subsystem, phase.enabled(subsystem))
+				if err != nil {
+					failed("switch "+subsystem, err)
+				}
+			}
+			instance.SetProfile(profile)
+		}()
+	}
+	wg.Wait()
+	return errors
+}
+
+// scale adds or removes devices of instance to reach count; removed devices are deleted on cleanup
+func scale(instance *control.Instance, count int) (err error) {
+	if instance.Metrics == nil {
+		return control.ErrScalingUnsupported
+	}
+	current := int(instance.Metrics.Devices())
+	switch {
+	case count > current:
+		_, err = instance.AddDevices(count - current)
+	case count < current:
+		_, err = instance.RemoveDevices(current-count, false)
+	}
+	return err
+}
+
+func summary() statistics.RunSummary {
+	stats := []*statistics.Implementation{}
+	for _, instance := range metrics.Instances() {
+		if instance.Stat != nil {
+			stats = append(stats, instance.Stat)
+		}
+	}
+	return statistics.SummarizePhase(stats)
+}
@@ -0,0 +1,57 @@
+package scenario
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/control"
+	"testing"
+	"time"
+)
+
+func TestRunner(t *testing.T) {
+	instance := control.Register(&control.Instance{Name: "scenario_test"})
+	defer control.Unregister(instance.Name)
+	runner := NewRunner(Scenario{Phases: []Phase{
+		{Name: "events", Subsystems: []string{control.Events}},
+		{Name: "commands", Duration: "1h", Subsystems: []string{control.Commands}},
+		{Name: "never"},
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runner.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for (len(runner.Results()) < 1 || instance.Enabled(control.Events)) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if instance.Enabled(control.Events) || !instance.Enabled(control.Commands) || instance.Enabled(control.Processes) {
+		t.Errorf("expect only commands enabled in the second phase, got disabled %v", instance.Disabled())
+	}
+	cancel()
+	select {
+	case <-runner.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expect the runner to end with the context")
+	}
+	results := runner.Results()
+	if len(results) != 2 || results[0].Name != "events" || results[1].Name != "commands" || results[1].Index != 2 {
+		t.Fatalf("expect the results of the first two phases in order, got %#v", results)
+	}
+	if results[0].Aborted || !results[1].Aborted {
+		t.Error("expect only the second phase to be aborted")
+	}
+	if len(results[0].Errors) != 0 {
+		t.Error("unexpected setup errors", results[0].Errors)
+	}
+}
+
+func TestRunnerSetupErrors(t *testing.T) {
+	instance := control.Register(&control.Instance{Name: "scenario_test_errors"})
+	defer control.Unregister(instance.Name)
+	runner := NewRunner(Scenario{Phases: []Phase{{Name: "scale", Devices: 10}}})
+	runner.Run(context.Background())
+	<-runner.Done()
+	results := runner.Results()
+	if len(results) != 1 || len(results[0].Errors) != 1 || results[0].Aborted {
+		t.Errorf("expect a setup error of the instance without scaling, got %#v", results)
+	}
+}
@@ -0,0 +1,119 @@
+package scenario
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/control"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/loadprofile"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/slo"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Scenario lists phases which are run in order, e.g.
+//
+//	{"phases": [
+//		{"name": "provision", "devices": 5000, "subsystems": []},
+//		{"name": "warmup", "duration": "5m", "rate": {"type": "constant", "to": 0.1}, "subsystems": ["events"]},
+//		{"name": "ramp", "duration": "10m", "rate": {"type": "ramp", "from": 0.1, "to": 1, "duration": "10m"}, "subsystems": ["events", "commands"]},
+//		{"name": "hold", "duration": "1h", "thresholds": [{"metric": "publish_latency.p99", "condition": "< 200ms"}]},
+//		{"name": "spike", "duration": "2m", "rate": {"type": "constant", "to": 5}},
+//		{"name": "cooldown", "duration": "5m", "rate": {"type": "ramp", "from": 1, "to": 0.1, "duration": "5m"}, "subsystems": ["events"]}
+//	]}
+//
+// after the last phase the run ends like at the end of duration: the emitters stop and the resources are removed (delete_on_shutdown)
+type Scenario struct {
+	Phases []Phase `json:"phases"`
+}
+
+type Phase struct {
+	Name       string             `json:"name"`       //defaults to phase_<index>
+	Duration   string             `json:"duration"`   //starts after the setup of the phase; empty or - ends the phase after its setup
+	Devices    int64              `json:"devices"`    //fleet size of each instance, provisioned or removed at the start of the phase; 0 keeps the fleet
+	Rate       loadprofile.Config `json:"rate"`       //load profile of the phase, starting after its setup; an empty type keeps the configured load_profile
+	Subsystems []string           `json:"subsystems"` //enabled subsystems (events, commands, processes, analytics); missing enables all, [] disables all
+	Thresholds []slo.Threshold    `json:"thresholds"` //evaluated against the statistics of the phase at its end
+}
+
+func Load(location string) (scenario Scenario, err error) {
+	file, err := os.Open(location)
+	if err != nil {
+		return scenario, err
+	}
+	defer file.Close()
+	err = json.NewDecoder(file).Decode(&scenario)
+	if err != nil {
+		return scenario, err
+	}
+	return scenario, scenario.Validate()
+}
+
+func (this Scenario) Validate() error {
+	if len(this.Phases) == 0 {
+		return errors.New("scenario without phases")
+	}
+	for i, phase := range this.Phases {
+		err := phase.validate()
+		if err != nil {
+			return errors.New("invalid scenario phase " + phase.name(i) + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+func (this Phase) validate() error {
+	duration, err := this.duration()
+	if err != nil {
+		return err
+	}
+	if duration < 0 {
+		return errors.New("expect duration >= 0")
+	}
+	if this.Devices < 0 {
+		return errors.New("expect devices >= 0")
+	}
+	if _, err := this.profile(); err != nil {
+		return err
+	}
+	for _, subsystem := range this.Subsystems {
+		if !control.IsSubsystem(subsystem) {
+			return errors.New("unknown subsystem " + subsystem)
+		}
+	}
+	return slo.Validate(this.Thresholds)
+}
+
+func (this Phase) name(index int) string {
+	if this.Name == "" {
+		return "phase_" + strconv.Itoa(index+1)
+	}
+	return this.Name
+}
+
+func (this Phase) duration() (time.Duration, error) {
+	if this.Duration == "" || this.Duration == "-" {
+		return 0, nil
+	}
+	return time.ParseDuration(this.Duration)
+}
+
+// profile returns nil if the phase keeps the configured load profile
+func (this Phase) profile() (loadprofile.Profile, error) {
+	if this.Rate.Type == "" {
+		return nil, nil
+	}
+	return loadprofile.New(this.Rate)
+}
+
+func (this Phase) enabled(subsystem string) bool {
+	if this.Subsystems == nil {
+		return true
+	}
+	for _, s := range this.Subsystems {
+		if s == subsystem {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package scenario
+
+import (
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/loadprofile"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/slo"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		phase Phase
+		valid bool
+	}{
+		{"empty", Phase{}, true},
+		{"complete", Phase{Name: "hold", Duration: "1h", Devices: 10, Rate: loadprofile.Config{Type: "constant", To: 1}, Subsystems: []string{"events", "commands"}, Thresholds: []slo.Threshold{{Metric: "publish_latency.p99", Condition: "< 200ms"}}}, true},
+		{"no duration", Phase{Duration: "-"}, true},
+		{"negative duration", Phase{Duration: "-5m"}, false},
+		{"invalid duration", Phase{Duration: "soon"}, false},
+		{"negative devices", Phase{Devices: -1}, false},
+		{"unknown profile", Phase{Rate: loadprofile.Config{Type: "unknown"}}, false},
+		{"unknown subsystem", Phase{Subsystems: []string{"unknown"}}, false},
+		{"invalid threshold", Phase{Thresholds: []slo.Threshold{{Metric: "unknown", Condition: "< 1"}}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Scenario{Phases: []Phase{{}, test.phase}}.Validate()
+			if (err == nil) != test.valid {
+				t.Errorf("expect valid %v, got %v", test.valid, err)
+			}
+		})
+	}
+	if (Scenario{}).Validate() == nil {
+		t.Error("expect error for a scenario without phases")
+	}
+}
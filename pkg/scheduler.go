@@ -0,0 +1,139 @@
+package pkg
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/control"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/distribution"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type Source struct {
+	Info    map[string]string
+	Message func() (message string, seq uint64, err error)
+	Qos     byte
+	Stream  int                       //see tracking.Tracker.Register()
+	Budget  *control.Budget           //limits the messages of all sources sharing it; nil is unlimited
+	Arrival distribution.Distribution //inter-arrival distribution of the service in a Scheduler; nil uses the distribution of the Scheduler
+}
+
+// Sources is the changeable list of sources of a Scheduler (see simulation.Add and simulation.Remove)
+type Sources struct {
+	mux  sync.RWMutex
+	list []Source
+}
+
+func (this *Sources) Add(sources ...Source) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.list = append(this.list, sources...)
+}
+
+// RemoveDevice removes all sources of the device
+func (this *Sources) RemoveDevice(deviceUri string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	list := make([]Source, 0, len(this.list))
+	for _, source := range this.list {
+		if source.Info[DeviceUriKey] != deviceUri {
+			list = append(list, source)
+		}
+	}
+	this.list = list
+}
+
+// Get returns the source at index modulo the count of sources; ok is false if there is no source
+func (this *Sources) Get(index int) (source Source, ok bool) {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	if len(this.list) == 0 {
+		return source, false
+	}
+	return this.list[index%len(this.list)], true
+}
+
+func (this *Sources) List() []Source {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	return append([]Source{}, this.list...)
+}
+
+// MaxScheduleInterval limits the mean time between two messages of a Scheduler with a very small rate
+const MaxScheduleInterval = 24 * time.Hour
+
+// Scheduler emits messages in an open loop with a global target rate (events per second), spread round-robin over all sources.
+// the schedule does not wait for the consumer of out: if out is full, the scheduled message is counted as missed and the schedule continues.
+// the time after a message is drawn from the Arrival of its source (or arrival) using r; rate() (see loadprofile.Profile) scales the target rate over time
+// and is rechecked at least every RateCheckInterval.
+// the scheduler stops when ctx is done; wg (may be nil) waits for it
+func Scheduler(ctx context.Context, wg *sync.WaitGroup, out chan<- Message, sources *Sources, targetRate float64, arrival distribution.Distribution, r *rand.Rand, rate func() float64, stat statistics.Interface) {
+	if targetRate <= 0 {
+		return
+	}
+	if wg != nil {
+		wg.Add(1)
+	}
+	go func() {
+		if wg != nil {
+			defer wg.Done()
+		}
+		index := 0
+		next := time.Now()
+		last := 0.0 //factor of the current wait
+		t := time.NewTimer(0)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				factor := rate()
+				if factor <= 0 {
+					next = time.Now().Add(RateCheckInterval)
+					last = 0
+					t.Reset(RateCheckInterval)
+					continue
+				}
+				mean := time.Duration(math.Min(float64(time.Second)/(targetRate*factor), float64(MaxScheduleInterval)))
+				now := time.Now()
+				if last > 0 && factor != last && next.After(now) {
+					//the rate changed during the wait: the rest of the wait elapses with the new factor
+					next = now.Add(time.Duration(float64(next.Sub(now)) * last / factor))
+				}
+				last = factor
+				for !next.After(now) {
+					source, ok := sources.Get(index)
+					if !ok {
+						//no devices (e.g. scaled down to 0)
+						next = now.Add(RateCheckInterval)
+						break
+					}
+					index = index + 1
+					m, ok := newMessage(source)
+					if ok {
+						m.Scheduled = next
+						select {
+						case out <- m:
+							emitted(m, stat)
+						default:
+							stat.EventMissed()
+						}
+					}
+					gap := arrival
+					if source.Arrival != nil {
+						gap = source.Arrival
+					}
+					next = next.Add(gap.Next(r, mean))
+				}
+				wait := time.Until(next)
+				if wait > RateCheckInterval {
+					wait = RateCheckInterval
+				}
+				t.Reset(wait)
+			}
+		}
+	}()
+}
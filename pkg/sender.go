@@ -0,0 +1,117 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	platform_connector_lib "github.com/SENERGY-Platform/platform-connector-lib"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/client"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/tracking"
+	"log"
+	"sync"
+	"time"
+)
+
+const DefaultEmitterQueueSize = 10000
+
+// QueueDepthSampleInterval defines how often the fill level of the emitter queue is reported to statistics
+const QueueDepthSampleInterval = time.Second
+
+func NewMessageQueue(config configuration.Config) chan Message {
+	size := config.EmitterQueueSize
+	if size <= 0 {
+		size = DefaultEmitterQueueSize
+	}
+	return make(chan Message, size)
+}
+
+// Sender sends event messages created by Emitter() or Scheduler() with config.SenderWorkers workers;
+// config.MaxInFlight limits the number of concurrent publishes on the client connection;
+// each worker waits for its publish, so more publishes than workers can not be in flight.
+// the workers stop after their current publish when ctx is done; wg (may be nil) waits for them.
+// emptied (may be nil) is signaled without blocking when a worker takes the last queued message
+func Sender(ctx context.Context, wg *sync.WaitGroup, config configuration.Config, messages chan Message, emptied chan struct{}, c client.Client, stat statistics.Interface, tracker *tracking.Tracker) {
+	workers := config.SenderWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	maxInFlight := config.MaxInFlight
+	if maxInFlight > workers {
+		log.Println("WARNING: max_in_flight", maxInFlight, "exceeds sender_workers; use", workers, "(increase sender_workers for more concurrent publishes)")
+	}
+	if maxInFlight <= 0 || maxInFlight > workers {
+		maxInFlight = workers
+	}
+	inFlight := make(chan struct{}, maxInFlight)
+	for i := int64(0); i < workers; i++ {
+		if wg != nil {
+			wg.Add(1)
+		}
+		go func() {
+			if wg != nil {
+				defer wg.Done()
+			}
+			for {
+				var m Message
+				select {
+				case <-ctx.Done():
+					return
+				case m = <-messages:
+				}
+				if emptied != nil && len(messages) == 0 {
+					select {
+					case emptied <- struct{}{}:
+					default:
+					}
+				}
+				event := map[platform_connector_lib.ProtocolSegmentName]string{}
+				err := json.Unmarshal([]byte(m.Message), &event)
+				if err != nil {
+					log.Println("ERROR: unable to unmarshal emitted event", m.Message, err)
+					stat.EventFailed(m.Info[DeviceUriKey], m.Info[ServiceUriKey])
+					stat.Error(statistics.Unmarshal, "event")
+					continue
+				}
+				inFlight <- struct{}{}
+				start := time.Now()
+				stat.EventDelay(start.Sub(m.Scheduled))
+				tracker.Published(m.Stream, m.Seq, start)
+				err = c.SendEventWithQos(m.Info[DeviceUriKey], m.Info[ServiceUriKey], event, m.Qos)
+				<-inFlight
+				if err != nil {
+					tracker.Unpublished(m.Stream, m.Seq)
+					log.Println("ERROR: unable to send emitted event", m.Message, err)
+					stat.EventFailed(m.Info[DeviceUriKey], m.Info[ServiceUriKey])
+					stat.Error(MqttErrorCategory(err), "mqtt/event")
+					continue
+				}
+				stat.EventProduce(m.Info[DeviceUriKey], m.Info[ServiceUriKey], time.Since(start))
+			}
+		}()
+	}
+	go func() {
+		t := time.NewTicker(QueueDepthSampleInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				stat.QueueDepth(len(messages), cap(messages))
+			}
+		}
+	}()
+}
+
+// MqttErrorCategory classifies errors of client.Client publishes and subscriptions
+func MqttErrorCategory(err error) statistics.ErrorCategory {
+	switch {
+	case errors.Is(err, client.ErrNotConnected):
+		return statistics.NotConnected
+	case errors.Is(err, client.ErrPublishTimeout):
+		return statistics.PublishTimeout
+	}
+	return statistics.BrokerRejected
+}
@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"context"
+	platform_connector_lib "github.com/SENERGY-Platform/platform-connector-lib"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/client"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/tracking"
+	"testing"
+	"time"
+)
+
+// sendClient publishes events after waiting for release
+type sendClient struct {
+	client.Client
+	release chan struct{}
+}
+
+func (this *sendClient) SendEventWithQos(deviceUri string, serviceUri string, event map[platform_connector_lib.ProtocolSegmentName]string, b byte) error {
+	<-this.release
+	return nil
+}
+
+func newTestSimulation(t *testing.T, c client.Client) *simulation {
+	t.Helper()
+	tracker, err := tracking.New("run", tracking.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := configuration.Config{SenderWorkers: 1, EmitterQueueSize: 10}
+	sim := newSimulation(context.Background(), config, nil, c, statistics.Void{}, tracker, nil)
+	err = sim.Start(tracking.NewCommands(time.Minute), func() float64 { return 1 }, func() bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		sim.messages <- Message{Message: "{}", Info: map[string]string{}}
+	}
+	return sim
+}
+
+func TestDrainQueue(t *testing.T) {
+	c := &sendClient{release: make(chan struct{})}
+	close(c.release)
+	sim := newTestSimulation(t, c)
+	if left := sim.DrainQueue(10 * time.Second); left != 0 {
+		t.Error("expect an empty queue, got", left)
+	}
+	if _, finished := sim.StopSenders(time.Second); !finished {
+		t.Error("expect finished senders")
+	}
+}
+
+func TestDrainQueueTimeout(t *testing.T) {
+	c := &sendClient{release: make(chan struct{})}
+	sim := newTestSimulation(t, c)
+	start := time.Now()
+	if left := sim.DrainQueue(50 * time.Millisecond); left == 0 {
+		t.Error("expect messages left in the queue of a blocked sender")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("expect the drain to end after the timeout")
+	}
+	close(c.release)
+	dropped, finished := sim.StopSenders(time.Second)
+	if !finished || dropped > 9 {
+		t.Errorf("unexpected stop of the senders: %v dropped, finished %v", dropped, finished)
+	}
+}
@@ -0,0 +1,363 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	platform_connector_lib "github.com/SENERGY-Platform/platform-connector-lib"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/client"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/control"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/distribution"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/payload"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/tracking"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type simService struct {
+	configuration.Service
+	qos      byte
+	interval time.Duration
+	arrival  distribution.Distribution
+	payload  *payload.Template
+}
+
+func getSimServices(config configuration.Config, services []configuration.Service) (result []simService, err error) {
+	for _, service := range services {
+		s := simService{Service: service, qos: byte(*service.Qos)}
+		s.payload, err = payload.New(service.Payload)
+		if err != nil {
+			log.Println("ERROR: unable to parse payload of", service.ServiceUri, err)
+			return result, err
+		}
+		switch service.Direction {
+		case configuration.CommandDirection:
+		case configuration.EventDirection:
+			if config.LatencyConsumer.Type != "" && !strings.Contains(service.Payload, ".Trace") {
+				log.Println("WARNING: payload of", service.ServiceUri, "contains no {{.Trace}}; events can not be tracked downstream")
+			}
+			s.arrival, err = distribution.New(*service.Distribution)
+			if err != nil {
+				log.Println("ERROR: invalid distribution of", service.ServiceUri, err)
+				return result, err
+			}
+			if config.TargetRate <= 0 {
+				s.interval, err = time.ParseDuration(service.Interval)
+				if err != nil {
+					log.Println("ERROR: unable to parse interval of", service.ServiceUri, service.Interval, err)
+					return result, err
+				}
+			}
+		default:
+			log.Println("ERROR: unknown direction of", service.ServiceUri, service.Direction)
+			return result, errors.New("unknown service direction: " + service.Direction)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// simulation runs the command listeners, emitters and senders of a fleet; devices can be added and removed while it runs.
+// the emitters stop with ctx, the senders and command handlers with StopSenders (see shutdown)
+type simulation struct {
+	ctx      context.Context
+	config   configuration.Config
+	c        client.Client
+	stat     statistics.Interface
+	tracker  *tracking.Tracker
+	events   *control.Budget
+	commands *tracking.Commands
+	rate     func() float64
+	answer   func() bool //false while commands are disabled
+	messages chan Message
+	emptied  chan struct{} //signaled by the senders when they took the last queued message (see DrainQueue)
+	sources  *Sources
+
+	emitters sync.WaitGroup
+	senders  sync.WaitGroup
+	sendCtx  context.Context
+	stopSend context.CancelFunc
+	handlers int64 //running command handlers
+
+	mux           sync.Mutex
+	groupServices map[string][]simService
+	fleet         []FleetDevice
+	devices       map[string]*simDevice //running devices by uri
+}
+
+type simDevice struct {
+	cancel   context.CancelFunc
+	commands []string //service uris of the command listeners
+}
+
+func newSimulation(ctx context.Context, config configuration.Config, fleet []FleetDevice, c client.Client, stat statistics.Interface, tracker *tracking.Tracker, events *control.Budget) *simulation {
+	sendCtx, stopSend := context.WithCancel(context.Background())
+	return &simulation{
+		sendCtx:       sendCtx,
+		stopSend:      stopSend,
+		ctx:           ctx,
+		config:        config,
+		c:             c,
+		stat:          stat,
+		tracker:       tracker,
+		events:        events,
+		messages:      NewMessageQueue(config),
+		emptied:       make(chan struct{}, 1),
+		sources:       &Sources{},
+		groupServices: map[string][]simService{},
+		fleet:         fleet,
+		devices:       map[string]*simDevice{},
+	}
+}
+
+// Start starts command listeners and emitters of the fleet and the senders
+func (this *simulation) Start(commands *tracking.Commands, rate func() float64, answer func() bool) (err error) {
+	this.commands = commands
+	this.rate = rate
+	this.answer = answer
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, d := range this.fleet {
+		err = this.startDevice(d)
+		if err != nil {
+			return err
+		}
+	}
+
+	if this.config.TargetRate > 0 {
+		//open loop: one global schedule over all devices and event services
+		arrival, err := distribution.New(this.config.EmitterDistribution)
+		if err != nil {
+			log.Println("ERROR: invalid emitter_distribution", err)
+			return err
+		}
+		r := rand.New(rand.NewSource(distribution.Seed(this.config.Seed, this.config.HubPrefix)))
+		Scheduler(this.ctx, &this.emitters, this.messages, this.sources, this.config.TargetRate, arrival, r, rate, this.stat)
+	}
+
+	//send event messages created by Emitter() and Scheduler()
+	Sender(this.sendCtx, &this.senders, this.config, this.messages, this.emptied, this.c, this.stat, this.tracker)
+	return nil
+}
+
+// Fleet returns the current devices
+func (this *simulation) Fleet() []FleetDevice {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return append([]FleetDevice{}, this.fleet...)
+}
+
+// Burst enqueues count events immediately (see Burst)
+func (this *simulation) Burst(count int) int {
+	return Burst(this.messages, this.sources.List(), count, this.stat)
+}
+
+// Add starts command listeners and emitters of devices which are already provisioned; on error the already started devices stay in the fleet
+// and the failed device is stopped again
+func (this *simulation) Add(devices []FleetDevice) (err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, d := range devices {
+		err = this.startDevice(d)
+		if err != nil {
+			return err
+		}
+		this.fleet = append(this.fleet, d)
+	}
+	return nil
+}
+
+// DrainCheckInterval is used to recheck the command handlers while the simulation is stopped
+const DrainCheckInterval = 10 * time.Millisecond
+
+// StopEmitters waits for the emitters and the scheduler, which stop when the run context is done
+func (this *simulation) StopEmitters() {
+	this.emitters.Wait()
+}
+
+// DrainQueue waits up to timeout until the senders took all queued messages and returns the number of messages left
+func (this *simulation) DrainQueue(timeout time.Duration) int {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for len(this.messages) > 0 {
+		select {
+		case <-this.emptied:
+		case <-deadline.C:
+			return len(this.messages)
+		}
+	}
+	return 0
+}
+
+// StopSenders stops the senders after their current publish, which waits for its QoS acknowledgement, and waits up to timeout for them and running command handlers;
+// messages left in the queue are counted as missed and returned as dropped
+func (this *simulation) StopSenders(timeout time.Duration) (dropped int, finished bool) {
+	this.stopSend()
+	done := make(chan struct{})
+	go func() {
+		this.senders.Wait()
+		for atomic.LoadInt64(&this.handlers) > 0 {
+			time.Sleep(DrainCheckInterval)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		finished = true
+	case <-time.After(timeout):
+	}
+	for {
+		select {
+		case <-this.messages:
+			this.stat.EventMissed()
+			dropped++
+		default:
+			return dropped, finished
+		}
+	}
+}
+
+// Remove stops the emitters and command listeners of devices (see ShrinkFleet), forgets their tracked streams and returns the removed devices
+func (this *simulation) Remove(devices []FleetDevice) (removed []FleetDevice) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	obsolete := map[string]bool{}
+	for _, d := range devices {
+		obsolete[d.Uri] = true
+	}
+	fleet := []FleetDevice{}
+	for _, d := range this.fleet {
+		if obsolete[d.Uri] {
+			removed = append(removed, d)
+		} else {
+			fleet = append(fleet, d)
+		}
+	}
+	this.fleet = fleet
+	for _, d := range removed {
+		this.stopDevice(d.Uri)
+	}
+	return removed
+}
+
+// stopDevice stops the emitters and command listeners of a started device and forgets its sources and tracked streams; the caller has to hold mux
+func (this *simulation) stopDevice(uri string) {
+	device, ok := this.devices[uri]
+	if !ok {
+		return
+	}
+	delete(this.devices, uri)
+	device.cancel()
+	this.sources.RemoveDevice(uri)
+	this.tracker.Forget(uri)
+	for _, service := range device.commands {
+		err := this.c.Unsubscribe(uri, service)
+		if err != nil {
+			log.Println("WARNING: unable to unsubscribe device command for", uri, service, err)
+			this.stat.Error(MqttErrorCategory(err), "mqtt/unsubscribe")
+		}
+	}
+}
+
+func (this *simulation) startDevice(d FleetDevice) (err error) {
+	services, ok := this.groupServices[d.Group.Name]
+	if !ok {
+		services, err = getSimServices(this.config, d.Group.Services)
+		if err != nil {
+			return err
+		}
+		this.groupServices[d.Group.Name] = services
+	}
+	ctx, cancel := context.WithCancel(this.ctx)
+	device := &simDevice{cancel: cancel}
+	this.devices[d.Uri] = device
+	defer func() {
+		if err != nil {
+			this.stopDevice(d.Uri)
+		}
+	}()
+	config, c, stat, tracker, commands, answer, handlers := this.config, this.c, this.stat, this.tracker, this.commands, this.answer, &this.handlers
+	for _, service := range services {
+		service := service
+		stream := tracker.Register(d.Uri, service.ServiceUri, stat)
+		generator := service.payload.ForDevice(payload.Device{Id: d.Uri, Name: d.Name, Index: d.FleetIndex, Group: d.Group.Name, GroupIndex: d.GroupIndex}, c.HubId(), rand.New(rand.NewSource(distribution.Seed(config.Seed, "payload/"+d.Uri+"/"+service.ServiceUri))), func(seq uint64) string {
+			return tracker.Trace(stream, seq)
+		})
+		switch service.Direction {
+		case configuration.CommandDirection:
+			err = c.ListenCommandWithQos(d.Uri, service.ServiceUri, service.qos, func(correlationId string, msg platform_connector_lib.CommandRequestMsg) (resp platform_connector_lib.CommandResponseMsg, err error) {
+				atomic.AddInt64(handlers, 1)
+				defer atomic.AddInt64(handlers, -1)
+				if ctx.Err() != nil {
+					//the client may subscribe removed devices again on reconnect
+					return resp, errors.New("device " + d.Uri + " removed")
+				}
+				if !answer() {
+					return resp, errors.New("commands disabled")
+				}
+				if config.Debug {
+					log.Println("DEBUG: receive command")
+				}
+				stat.CommandsHandled(d.Uri, service.ServiceUri)
+				if latency, ok := commands.Received(d.Uri, correlationId, time.Now()); ok {
+					stat.CommandLatency(latency)
+				}
+				message, _, err := createPayload(generator)
+				if err != nil {
+					stat.Error(statistics.Template, "command-response")
+					return resp, err
+				}
+				err = json.Unmarshal([]byte(message), &resp)
+				if err != nil {
+					stat.Error(statistics.Unmarshal, "command-response")
+				}
+				return
+			})
+			if err != nil {
+				log.Println("ERROR: unable to listen to device command for", d.Uri, service.ServiceUri, err)
+				stat.Error(MqttErrorCategory(err), "mqtt/subscribe")
+				return err
+			}
+			device.commands = append(device.commands, service.ServiceUri)
+		case configuration.EventDirection:
+			source := Source{
+				Info: map[string]string{
+					DeviceUriKey:  d.Uri,
+					ServiceUriKey: service.ServiceUri,
+				},
+				Qos:     service.qos,
+				Stream:  stream,
+				Budget:  this.events,
+				Arrival: service.arrival,
+				Message: func() (string, uint64, error) {
+					message, seq, err := createPayload(generator)
+					if err != nil {
+						stat.Error(statistics.Template, "event")
+					}
+					return message, seq, err
+				},
+			}
+			this.sources.Add(source)
+			if config.TargetRate <= 0 {
+				//create emitter of event messages
+				r := rand.New(rand.NewSource(distribution.Seed(config.Seed, d.Uri+"/"+service.ServiceUri)))
+				Emitter(ctx, &this.emitters, this.messages, source, service.interval, service.arrival, r, this.rate, stat)
+			}
+		}
+	}
+	return nil
+}
+
+func createPayload(generator *payload.Generator) (result string, seq uint64, err error) {
+	result, seq, err = generator.Next()
+	if err != nil {
+		log.Println("ERROR: unable to create payload", err)
+	}
+	return
+}
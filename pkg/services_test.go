@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	platform_connector_lib "github.com/SENERGY-Platform/platform-connector-lib"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/client"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/distribution"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/tracking"
+	"testing"
+	"time"
+)
+
+// listenClient fails to listen to the command service fail and records unsubscribed services
+type listenClient struct {
+	client.Client
+	fail         string
+	unsubscribed []string
+}
+
+func (this *listenClient) HubId() string {
+	return "hub"
+}
+
+func (this *listenClient) ListenCommandWithQos(deviceUri string, serviceUri string, qos byte, f func(correlationId string, msg platform_connector_lib.CommandRequestMsg) (resp platform_connector_lib.CommandResponseMsg, err error)) error {
+	if serviceUri == this.fail {
+		return errors.New("subscribe failed")
+	}
+	return nil
+}
+
+func (this *listenClient) Unsubscribe(deviceUri string, serviceUri string) error {
+	this.unsubscribed = append(this.unsubscribed, serviceUri)
+	return nil
+}
+
+func TestAddFailedDevice(t *testing.T) {
+	qos := int64(0)
+	services := []configuration.Service{
+		{ServiceUri: "command", Direction: configuration.CommandDirection, Payload: "{}", Qos: &qos},
+		{ServiceUri: "event", Direction: configuration.EventDirection, Payload: "{}", Qos: &qos, Interval: "1h", Distribution: &distribution.Config{}},
+		{ServiceUri: "failing", Direction: configuration.CommandDirection, Payload: "{}", Qos: &qos},
+	}
+	c := &listenClient{fail: "failing"}
+	tracker, err := tracking.New("run", tracking.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sim := newSimulation(ctx, configuration.Config{}, nil, c, statistics.Void{}, tracker, nil)
+	err = sim.Start(tracking.NewCommands(time.Minute), func() float64 { return 1 }, func() bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	device := FleetDevice{Group: configuration.DeviceGroup{Name: "group", Services: services}}
+	device.Uri = "device"
+	err = sim.Add([]FleetDevice{device})
+	if err == nil {
+		t.Fatal("expect error of the failed command subscription")
+	}
+	if len(sim.Fleet()) != 0 || len(sim.devices) != 0 || len(sim.sources.List()) != 0 {
+		t.Errorf("expect the failed device to be removed, got %v fleet devices, %v devices and %v sources", len(sim.Fleet()), len(sim.devices), len(sim.sources.List()))
+	}
+	if len(c.unsubscribed) != 1 || c.unsubscribed[0] != "command" {
+		t.Error("expect the already subscribed command to be unsubscribed, got", c.unsubscribed)
+	}
+}
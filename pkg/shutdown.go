@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/client"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/metrics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/tracking"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const DefaultDrainTimeout = 30 * time.Second
+
+// shutdown stops a started instance in order after its run context is done:
+// emitters, emitter queue, publishes and command responses, downstream receipts of the published events, final statistics, processes and analytics, devices and hub.
+// each step is timed, logged and recorded in the metrics instance (see report.Instance)
+type shutdown struct {
+	config         configuration.Config
+	timeout        time.Duration //limits draining the queue and waiting for the senders
+	c              client.Client
+	instance       *metrics.Instance
+	stat           *statistics.Implementation
+	tracker        *tracking.Tracker
+	sim            *simulation
+	scaling        *scaler
+	stopStatistics context.CancelFunc //ends the statistics with the final interval
+	stopResources  context.CancelFunc //starts the removal of processes and analytics
+	resources      *sync.WaitGroup    //waits for the removal of processes and analytics
+}
+
+func (this *shutdown) run() {
+	log.Println("INFO: shutdown", this.config.HubPrefix)
+	start := time.Now()
+	this.step("stop_emitters", func() string {
+		this.sim.StopEmitters()
+		return ""
+	})
+	this.step("drain_queue", func() string {
+		left := this.sim.DrainQueue(this.timeout)
+		if left > 0 {
+			return strconv.Itoa(left) + " messages left after " + this.timeout.String()
+		}
+		return ""
+	})
+	this.step("wait_in_flight", func() string {
+		dropped, finished := this.sim.StopSenders(this.timeout)
+		result := ""
+		if !finished {
+			result = "publishes or command responses not finished after " + this.timeout.String() + "; "
+		}
+		if dropped > 0 || !finished {
+			result = result + strconv.Itoa(dropped) + " queued messages dropped"
+		}
+		return result
+	})
+	this.step("wait_receipts", func() string {
+		pending := this.tracker.Wait()
+		if pending > 0 {
+			return strconv.Itoa(pending) + " published events neither received nor expired"
+		}
+		return ""
+	})
+	this.step("final_statistics", func() string {
+		this.stopStatistics()
+		<-this.stat.Done()
+		return ""
+	})
+	this.step("delete_processes_and_analytics", func() string {
+		this.stopResources()
+		this.resources.Wait()
+		return ""
+	})
+	this.step("delete_devices_and_hub", func() string {
+		if cleanup(this.config, this.scaling.Devices(), this.c, this.instance) {
+			this.scaling.forget()
+		}
+		this.c.Stop()
+		return ""
+	})
+	log.Println("INFO: shutdown", this.config.HubPrefix, "finished in", time.Since(start).String())
+}
+
+// step runs f and records its duration and result
+func (this *shutdown) step(name string, f func() string) {
+	start := time.Now()
+	result := f()
+	duration := time.Since(start)
+	if result == "" {
+		log.Println("INFO: shutdown", this.config.HubPrefix, name, "in", duration.String())
+	} else {
+		log.Println("WARNING: shutdown", this.config.HubPrefix, name, "in", duration.String()+":", result)
+	}
+	this.instance.AddShutdownStep(metrics.ShutdownStep{Name: name, Duration: duration, Result: result})
+}
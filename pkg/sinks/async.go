@@ -0,0 +1,67 @@
+package sinks
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"log"
+	"time"
+)
+
+// AsyncQueueSize is the number of intervals an async writer buffers; further intervals are dropped
+const AsyncQueueSize = 100
+
+// AsyncCloseTimeout limits the wait for the queued intervals on Close
+const AsyncCloseTimeout = 30 * time.Second
+
+type interval struct {
+	hub     string
+	summary statistics.IntervalSummary
+}
+
+// async passes the intervals to writer in the background; Write and Close are serialized by Sinks.mux
+type async struct {
+	writer writer
+	queue  chan interval
+	done   chan struct{}
+	closed bool
+}
+
+func newAsync(w writer) *async {
+	result := &async{writer: w, queue: make(chan interval, AsyncQueueSize), done: make(chan struct{})}
+	go func() {
+		defer close(result.done)
+		for next := range result.queue {
+			err := w.Write(next.hub, next.summary)
+			if err != nil {
+				log.Println("WARNING: unable to write statistics to sink", err)
+			}
+		}
+	}()
+	return result
+}
+
+func (this *async) Write(hub string, summary statistics.IntervalSummary) error {
+	if this.closed {
+		return errors.New("statistics sink is closed")
+	}
+	select {
+	case this.queue <- interval{hub: hub, summary: summary}:
+		return nil
+	default:
+		return errors.New("statistics sink queue is full; drop interval")
+	}
+}
+
+func (this *async) Close() error {
+	if this.closed {
+		return nil
+	}
+	this.closed = true
+	close(this.queue)
+	select {
+	case <-this.done:
+	case <-time.After(AsyncCloseTimeout):
+		return errors.New("timeout while writing the queued statistics")
+	}
+	return this.writer.Close()
+}
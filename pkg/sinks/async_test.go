@@ -0,0 +1,60 @@
+package sinks
+
+import (
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blocking records the written hubs after release is closed
+type blocking struct {
+	release chan struct{}
+	mux     sync.Mutex
+	hubs    []string
+	closed  bool
+}
+
+func (this *blocking) Write(hub string, summary statistics.IntervalSummary) error {
+	<-this.release
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.hubs = append(this.hubs, hub)
+	return nil
+}
+
+func (this *blocking) Close() error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.closed = true
+	return nil
+}
+
+func TestAsync(t *testing.T) {
+	w := &blocking{release: make(chan struct{})}
+	a := newAsync(w)
+	start := time.Now()
+	for i := 0; i < AsyncQueueSize; i++ {
+		err := a.Write("hub", statistics.IntervalSummary{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expect writes not to wait for the writer")
+	}
+	if a.Write("hub", statistics.IntervalSummary{}) == nil && a.Write("hub", statistics.IntervalSummary{}) == nil {
+		t.Error("expect an error if the queue is full")
+	}
+	close(w.release)
+	err := a.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.hubs) < AsyncQueueSize || !w.closed {
+		t.Errorf("expect the queued intervals to be written before close, got %v closed=%v", len(w.hubs), w.closed)
+	}
+	if a.Write("hub", statistics.IntervalSummary{}) == nil {
+		t.Error("expect an error after close")
+	}
+}
@@ -0,0 +1,82 @@
+package sinks
+
+import (
+	"encoding/csv"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"os"
+	"strconv"
+	"time"
+)
+
+// CsvHeader and CsvRow are the format of the csv_file sink and of the report time series
+var CsvHeader = []string{"hub", "start", "end", "emitted", "produced", "failed", "missed", "blocked", "commands_handled", "processes_triggered", "throughput", "max_queue_depth", "produce_p50_ms", "produce_p95_ms", "produce_p99_ms", "send_delay_p99_ms", "command_latency_p95_ms", "process_completion_p95_ms", "lost", "errors", "phase"}
+
+func CsvRow(hub string, interval statistics.IntervalSummary) []string {
+	return []string{
+		hub,
+		interval.Start.Format(time.RFC3339Nano),
+		interval.End.Format(time.RFC3339Nano),
+		strconv.FormatUint(interval.Emitted, 10),
+		strconv.FormatUint(interval.Produced, 10),
+		strconv.FormatUint(interval.Failed, 10),
+		strconv.FormatUint(interval.Missed, 10),
+		strconv.FormatUint(interval.Blocked, 10),
+		strconv.FormatUint(interval.CommandsHandled, 10),
+		strconv.FormatUint(interval.ProcessesTriggered, 10),
+		strconv.FormatFloat(interval.Throughput, 'f', 3, 64),
+		strconv.Itoa(interval.MaxQueueDepth),
+		ms(interval.ProduceTime.P50),
+		ms(interval.ProduceTime.P95),
+		ms(interval.ProduceTime.P99),
+		ms(interval.SendDelay.P99),
+		ms(interval.CommandLatency.P95),
+		ms(interval.ProcessCompletion.P95),
+		strconv.FormatUint(interval.Deliveries[statistics.Lost.String()], 10),
+		strconv.FormatUint(errorCount(interval), 10),
+		interval.Phase,
+	}
+}
+
+func errorCount(interval statistics.IntervalSummary) (result uint64) {
+	for _, count := range interval.Errors {
+		result = result + count
+	}
+	return result
+}
+
+type csvFile struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+// newCsvFile returns an async writer, so that a slow disk does not block the statistics of all instances
+func newCsvFile(location string) (writer, error) {
+	file, err := os.OpenFile(location, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+	result := &csvFile{file: file, writer: csv.NewWriter(file)}
+	err = result.writer.Write(CsvHeader)
+	if err == nil {
+		result.writer.Flush()
+		err = result.writer.Error()
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return newAsync(result), nil
+}
+
+func (this *csvFile) Write(hub string, summary statistics.IntervalSummary) error {
+	err := this.writer.Write(CsvRow(hub, summary))
+	if err != nil {
+		return err
+	}
+	this.writer.Flush()
+	return this.writer.Error()
+}
+
+func (this *csvFile) Close() error {
+	return this.file.Close()
+}
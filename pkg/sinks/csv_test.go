@@ -0,0 +1,71 @@
+package sinks
+
+import (
+	"encoding/csv"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCsvRow(t *testing.T) {
+	start := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	row := CsvRow("hub", statistics.IntervalSummary{
+		Start:              start,
+		End:                start.Add(10 * time.Second),
+		Emitted:            10,
+		Produced:           9,
+		Failed:             1,
+		Missed:             2,
+		Blocked:            3,
+		CommandsHandled:    4,
+		ProcessesTriggered: 5,
+		Throughput:         0.9,
+		MaxQueueDepth:      6,
+		ProduceTime:        statistics.LatencySummary{P50: time.Millisecond, P95: 1500 * time.Microsecond, P99: 2 * time.Millisecond},
+		SendDelay:          statistics.LatencySummary{P99: 250 * time.Microsecond},
+		CommandLatency:     statistics.LatencySummary{P95: time.Second},
+		ProcessCompletion:  statistics.LatencySummary{P95: 2 * time.Second},
+		Deliveries:         map[string]uint64{statistics.Lost.String(): 7},
+		Errors:             map[string]uint64{"http_5xx": 1, "template:event": 2},
+		Phase:              "steady",
+	})
+	expect := []string{"hub", "2021-10-01T12:00:00Z", "2021-10-01T12:00:10Z", "10", "9", "1", "2", "3", "4", "5", "0.900", "6", "1.000", "1.500", "2.000", "0.250", "1000.000", "2000.000", "7", "3", "steady"}
+	if len(row) != len(CsvHeader) || len(row) != len(expect) {
+		t.Fatalf("expect %v columns like the header, got %v", len(CsvHeader), len(row))
+	}
+	for i := range expect {
+		if row[i] != expect[i] {
+			t.Errorf("%v: expect %v, got %v", CsvHeader[i], expect[i], row[i])
+		}
+	}
+}
+
+func TestCsvFile(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "statistics.csv")
+	sinks, err := Open([]Config{{Type: CsvFileType, Location: location}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	for _, hub := range []string{"a", "b"} {
+		err = sinks.For(hub)[0].Write(statistics.IntervalSummary{Start: start, End: start.Add(time.Second), Emitted: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	sinks.Close()
+	file, err := os.Open(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0][0] != "hub" || rows[1][0] != "a" || rows[2][0] != "b" || rows[2][3] != "1" {
+		t.Errorf("expect header and one row per interval, got %v", rows)
+	}
+}
@@ -0,0 +1,57 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const HttpTimeout = 10 * time.Second
+
+// httpSink posts each interval as json {hub, ...interval} (e.g. to a coordinator, see pkg/distributed)
+type httpSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// newHttp returns an async writer, because a slow receiver would block the statistics of all instances
+func newHttp(url string, token string) (writer, error) {
+	if url == "" {
+		return nil, errors.New("missing location of http statistics sink")
+	}
+	return newAsync(&httpSink{url: url, token: token, client: &http.Client{Timeout: HttpTimeout}}), nil
+}
+
+func (this *httpSink) Write(hub string, summary statistics.IntervalSummary) error {
+	body, err := json.Marshal(Line{Hub: hub, IntervalSummary: summary})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, this.url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if this.token != "" {
+		req.Header.Set("Authorization", "Bearer "+this.token)
+	}
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		temp, _ := ioutil.ReadAll(resp.Body)
+		return errors.New("unexpected statistics sink response " + resp.Status + ": " + string(temp))
+	}
+	return nil
+}
+
+func (this *httpSink) Close() error {
+	return nil
+}
@@ -0,0 +1,153 @@
+package sinks
+
+import (
+	"bytes"
+	"errors"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const Measurement = "senergy_load_test"
+
+const InfluxHttpTimeout = 10 * time.Second
+
+// Lines formats interval in the influxdb line protocol: one line with the counters and latencies (in ms) and one line per error key
+func Lines(hub string, interval statistics.IntervalSummary) string {
+	tags := ",hub=" + escapeTag(hub)
+	if interval.Phase != "" {
+		tags = tags + ",phase=" + escapeTag(interval.Phase)
+	}
+	timestamp := " " + strconv.FormatInt(interval.End.UnixNano(), 10) + "\n"
+	fields := []string{
+		"emitted=" + strconv.FormatUint(interval.Emitted, 10) + "i",
+		"produced=" + strconv.FormatUint(interval.Produced, 10) + "i",
+		"failed=" + strconv.FormatUint(interval.Failed, 10) + "i",
+		"missed=" + strconv.FormatUint(interval.Missed, 10) + "i",
+		"blocked=" + strconv.FormatUint(interval.Blocked, 10) + "i",
+		"commands_handled=" + strconv.FormatUint(interval.CommandsHandled, 10) + "i",
+		"processes_triggered=" + strconv.FormatUint(interval.ProcessesTriggered, 10) + "i",
+		"max_queue_depth=" + strconv.Itoa(interval.MaxQueueDepth) + "i",
+		"throughput=" + strconv.FormatFloat(interval.Throughput, 'f', -1, 64),
+		"lost=" + strconv.FormatUint(interval.Deliveries[statistics.Lost.String()], 10) + "i",
+	}
+	fields = append(fields, latencyFields("produce", interval.ProduceTime)...)
+	fields = append(fields, latencyFields("send_delay", interval.SendDelay)...)
+	fields = append(fields, latencyFields("command_latency", interval.CommandLatency)...)
+	fields = append(fields, latencyFields("process_completion", interval.ProcessCompletion)...)
+	result := Measurement + tags + " " + strings.Join(fields, ",") + timestamp
+
+	services := []string{}
+	for service := range interval.EventLatency {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		result = result + Measurement + "_event_latency" + tags + ",service=" + escapeTag(service) + " " + strings.Join(latencyFields("event_latency", interval.EventLatency[service]), ",") + timestamp
+	}
+
+	keys := []string{}
+	for key := range interval.Errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts := strings.SplitN(key, ":", 2)
+		errorTags := tags + ",category=" + escapeTag(parts[0])
+		if len(parts) == 2 {
+			errorTags = errorTags + ",endpoint=" + escapeTag(parts[1])
+		}
+		result = result + Measurement + "_errors" + errorTags + " count=" + strconv.FormatUint(interval.Errors[key], 10) + "i" + timestamp
+	}
+	return result
+}
+
+func latencyFields(prefix string, summary statistics.LatencySummary) []string {
+	return []string{
+		prefix + "_count=" + strconv.FormatUint(summary.Count, 10) + "i",
+		prefix + "_avg_ms=" + ms(summary.Avg),
+		prefix + "_p50_ms=" + ms(summary.P50),
+		prefix + "_p95_ms=" + ms(summary.P95),
+		prefix + "_p99_ms=" + ms(summary.P99),
+		prefix + "_max_ms=" + ms(summary.Max),
+	}
+}
+
+var tagEscaper = strings.NewReplacer(",", "\\,", "=", "\\=", " ", "\\ ")
+
+func escapeTag(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return tagEscaper.Replace(value)
+}
+
+type influxFile struct {
+	file *os.File
+}
+
+func newInfluxFile(location string) (*influxFile, error) {
+	file, err := os.OpenFile(location, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &influxFile{file: file}, nil
+}
+
+func (this *influxFile) Write(hub string, summary statistics.IntervalSummary) error {
+	_, err := io.WriteString(this.file, Lines(hub, summary))
+	return err
+}
+
+func (this *influxFile) Close() error {
+	return this.file.Close()
+}
+
+type influxHttp struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// newInfluxHttp returns an async writer, because a slow influxdb would block the statistics of all instances
+func newInfluxHttp(url string, token string) (writer, error) {
+	if url == "" {
+		return nil, errors.New("missing location of influx_http statistics sink")
+	}
+	return newAsync(&influxHttp{url: url, token: token, client: &http.Client{Timeout: InfluxHttpTimeout}}), nil
+}
+
+func (this *influxHttp) Write(hub string, summary statistics.IntervalSummary) error {
+	req, err := http.NewRequest(http.MethodPost, this.url, bytes.NewBufferString(Lines(hub, summary)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if this.token != "" {
+		req.Header.Set("Authorization", "Token "+this.token)
+	}
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return errors.New("unexpected influx response " + resp.Status + ": " + string(body))
+	}
+	return nil
+}
+
+func (this *influxHttp) Close() error {
+	return nil
+}
+
+func ms(duration time.Duration) string {
+	return strconv.FormatFloat(float64(duration)/float64(time.Millisecond), 'f', 3, 64)
+}
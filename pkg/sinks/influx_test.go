@@ -0,0 +1,60 @@
+package sinks
+
+import (
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLines(t *testing.T) {
+	end := time.Unix(1600000000, 5)
+	interval := statistics.IntervalSummary{
+		End:           end,
+		Emitted:       10,
+		Produced:      9,
+		Failed:        1,
+		MaxQueueDepth: 3,
+		Throughput:    4.5,
+		ProduceTime:   statistics.LatencySummary{Count: 9, Avg: 2 * time.Millisecond, P50: time.Millisecond, P95: 1500 * time.Microsecond, P99: 3 * time.Millisecond, Max: 4 * time.Millisecond},
+		EventLatency:  map[string]statistics.LatencySummary{"b": {Count: 1}, "a": {Count: 2}},
+		Deliveries:    map[string]uint64{statistics.Lost.String(): 2},
+		Errors:        map[string]uint64{"http_5xx:process-engine/start": 3, "template": 1},
+		Phase:         "ramp up",
+	}
+	lines := strings.Split(strings.TrimSuffix(Lines("hub,1", interval), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expect 5 lines, got %v", lines)
+	}
+	tests := []struct {
+		line     string
+		prefix   string
+		contains []string
+	}{
+		{line: lines[0], prefix: "senergy_load_test,hub=hub\\,1,phase=ramp\\ up ", contains: []string{"emitted=10i", "produced=9i", "failed=1i", "max_queue_depth=3i", "throughput=4.5", "lost=2i", "produce_count=9i", "produce_avg_ms=2.000", "produce_p95_ms=1.500", "produce_max_ms=4.000", "send_delay_count=0i"}},
+		{line: lines[1], prefix: "senergy_load_test_event_latency,hub=hub\\,1,phase=ramp\\ up,service=a ", contains: []string{"event_latency_count=2i"}},
+		{line: lines[2], prefix: "senergy_load_test_event_latency,hub=hub\\,1,phase=ramp\\ up,service=b ", contains: []string{"event_latency_count=1i"}},
+		{line: lines[3], prefix: "senergy_load_test_errors,hub=hub\\,1,phase=ramp\\ up,category=http_5xx,endpoint=process-engine/start ", contains: []string{"count=3i"}},
+		{line: lines[4], prefix: "senergy_load_test_errors,hub=hub\\,1,phase=ramp\\ up,category=template ", contains: []string{"count=1i"}},
+	}
+	for _, test := range tests {
+		if !strings.HasPrefix(test.line, test.prefix) {
+			t.Errorf("expect prefix %v in %v", test.prefix, test.line)
+		}
+		if !strings.HasSuffix(test.line, " 1600000000000000005") {
+			t.Errorf("expect nanosecond timestamp in %v", test.line)
+		}
+		for _, field := range test.contains {
+			if !strings.Contains(test.line, " "+field+",") && !strings.Contains(test.line, ","+field+",") && !strings.Contains(test.line, ","+field+" ") && !strings.Contains(test.line, " "+field+" ") {
+				t.Errorf("expect field %v in %v", field, test.line)
+			}
+		}
+	}
+}
+
+func TestLinesWithoutPhase(t *testing.T) {
+	line := Lines("", statistics.IntervalSummary{End: time.Unix(1, 0)})
+	if !strings.HasPrefix(line, "senergy_load_test,hub=- ") || strings.Count(line, "\n") != 1 {
+		t.Error("unexpected line", line)
+	}
+}
@@ -0,0 +1,99 @@
+package sinks
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"log"
+	"sync"
+)
+
+const (
+	CsvFileType    = "csv_file"    //Location is a file; one row per interval, see CsvHeader
+	InfluxFileType = "influx_file" //Location is a file, written in the influxdb line protocol
+	InfluxHttpType = "influx_http" //Location is the write url, e.g. http://influxdb:8086/api/v2/write?org=o&bucket=b&precision=ns
+	StdoutType     = "stdout"      //json lines
+	HttpType       = "http"        //Location is an url; each interval is posted as json line
+)
+
+type Config struct {
+	Type     string `json:"type"`
+	Location string `json:"location"`
+	Token    string `json:"token"` //influx_http: sent as "Authorization: Token <token>"; http: sent as "Authorization: Bearer <token>"
+}
+
+// writer receives the intervals of all instances
+type writer interface {
+	Write(hub string, summary statistics.IntervalSummary) error
+	Close() error
+}
+
+// Sinks are shared by all instances of a run; each instance writes through the statistics.Sink list returned by For
+type Sinks struct {
+	mux     sync.Mutex
+	writers []writer
+}
+
+func Open(configs []Config) (result *Sinks, err error) {
+	result = &Sinks{}
+	for _, config := range configs {
+		var w writer
+		switch config.Type {
+		case CsvFileType:
+			w, err = newCsvFile(config.Location)
+		case InfluxFileType:
+			w, err = newInfluxFile(config.Location)
+		case InfluxHttpType:
+			w, err = newInfluxHttp(config.Location, config.Token)
+		case StdoutType:
+			w = newStdout()
+		case HttpType:
+			w, err = newHttp(config.Location, config.Token)
+		default:
+			err = errors.New("unknown statistics sink type " + config.Type)
+		}
+		if err != nil {
+			result.Close()
+			return nil, err
+		}
+		result.writers = append(result.writers, w)
+	}
+	return result, nil
+}
+
+// For returns the sinks of the instance hub
+func (this *Sinks) For(hub string) (result []statistics.Sink) {
+	if this == nil {
+		return nil
+	}
+	for _, w := range this.writers {
+		result = append(result, &tagged{sinks: this, writer: w, hub: hub})
+	}
+	return result
+}
+
+func (this *Sinks) Close() {
+	if this == nil {
+		return
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, w := range this.writers {
+		err := w.Close()
+		if err != nil {
+			log.Println("WARNING: unable to close statistics sink", err)
+		}
+	}
+	this.writers = nil
+}
+
+type tagged struct {
+	sinks  *Sinks
+	writer writer
+	hub    string
+}
+
+func (this *tagged) Write(summary statistics.IntervalSummary) error {
+	this.sinks.mux.Lock()
+	defer this.sinks.mux.Unlock()
+	return this.writer.Write(this.hub, summary)
+}
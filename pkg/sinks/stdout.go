@@ -0,0 +1,29 @@
+package sinks
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"os"
+)
+
+type stdout struct {
+	encoder *json.Encoder
+}
+
+func newStdout() *stdout {
+	return &stdout{encoder: json.NewEncoder(os.Stdout)}
+}
+
+// Line is the json representation of an interval of the stdout and http sinks
+type Line struct {
+	Hub string `json:"hub"`
+	statistics.IntervalSummary
+}
+
+func (this *stdout) Write(hub string, summary statistics.IntervalSummary) error {
+	return this.encoder.Encode(Line{Hub: hub, IntervalSummary: summary})
+}
+
+func (this *stdout) Close() error {
+	return nil
+}
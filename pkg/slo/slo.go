@@ -0,0 +1,292 @@
+package slo
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Threshold asserts a condition on a metric of the run summary, e.g. {"metric": "publish_latency.p99", "condition": "< 200ms"}.
+// latency metrics are publish_latency, send_delay, command_latency, process_completion and event_latency,
+// each followed by .min, .avg, .p50, .p90, .p95, .p99, .p999 or .max;
+// other metrics are error_rate, errors, failed, missed, loss_rate, lost, duplicate and throughput.
+// conditions compare with <, <=, >, >=, == or != to a number, a percentage (0.1%) or a duration (2s)
+type Threshold struct {
+	Metric    string `json:"metric"`
+	Condition string `json:"condition"`
+}
+
+const (
+	EvaluateAtEnd        = "end"
+	EvaluateContinuously = "continuous" //aborts the run on the first breach
+)
+
+type Category string
+
+const (
+	LatencyCategory    Category = "latency"
+	ErrorCategory      Category = "errors"
+	LossCategory       Category = "loss"
+	ThroughputCategory Category = "throughput"
+)
+
+// exit codes of main
+const (
+	ExitOk         = 0
+	ExitStartup    = 2
+	ExitScenario   = 3 //a scenario phase could not be set up
+	ExitLatency    = 10
+	ExitErrors     = 11
+	ExitLoss       = 12
+	ExitThroughput = 13
+)
+
+var exitCodes = map[Category]int{
+	LatencyCategory:    ExitLatency,
+	ErrorCategory:      ExitErrors,
+	LossCategory:       ExitLoss,
+	ThroughputCategory: ExitThroughput,
+}
+
+type Result struct {
+	Threshold
+	Category Category `json:"category"`
+	Value    float64  `json:"value"` //durations in seconds, rates as fraction
+	Passed   bool     `json:"passed"`
+}
+
+func (this Result) String() string {
+	state := "passed"
+	if !this.Passed {
+		state = "FAILED"
+	}
+	return state + ": " + this.Metric + " " + this.Condition + " (value=" + strconv.FormatFloat(this.Value, 'g', 6, 64) + ")"
+}
+
+// Validate checks metrics and conditions of thresholds
+func Validate(thresholds []Threshold) error {
+	for _, threshold := range thresholds {
+		if _, _, err := Metric(threshold.Metric, statistics.RunSummary{}); err != nil {
+			return err
+		}
+		if _, _, err := parseCondition(threshold.Condition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateConsumer rejects thresholds of metrics which are only measured downstream by the latency_consumer if consumer is false,
+// because without samples they would always pass
+func ValidateConsumer(thresholds []Threshold, consumer bool) error {
+	if consumer {
+		return nil
+	}
+	for _, threshold := range thresholds {
+		if downstream(threshold.Metric) {
+			return errors.New("slo metric " + threshold.Metric + " requires a latency_consumer")
+		}
+	}
+	return nil
+}
+
+// downstream reports whether metric is measured from the events consumed downstream (see tracking.Tracker)
+func downstream(metric string) bool {
+	switch metric {
+	case "loss_rate", "lost", "duplicate":
+		return true
+	}
+	return strings.HasPrefix(metric, "event_latency.")
+}
+
+func Evaluate(thresholds []Threshold, run statistics.RunSummary) (results []Result, err error) {
+	for _, threshold := range thresholds {
+		value, category, err := Metric(threshold.Metric, run)
+		if err != nil {
+			return results, err
+		}
+		operator, limit, err := parseCondition(threshold.Condition)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, Result{
+			Threshold: threshold,
+			Category:  category,
+			Value:     value,
+			Passed:    compare(value, operator, limit),
+		})
+	}
+	return results, nil
+}
+
+// ExitCode returns the exit code of the category of the first failed result
+func ExitCode(results []Result) int {
+	for _, result := range results {
+		if !result.Passed {
+			return exitCodes[result.Category]
+		}
+	}
+	return ExitOk
+}
+
+// Watch evaluates thresholds every interval after warmup against the summary returned by current and calls breach with the failed results
+func Watch(ctx context.Context, thresholds []Threshold, interval time.Duration, warmup time.Duration, current func() statistics.RunSummary, breach func(results []Result)) {
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(warmup):
+		}
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				results, err := Evaluate(thresholds, current())
+				if err != nil {
+					log.Println("ERROR: unable to evaluate thresholds", err)
+					return
+				}
+				if ExitCode(results) != ExitOk {
+					for _, result := range results {
+						if !result.Passed {
+							log.Println("SLO:", result.String())
+						}
+					}
+					breach(results)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Metric returns the value of the named metric (see Threshold) in run; durations in seconds, rates as fraction
+func Metric(name string, run statistics.RunSummary) (value float64, category Category, err error) {
+	switch name {
+	case "error_rate":
+		return rate(run.Failed, run.Produced+run.Failed), ErrorCategory, nil
+	case "errors":
+		total := uint64(0)
+		for _, count := range run.Errors {
+			total = total + count
+		}
+		return float64(total), ErrorCategory, nil
+	case "failed":
+		return float64(run.Failed), ErrorCategory, nil
+	case "missed":
+		return float64(run.Missed), ErrorCategory, nil
+	case "loss_rate":
+		return rate(run.Deliveries[statistics.Lost.String()], run.Produced), LossCategory, nil
+	case "lost":
+		return float64(run.Deliveries[statistics.Lost.String()]), LossCategory, nil
+	case "duplicate":
+		return float64(run.Deliveries[statistics.Duplicate.String()]), LossCategory, nil
+	case "throughput":
+		return run.Throughput, ThroughputCategory, nil
+	}
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) != 2 {
+		return 0, category, errors.New("unknown slo metric " + name)
+	}
+	var summary statistics.LatencySummary
+	switch parts[0] {
+	case "publish_latency":
+		summary = run.ProduceTime
+	case "send_delay":
+		summary = run.SendDelay
+	case "command_latency":
+		summary = run.CommandLatency
+	case "process_completion":
+		summary = run.ProcessCompletion
+	case "event_latency":
+		summary = run.EndToEndLatency
+	default:
+		return 0, category, errors.New("unknown slo metric " + name)
+	}
+	var d time.Duration
+	switch parts[1] {
+	case "min":
+		d = summary.Min
+	case "avg":
+		d = summary.Avg
+	case "p50":
+		d = summary.P50
+	case "p90":
+		d = summary.P90
+	case "p95":
+		d = summary.P95
+	case "p99":
+		d = summary.P99
+	case "p999":
+		d = summary.P999
+	case "max":
+		d = summary.Max
+	default:
+		return 0, category, errors.New("unknown slo metric " + name)
+	}
+	return d.Seconds(), LatencyCategory, nil
+}
+
+func rate(count uint64, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
+
+var operators = []string{"<=", ">=", "==", "!=", "<", ">", "="}
+
+func parseCondition(condition string) (operator string, limit float64, err error) {
+	condition = strings.TrimSpace(condition)
+	for _, op := range operators {
+		if strings.HasPrefix(condition, op) {
+			operator = op
+			break
+		}
+	}
+	if operator == "" {
+		return operator, limit, errors.New("missing operator in slo condition " + condition)
+	}
+	value := strings.TrimSpace(strings.TrimPrefix(condition, operator))
+	if operator == "=" {
+		operator = "=="
+	}
+	if strings.HasSuffix(value, "%") {
+		limit, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		return operator, limit / 100, err
+	}
+	limit, err = strconv.ParseFloat(value, 64)
+	if err == nil {
+		return operator, limit, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return operator, limit, errors.New("invalid value in slo condition " + condition)
+	}
+	return operator, d.Seconds(), nil
+}
+
+func compare(value float64, operator string, limit float64) bool {
+	switch operator {
+	case "<":
+		return value < limit
+	case "<=":
+		return value <= limit
+	case ">":
+		return value > limit
+	case ">=":
+		return value >= limit
+	case "==":
+		return value == limit
+	case "!=":
+		return value != limit
+	}
+	return false
+}
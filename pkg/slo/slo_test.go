@@ -0,0 +1,155 @@
+package slo
+
+import (
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"testing"
+	"time"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		condition string
+		operator  string
+		limit     float64
+		invalid   bool
+	}{
+		{condition: "< 200ms", operator: "<", limit: 0.2},
+		{condition: "<=2s", operator: "<=", limit: 2},
+		{condition: " > 100 ", operator: ">", limit: 100},
+		{condition: ">= 1.5", operator: ">=", limit: 1.5},
+		{condition: "= 0", operator: "==", limit: 0},
+		{condition: "== 0", operator: "==", limit: 0},
+		{condition: "!= 3", operator: "!=", limit: 3},
+		{condition: "< 0.1%", operator: "<", limit: 0.001},
+		{condition: "200ms", invalid: true},
+		{condition: "< soon", invalid: true},
+		{condition: "< 1x%", invalid: true},
+		{condition: "", invalid: true},
+	}
+	for _, test := range tests {
+		t.Run(test.condition, func(t *testing.T) {
+			operator, limit, err := parseCondition(test.condition)
+			if test.invalid {
+				if err == nil {
+					t.Error("expect error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if operator != test.operator || limit != test.limit {
+				t.Errorf("expect %v %v, got %v %v", test.operator, test.limit, operator, limit)
+			}
+		})
+	}
+}
+
+var run = statistics.RunSummary{
+	Produced:        990,
+	Failed:          10,
+	Missed:          3,
+	Throughput:      99.5,
+	ProduceTime:     statistics.LatencySummary{Min: time.Millisecond, Avg: 5 * time.Millisecond, P50: 4 * time.Millisecond, P99: 150 * time.Millisecond, Max: time.Second},
+	EndToEndLatency: statistics.LatencySummary{P95: 300 * time.Millisecond},
+	Deliveries:      map[string]uint64{statistics.Lost.String(): 99, statistics.Duplicate.String(): 2},
+	Errors:          map[string]uint64{"http": 4, "mqtt:publish": 1},
+}
+
+func TestMetric(t *testing.T) {
+	tests := []struct {
+		metric   string
+		value    float64
+		category Category
+	}{
+		{metric: "error_rate", value: 0.01, category: ErrorCategory},
+		{metric: "errors", value: 5, category: ErrorCategory},
+		{metric: "failed", value: 10, category: ErrorCategory},
+		{metric: "missed", value: 3, category: ErrorCategory},
+		{metric: "loss_rate", value: 0.1, category: LossCategory},
+		{metric: "lost", value: 99, category: LossCategory},
+		{metric: "duplicate", value: 2, category: LossCategory},
+		{metric: "throughput", value: 99.5, category: ThroughputCategory},
+		{metric: "publish_latency.min", value: 0.001, category: LatencyCategory},
+		{metric: "publish_latency.avg", value: 0.005, category: LatencyCategory},
+		{metric: "publish_latency.p50", value: 0.004, category: LatencyCategory},
+		{metric: "publish_latency.p99", value: 0.15, category: LatencyCategory},
+		{metric: "publish_latency.max", value: 1, category: LatencyCategory},
+		{metric: "event_latency.p95", value: 0.3, category: LatencyCategory},
+		{metric: "command_latency.p999", value: 0, category: LatencyCategory},
+	}
+	for _, test := range tests {
+		t.Run(test.metric, func(t *testing.T) {
+			value, category, err := Metric(test.metric, run)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != test.value || category != test.category {
+				t.Errorf("expect %v %v, got %v %v", test.value, test.category, value, category)
+			}
+		})
+	}
+	for _, metric := range []string{"latency", "publish_latency", "publish_latency.p42", "queue.p99", ""} {
+		_, _, err := Metric(metric, run)
+		if err == nil {
+			t.Error("expect error for metric", metric)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds []Threshold
+		exitCode   int
+	}{
+		{name: "no thresholds", exitCode: ExitOk},
+		{name: "passed", thresholds: []Threshold{{Metric: "publish_latency.p99", Condition: "< 200ms"}, {Metric: "error_rate", Condition: "<= 1%"}}, exitCode: ExitOk},
+		{name: "latency", thresholds: []Threshold{{Metric: "publish_latency.p99", Condition: "< 100ms"}}, exitCode: ExitLatency},
+		{name: "errors", thresholds: []Threshold{{Metric: "error_rate", Condition: "< 1%"}}, exitCode: ExitErrors},
+		{name: "loss", thresholds: []Threshold{{Metric: "lost", Condition: "== 0"}}, exitCode: ExitLoss},
+		{name: "throughput", thresholds: []Threshold{{Metric: "throughput", Condition: ">= 100"}}, exitCode: ExitThroughput},
+		{name: "first failed", thresholds: []Threshold{{Metric: "throughput", Condition: "> 0"}, {Metric: "lost", Condition: "< 10"}, {Metric: "failed", Condition: "== 0"}}, exitCode: ExitLoss},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results, err := Evaluate(test.thresholds, run)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(test.thresholds) {
+				t.Fatalf("expect %v results, got %v", len(test.thresholds), len(results))
+			}
+			if code := ExitCode(results); code != test.exitCode {
+				t.Errorf("expect exit code %v, got %v (%v)", test.exitCode, code, results)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []Threshold{{Metric: "publish_latency.p99", Condition: "< 200ms"}, {Metric: "loss_rate", Condition: "< 0.1%"}}
+	if err := Validate(valid); err != nil {
+		t.Error(err)
+	}
+	for _, threshold := range []Threshold{{Metric: "unknown", Condition: "< 1"}, {Metric: "lost", Condition: "1"}} {
+		if err := Validate([]Threshold{threshold}); err == nil {
+			t.Error("expect error for", threshold)
+		}
+	}
+}
+
+func TestValidateConsumer(t *testing.T) {
+	for _, metric := range []string{"loss_rate", "lost", "duplicate", "event_latency.p99"} {
+		thresholds := []Threshold{{Metric: "throughput", Condition: "> 1"}, {Metric: metric, Condition: "< 1"}}
+		if ValidateConsumer(thresholds, false) == nil {
+			t.Error("expect error for", metric, "without latency_consumer")
+		}
+		if err := ValidateConsumer(thresholds, true); err != nil {
+			t.Error("unexpected error for", metric, "with latency_consumer", err)
+		}
+	}
+	if err := ValidateConsumer([]Threshold{{Metric: "publish_latency.p99", Condition: "< 1s"}, {Metric: "error_rate", Condition: "< 0.01"}}, false); err != nil {
+		t.Error("unexpected error for upstream metrics", err)
+	}
+}
@@ -0,0 +1,107 @@
+package pkg
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/sinks"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/tracking"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// startInstances starts config.Instances instances with up to config.StartupParallelism at the same time;
+// after the first instance which could not be started no further instances are started and its error is returned once the running starts are finished
+func startInstances(ctx context.Context, wg *sync.WaitGroup, config configuration.Config, tracker *tracking.Tracker, statisticsSinks *sinks.Sinks, limits budgets) (err error) {
+	parallelism := config.StartupParallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	log.Println("INFO: start", config.Instances, "instances with startup_parallelism", parallelism, "and connect_rate", config.ConnectRate)
+	connects := newConnectLimiter(config.ConnectRate)
+	slots := make(chan struct{}, parallelism)
+	failed := make(chan struct{})
+	mux := sync.Mutex{}
+	starts := sync.WaitGroup{}
+	begin := time.Now()
+loop:
+	for i := int64(1); i <= config.Instances; i++ {
+		select {
+		case slots <- struct{}{}:
+		case <-failed:
+			break loop
+		case <-ctx.Done():
+			break loop
+		}
+		c := config
+		c.HubPrefix = config.HubPrefix + "_" + strconv.FormatInt(i, 10)
+		c.ClientInfoLocation = iterateFileLocation(config.ClientInfoLocation, i)
+		c.ProcessInfoLocation = iterateFileLocation(config.ProcessInfoLocation, i)
+		c.AnalyticInfoLocation = iterateFileLocation(config.AnalyticInfoLocation, i)
+		starts.Add(1)
+		go func() {
+			defer starts.Done()
+			defer func() { <-slots }()
+			startErr := startRetry(ctx, wg, c, tracker, statisticsSinks, limits, connects, 5)
+			if startErr != nil {
+				mux.Lock()
+				defer mux.Unlock()
+				if err == nil {
+					err = startErr
+					close(failed)
+				}
+			}
+		}()
+	}
+	starts.Wait()
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		log.Println("INFO: started", config.Instances, "instances in", time.Since(begin).String())
+	}
+	return err
+}
+
+// connectLimiter spaces the mqtt connects of all instances by 1/connect_rate; provisioning is not limited.
+// a nil limiter is unlimited
+type connectLimiter struct {
+	mux      sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newConnectLimiter(rate float64) *connectLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &connectLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// Wait blocks until the next mqtt connect is allowed and returns the waited time
+func (this *connectLimiter) Wait(ctx context.Context) (waited time.Duration, err error) {
+	if this == nil {
+		return 0, nil
+	}
+	this.mux.Lock()
+	now := time.Now()
+	slot := this.next
+	if slot.Before(now) {
+		slot = now
+	}
+	this.next = slot.Add(this.interval)
+	this.mux.Unlock()
+	waited = slot.Sub(now)
+	if waited <= 0 {
+		return 0, nil
+	}
+	t := time.NewTimer(waited)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return time.Since(now), ctx.Err()
+	case <-t.C:
+		return waited, nil
+	}
+}
@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/configuration"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/metrics"
+	"testing"
+	"time"
+)
+
+func TestConnectLimiter(t *testing.T) {
+	var unlimited *connectLimiter
+	if waited, err := unlimited.Wait(context.Background()); waited != 0 || err != nil {
+		t.Error("expect no wait of a nil limiter, got", waited, err)
+	}
+	if newConnectLimiter(0) != nil {
+		t.Error("expect a nil limiter for rate 0")
+	}
+
+	limiter := newConnectLimiter(20) //50ms
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		_, err := limiter.Wait(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if min := time.Duration(i) * 50 * time.Millisecond; time.Since(start) < min {
+			t.Errorf("expect attempt %v after %v, got %v", i, min, time.Since(start))
+		}
+	}
+
+	limiter = newConnectLimiter(1)
+	_, _ = limiter.Wait(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	waited, err := limiter.Wait(ctx)
+	if err == nil || time.Since(start) > 500*time.Millisecond || waited > 500*time.Millisecond {
+		t.Error("expect the wait to end with the context, got", waited, err)
+	}
+}
+
+func TestStartRetryRecordsFailure(t *testing.T) {
+	config := configuration.Config{HubPrefix: "failed_startup", ConnectorType: "unknown"}
+	defer metrics.Unregister(config.HubPrefix)
+	connects := newConnectLimiter(1)
+	_, _ = connects.Wait(context.Background())
+	err := startRetry(context.Background(), nil, config, nil, nil, budgets{}, connects, 1)
+	if err == nil {
+		t.Fatal("expect error of an unknown connector type")
+	}
+	instance := metrics.Get(config.HubPrefix)
+	if instance == nil {
+		t.Fatal("expect the failed instance to be registered")
+	}
+	startup := instance.Startup()
+	if startup.Attempts != 1 || startup.Error == "" || startup.Duration <= 0 {
+		t.Errorf("unexpected startup record %#v", startup)
+	}
+	if startup.ConnectWait != 0 || startup.Duration > 500*time.Millisecond {
+		t.Errorf("expect no connect_rate wait before the mqtt connect, got %#v", startup)
+	}
+}
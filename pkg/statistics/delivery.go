@@ -0,0 +1,105 @@
+package statistics
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Delivery is the downstream outcome of a published event (see tracking.Tracker)
+type Delivery int
+
+const (
+	Received  Delivery = iota //arrived in order
+	Lost                      //not arrived within the tracking timeout
+	Duplicate                 //arrived again or without being published
+	Reordered                 //arrived after an event with a higher sequence number
+	Late                      //arrived after it was counted as lost
+	Gap                       //missing sequence number while a later event arrived
+	deliveryCount
+)
+
+var deliveryNames = []string{"received", "lost", "duplicate", "reordered", "late", "gap"}
+
+func (this Delivery) String() string {
+	if this < 0 || this >= deliveryCount {
+		return "unknown"
+	}
+	return deliveryNames[this]
+}
+
+// MaxLoggedDevices limits the device list of delivery logs
+const MaxLoggedDevices = 20
+
+type deliveryCounts [deliveryCount]uint64
+
+func (this deliveryCounts) anomalies() (result uint64) {
+	for i := Lost; i < deliveryCount; i++ {
+		result = result + this[i]
+	}
+	return result
+}
+
+func (this deliveryCounts) String() string {
+	parts := []string{}
+	for i := Received; i < deliveryCount; i++ {
+		parts = append(parts, i.String()+"="+strconv.FormatUint(this[i], 10))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Map returns the counts by delivery name
+func (this deliveryCounts) Map() map[string]uint64 {
+	result := map[string]uint64{}
+	for i := Received; i < deliveryCount; i++ {
+		result[i.String()] = this[i]
+	}
+	return result
+}
+
+// deliveries counts delivery outcomes per device
+type deliveries map[string]*deliveryCounts
+
+func (this deliveries) add(device string, delivery Delivery) {
+	counts, ok := this[device]
+	if !ok {
+		counts = &deliveryCounts{}
+		this[device] = counts
+	}
+	counts[delivery]++
+}
+
+func (this deliveries) total() (result deliveryCounts) {
+	for _, counts := range this {
+		for i := range counts {
+			result[i] = result[i] + counts[i]
+		}
+	}
+	return result
+}
+
+func (this deliveries) log(label string) {
+	if len(this) == 0 {
+		return
+	}
+	total := this.total()
+	devices := []string{}
+	for device, counts := range this {
+		if counts.anomalies() > 0 {
+			devices = append(devices, device)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return this[devices[i]].anomalies() > this[devices[j]].anomalies()
+	})
+	msg := "LOG: " + label + " delivery: " + total.String() + "\n\tdevices with anomalies: " + strconv.Itoa(len(devices))
+	for i, device := range devices {
+		if i >= MaxLoggedDevices {
+			msg = msg + "\n\t..."
+			break
+		}
+		msg = msg + "\n\t" + device + ": " + this[device].String()
+	}
+	log.Println(msg)
+}
@@ -0,0 +1,209 @@
+package statistics
+
+import (
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+const DefaultTopN = 5
+
+// deviceCounts are the counters of one device (or hub)
+type deviceCounts struct {
+	Emitted  uint64 `json:"emitted"`
+	Produced uint64 `json:"produced"`
+	Failed   uint64 `json:"failed"`
+	Commands uint64 `json:"commands"`
+}
+
+func (this *deviceCounts) add(other deviceCounts) {
+	this.Emitted = this.Emitted + other.Emitted
+	this.Produced = this.Produced + other.Produced
+	this.Failed = this.Failed + other.Failed
+	this.Commands = this.Commands + other.Commands
+}
+
+// deviceCounters counts per device in arrays allocated by TrackDevices (and extended by AddTrackedDevices);
+// updates are atomic increments of counters which keep their address when devices are added and each interval costs O(devices * log(devices)) for the ranking,
+// which keeps memory and cpu bounded for 100k devices
+type deviceCounters struct {
+	index    map[string]int
+	names    []string
+	interval []*deviceCounts
+	run      []deviceCounts
+	topN     int
+}
+
+func newDeviceCounters(devices []string, topN int) *deviceCounters {
+	if topN <= 0 {
+		topN = DefaultTopN
+	}
+	result := &deviceCounters{
+		index:    make(map[string]int, len(devices)),
+		names:    append([]string{}, devices...),
+		interval: make([]*deviceCounts, len(devices)),
+		run:      make([]deviceCounts, len(devices)),
+		topN:     topN,
+	}
+	for i, device := range devices {
+		result.index[device] = i
+		result.interval[i] = &deviceCounts{}
+	}
+	return result
+}
+
+// add appends counters for devices which are not tracked yet
+func (this *deviceCounters) add(devices []string) {
+	if this == nil {
+		return
+	}
+	for _, device := range devices {
+		if _, ok := this.index[device]; ok {
+			continue
+		}
+		this.index[device] = len(this.names)
+		this.names = append(this.names, device)
+		this.interval = append(this.interval, &deviceCounts{})
+		this.run = append(this.run, deviceCounts{})
+	}
+}
+
+// get returns the interval counters of device or nil if the device is not tracked
+func (this *deviceCounters) get(device string) *deviceCounts {
+	if this == nil {
+		return nil
+	}
+	i, ok := this.index[device]
+	if !ok {
+		return nil
+	}
+	return this.interval[i]
+}
+
+// rotate moves the interval counts into the run counts and returns the interval breakdown
+func (this *deviceCounters) rotate() *Breakdown {
+	if this == nil {
+		return nil
+	}
+	counts := make([]deviceCounts, len(this.interval))
+	for i := range this.interval {
+		counts[i] = deviceCounts{
+			Emitted:  atomic.SwapUint64(&this.interval[i].Emitted, 0),
+			Produced: atomic.SwapUint64(&this.interval[i].Produced, 0),
+			Failed:   atomic.SwapUint64(&this.interval[i].Failed, 0),
+			Commands: atomic.SwapUint64(&this.interval[i].Commands, 0),
+		}
+		this.run[i].add(counts[i])
+	}
+	return newBreakdown(this.names, counts, this.topN)
+}
+
+// total returns the run counts including the current interval
+func (this *deviceCounters) total() []deviceCounts {
+	result := make([]deviceCounts, len(this.run))
+	for i := range this.run {
+		result[i] = this.run[i]
+		result[i].add(deviceCounts{
+			Emitted:  atomic.LoadUint64(&this.interval[i].Emitted),
+			Produced: atomic.LoadUint64(&this.interval[i].Produced),
+			Failed:   atomic.LoadUint64(&this.interval[i].Failed),
+			Commands: atomic.LoadUint64(&this.interval[i].Commands),
+		})
+	}
+	return result
+}
+
+type Count struct {
+	Name string `json:"name"`
+	deviceCounts
+}
+
+// Breakdown describes the distribution of produced events over devices or hubs
+type Breakdown struct {
+	Count  int     `json:"count"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+	Cv     float64 `json:"cv"` //coefficient of variation (std_dev / mean); 0 is perfectly fair
+	Min    uint64  `json:"min"`
+	Max    uint64  `json:"max"`
+	Top    []Count `json:"top"`    //most produced events
+	Bottom []Count `json:"bottom"` //least produced events
+}
+
+func newBreakdown(names []string, counts []deviceCounts, topN int) *Breakdown {
+	result := &Breakdown{Count: len(counts)}
+	if len(counts) == 0 {
+		return result
+	}
+	sum := 0.0
+	for _, c := range counts {
+		sum = sum + float64(c.Produced)
+	}
+	result.Mean = sum / float64(len(counts))
+	variance := 0.0
+	for _, c := range counts {
+		d := float64(c.Produced) - result.Mean
+		variance = variance + d*d
+	}
+	result.StdDev = math.Sqrt(variance / float64(len(counts)))
+	if result.Mean > 0 {
+		result.Cv = result.StdDev / result.Mean
+	}
+	order := make([]int, len(counts))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return counts[order[i]].Produced > counts[order[j]].Produced
+	})
+	result.Max = counts[order[0]].Produced
+	result.Min = counts[order[len(order)-1]].Produced
+	for i := 0; i < topN && i < len(order); i++ {
+		result.Top = append(result.Top, Count{Name: names[order[i]], deviceCounts: counts[order[i]]})
+		bottom := order[len(order)-1-i]
+		result.Bottom = append(result.Bottom, Count{Name: names[bottom], deviceCounts: counts[bottom]})
+	}
+	return result
+}
+
+func (this *Breakdown) Log(label string) {
+	if this == nil || this.Count == 0 {
+		return
+	}
+	msg := "LOG: " + label + ": count=" + strconv.Itoa(this.Count) +
+		" mean=" + strconv.FormatFloat(this.Mean, 'f', 2, 64) +
+		" std-dev=" + strconv.FormatFloat(this.StdDev, 'f', 2, 64) +
+		" cv=" + strconv.FormatFloat(this.Cv, 'f', 3, 64) +
+		" min=" + strconv.FormatUint(this.Min, 10) +
+		" max=" + strconv.FormatUint(this.Max, 10)
+	msg = msg + "\n\ttop:"
+	for _, c := range this.Top {
+		msg = msg + "\n\t\t" + c.String()
+	}
+	msg = msg + "\n\tbottom:"
+	for _, c := range this.Bottom {
+		msg = msg + "\n\t\t" + c.String()
+	}
+	log.Println(msg)
+}
+
+func (this Count) String() string {
+	return this.Name + ": produced=" + strconv.FormatUint(this.Produced, 10) +
+		" emitted=" + strconv.FormatUint(this.Emitted, 10) +
+		" failed=" + strconv.FormatUint(this.Failed, 10) +
+		" commands=" + strconv.FormatUint(this.Commands, 10)
+}
+
+// HubBreakdown compares the run summaries of multiple hubs; returns nil for less than two hubs
+func HubBreakdown(hubs []string, runs []RunSummary) *Breakdown {
+	if len(hubs) < 2 || len(hubs) != len(runs) {
+		return nil
+	}
+	counts := make([]deviceCounts, len(runs))
+	for i, run := range runs {
+		counts[i] = deviceCounts{Emitted: run.Emitted, Produced: run.Produced, Failed: run.Failed, Commands: run.CommandsHandled}
+	}
+	return newBreakdown(hubs, counts, DefaultTopN)
+}
@@ -0,0 +1,77 @@
+package statistics
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// ErrorCategory classifies failures counted with Interface.Error
+type ErrorCategory string
+
+const (
+	NotConnected   ErrorCategory = "not_connected"
+	PublishTimeout ErrorCategory = "publish_timeout"
+	BrokerRejected ErrorCategory = "broker_rejected"
+	Http4xx        ErrorCategory = "http_4xx"
+	Http5xx        ErrorCategory = "http_5xx"
+	HttpRequest    ErrorCategory = "http_request" //no response, e.g. connection refused
+	HttpStatus     ErrorCategory = "http_status"  //unexpected status code below 400, e.g. a redirect
+	AuthFailed     ErrorCategory = "auth"
+	Unmarshal      ErrorCategory = "unmarshal"
+	Template       ErrorCategory = "template" //payload template could not be rendered; the message is skipped
+)
+
+// HttpErrorCategory classifies a failed request by the status code of resp (which may be nil)
+func HttpErrorCategory(resp *http.Response) ErrorCategory {
+	switch {
+	case resp == nil:
+		return HttpRequest
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return AuthFailed
+	case resp.StatusCode >= 500:
+		return Http5xx
+	case resp.StatusCode >= 400:
+		return Http4xx
+	}
+	return HttpStatus
+}
+
+// errorCounts counts errors by category and endpoint (e.g. "process-engine/start" or "mqtt/event")
+type errorCounts map[string]uint64
+
+func errorKey(category ErrorCategory, endpoint string) string {
+	if endpoint == "" {
+		return string(category)
+	}
+	return string(category) + ":" + endpoint
+}
+
+func (this errorCounts) add(category ErrorCategory, endpoint string) {
+	this[errorKey(category, endpoint)]++
+}
+
+func (this errorCounts) copy() map[string]uint64 {
+	result := map[string]uint64{}
+	for key, count := range this {
+		result[key] = count
+	}
+	return result
+}
+
+func (this errorCounts) log(label string) {
+	if len(this) == 0 {
+		return
+	}
+	keys := []string{}
+	for key := range this {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	msg := "LOG: " + label + " errors:"
+	for _, key := range keys {
+		msg = msg + "\n\t" + key + ": " + strconv.FormatUint(this[key], 10)
+	}
+	log.Println(msg)
+}
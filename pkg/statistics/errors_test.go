@@ -0,0 +1,29 @@
+package statistics
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHttpErrorCategory(t *testing.T) {
+	tests := []struct {
+		name   string
+		resp   *http.Response
+		expect ErrorCategory
+	}{
+		{name: "no response", resp: nil, expect: HttpRequest},
+		{name: "unauthorized", resp: &http.Response{StatusCode: http.StatusUnauthorized}, expect: AuthFailed},
+		{name: "forbidden", resp: &http.Response{StatusCode: http.StatusForbidden}, expect: AuthFailed},
+		{name: "not found", resp: &http.Response{StatusCode: http.StatusNotFound}, expect: Http4xx},
+		{name: "bad gateway", resp: &http.Response{StatusCode: http.StatusBadGateway}, expect: Http5xx},
+		{name: "redirect", resp: &http.Response{StatusCode: http.StatusFound}, expect: HttpStatus},
+		{name: "no content", resp: &http.Response{StatusCode: http.StatusNoContent}, expect: HttpStatus},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := HttpErrorCategory(test.resp); actual != test.expect {
+				t.Errorf("expect %v, got %v", test.expect, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package statistics
+
+import (
+	"encoding/json"
+	"errors"
+	"math/bits"
+	"strconv"
+	"time"
+)
+
+// Histogram records durations with fixed memory in log-linear buckets (HDR style):
+// values are stored in microseconds with a relative error below 1/histogramSubBucketHalfCount (< 1%), up to HistogramMaxValue.
+// a Histogram is not thread safe.
+type Histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+	min    int64
+	max    int64
+}
+
+const HistogramMaxValue = time.Hour
+const HistogramResolution = time.Microsecond
+
+const histogramSubBucketBits = 8
+const histogramSubBucketCount = 1 << histogramSubBucketBits
+const histogramSubBucketHalfCount = histogramSubBucketCount / 2
+
+var histogramSize = histogramIndex(int64(HistogramMaxValue/HistogramResolution)) + 1
+
+func NewHistogram() *Histogram {
+	return &Histogram{counts: make([]uint64, histogramSize)}
+}
+
+func histogramIndex(value int64) int {
+	if value < histogramSubBucketCount {
+		return int(value)
+	}
+	shift := bits.Len64(uint64(value)) - histogramSubBucketBits
+	return shift*histogramSubBucketHalfCount + int(value>>uint(shift))
+}
+
+// histogramValue returns the highest value stored in the bucket with index
+func histogramValue(index int) int64 {
+	if index < histogramSubBucketCount {
+		return int64(index)
+	}
+	shift := index/histogramSubBucketHalfCount - 1
+	sub := int64(index%histogramSubBucketHalfCount + histogramSubBucketHalfCount)
+	return ((sub + 1) << uint(shift)) - 1
+}
+
+func (this *Histogram) Record(duration time.Duration) {
+	value := int64(duration / HistogramResolution)
+	if value < 0 {
+		value = 0
+	}
+	if value > int64(HistogramMaxValue/HistogramResolution) {
+		value = int64(HistogramMaxValue / HistogramResolution)
+	}
+	this.counts[histogramIndex(value)]++
+	if this.count == 0 || value < this.min {
+		this.min = value
+	}
+	if value > this.max {
+		this.max = value
+	}
+	this.count++
+	this.sum = this.sum + float64(value)
+}
+
+func (this *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+	for i, count := range other.counts {
+		this.counts[i] = this.counts[i] + count
+	}
+	if this.count == 0 || other.min < this.min {
+		this.min = other.min
+	}
+	if other.max > this.max {
+		this.max = other.max
+	}
+	this.count = this.count + other.count
+	this.sum = this.sum + other.sum
+}
+
+func (this *Histogram) Copy() *Histogram {
+	result := NewHistogram()
+	result.Merge(this)
+	return result
+}
+
+// since returns the values recorded after the earlier copy base (nil: all values);
+// min and max are limited to the bounds of the remaining buckets
+func (this *Histogram) since(base *Histogram) *Histogram {
+	result := this.Copy()
+	if base == nil || base.count == 0 {
+		return result
+	}
+	for i, count := range base.counts {
+		if count > result.counts[i] {
+			count = result.counts[i]
+		}
+		result.counts[i] = result.counts[i] - count
+	}
+	if base.count >= result.count {
+		result.Reset()
+		return result
+	}
+	result.count = result.count - base.count
+	result.sum = result.sum - base.sum
+	if result.sum < 0 {
+		result.sum = 0
+	}
+	first, last := -1, -1
+	for i, count := range result.counts {
+		if count > 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		result.Reset()
+		return result
+	}
+	if first > 0 && histogramValue(first-1)+1 > result.min {
+		result.min = histogramValue(first-1) + 1
+	}
+	if histogramValue(last) < result.max {
+		result.max = histogramValue(last)
+	}
+	return result
+}
+
+func (this *Histogram) Reset() {
+	for i := range this.counts {
+		this.counts[i] = 0
+	}
+	this.count = 0
+	this.sum = 0
+	this.min = 0
+	this.max = 0
+}
+
+func (this *Histogram) Count() uint64 {
+	return this.count
+}
+
+// CountUpTo returns the number of recorded values less than or equal to limit (within the histogram precision)
+func (this *Histogram) CountUpTo(limit time.Duration) (result uint64) {
+	value := int64(limit / HistogramResolution)
+	if value > int64(HistogramMaxValue/HistogramResolution) {
+		return this.count
+	}
+	if value < 0 {
+		return 0
+	}
+	last := histogramIndex(value)
+	for i := 0; i <= last; i++ {
+		result = result + this.counts[i]
+	}
+	return result
+}
+
+// Sum returns the sum of all recorded values
+func (this *Histogram) Sum() time.Duration {
+	return time.Duration(this.sum) * HistogramResolution
+}
+
+// Percentile returns the value below or equal to which p percent (0-100) of the recorded values fall
+func (this *Histogram) Percentile(p float64) time.Duration {
+	if this.count == 0 {
+		return 0
+	}
+	target := uint64(float64(this.count)*p/100 + 0.5)
+	if target < 1 {
+		target = 1
+	}
+	sum := uint64(0)
+	for i, count := range this.counts {
+		sum = sum + count
+		if sum >= target {
+			value := histogramValue(i)
+			if value > this.max {
+				value = this.max
+			}
+			if value < this.min {
+				value = this.min
+			}
+			return time.Duration(value) * HistogramResolution
+		}
+	}
+	return time.Duration(this.max) * HistogramResolution
+}
+
+func (this *Histogram) Summary() (result LatencySummary) {
+	result.Count = this.count
+	if this.count == 0 {
+		return result
+	}
+	result.Min = time.Duration(this.min) * HistogramResolution
+	result.Max = time.Duration(this.max) * HistogramResolution
+	result.Avg = time.Duration(this.sum/float64(this.count)) * HistogramResolution
+	result.P50 = this.Percentile(50)
+	result.P90 = this.Percentile(90)
+	result.P95 = this.Percentile(95)
+	result.P99 = this.Percentile(99)
+	result.P999 = this.Percentile(99.9)
+	return result
+}
+
+// histogramJson is the json representation of a Histogram with the non-empty buckets as [index, count] pairs
+type histogramJson struct {
+	Buckets [][2]uint64 `json:"buckets"`
+	Count   uint64      `json:"count"`
+	Sum     float64     `json:"sum"`
+	Min     int64       `json:"min"`
+	Max     int64       `json:"max"`
+}
+
+func (this *Histogram) MarshalJSON() ([]byte, error) {
+	result := histogramJson{Buckets: [][2]uint64{}, Count: this.count, Sum: this.sum, Min: this.min, Max: this.max}
+	for i, count := range this.counts {
+		if count > 0 {
+			result.Buckets = append(result.Buckets, [2]uint64{uint64(i), count})
+		}
+	}
+	return json.Marshal(result)
+}
+
+func (this *Histogram) UnmarshalJSON(data []byte) error {
+	temp := histogramJson{}
+	err := json.Unmarshal(data, &temp)
+	if err != nil {
+		return err
+	}
+	counts := make([]uint64, histogramSize)
+	sum := uint64(0)
+	for _, bucket := range temp.Buckets {
+		if bucket[0] >= uint64(histogramSize) {
+			return errors.New("histogram bucket out of range: " + strconv.FormatUint(bucket[0], 10))
+		}
+		counts[bucket[0]] = counts[bucket[0]] + bucket[1]
+		sum = sum + bucket[1]
+	}
+	if sum != temp.Count {
+		return errors.New("histogram count does not match its buckets")
+	}
+	this.counts, this.count, this.sum, this.min, this.max = counts, temp.Count, temp.Sum, temp.Min, temp.Max
+	return nil
+}
+
+type LatencySummary struct {
+	Count uint64        `json:"count"`
+	Min   time.Duration `json:"min"`
+	Avg   time.Duration `json:"avg"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+	P999  time.Duration `json:"p999"`
+	Max   time.Duration `json:"max"`
+}
+
+func (this LatencySummary) String() string {
+	return "count=" + strconv.FormatUint(this.Count, 10) +
+		" min=" + this.Min.String() +
+		" avg=" + this.Avg.String() +
+		" p50=" + this.P50.String() +
+		" p90=" + this.P90.String() +
+		" p95=" + this.P95.String() +
+		" p99=" + this.P99.String() +
+		" p99.9=" + this.P999.String() +
+		" max=" + this.Max.String()
+}
+
+// latency records an interval histogram, which is merged into the run histogram on each rotate
+type latency struct {
+	interval *Histogram
+	run      *Histogram
+}
+
+func newLatency() *latency {
+	return &latency{interval: NewHistogram(), run: NewHistogram()}
+}
+
+func (this *latency) Record(duration time.Duration) {
+	this.interval.Record(duration)
+}
+
+// rotate returns the summary of the current interval and starts a new one
+func (this *latency) rotate() LatencySummary {
+	result := this.interval.Summary()
+	this.rotateInto(nil)
+	return result
+}
+
+// rotateInto merges the current interval into result (if not nil) and starts a new one
+func (this *latency) rotateInto(result *Histogram) {
+	if result != nil {
+		result.Merge(this.interval)
+	}
+	this.run.Merge(this.interval)
+	this.interval.Reset()
+}
+
+// total returns the summary of the whole run including the current interval
+func (this *latency) total() LatencySummary {
+	return this.totalHistogram().Summary()
+}
+
+func (this *latency) totalHistogram() *Histogram {
+	result := NewHistogram()
+	result.Merge(this.run)
+	result.Merge(this.interval)
+	return result
+}
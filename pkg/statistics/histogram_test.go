@@ -0,0 +1,119 @@
+package statistics
+
+import (
+	"encoding/json"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestHistogramIndex(t *testing.T) {
+	previous := int64(-1)
+	for index := 0; index < histogramSize; index++ {
+		value := histogramValue(index)
+		if value <= previous {
+			t.Fatalf("expect increasing bucket values, got %v after %v at %v", value, previous, index)
+		}
+		if histogramIndex(value) != index || histogramIndex(previous+1) != index {
+			t.Fatalf("expect the values %v to %v in bucket %v", previous+1, value, index)
+		}
+		previous = value
+	}
+	if previous < int64(HistogramMaxValue/HistogramResolution) {
+		t.Error("expect the buckets to cover HistogramMaxValue, got", previous)
+	}
+}
+
+func TestHistogramPercentiles(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	h := NewHistogram()
+	values := []time.Duration{}
+	for i := 0; i < 10000; i++ {
+		value := time.Duration(r.ExpFloat64() * float64(50*time.Millisecond)).Truncate(HistogramResolution)
+		values = append(values, value)
+		h.Record(value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i] < values[j]
+	})
+	for _, p := range []float64{50, 90, 99, 99.9} {
+		expected := values[int(float64(len(values))*p/100+0.5)-1]
+		result := h.Percentile(p)
+		if diff := float64(result-expected) / float64(expected); diff < 0 || diff > 0.01 {
+			t.Errorf("expect p%v %v within 1%%, got %v", p, expected, result)
+		}
+	}
+	summary := h.Summary()
+	if summary.Count != 10000 || summary.Min != values[0] || summary.Max != values[len(values)-1] {
+		t.Errorf("unexpected summary %v", summary.String())
+	}
+	if h.CountUpTo(values[4999]) < 5000 || h.CountUpTo(values[4999]) > 5100 || h.CountUpTo(HistogramMaxValue+time.Hour) != 10000 || h.CountUpTo(-1) != 0 {
+		t.Error("unexpected counts up to limits")
+	}
+}
+
+func TestHistogramLimits(t *testing.T) {
+	h := NewHistogram()
+	h.Record(-time.Second)
+	h.Record(2 * HistogramMaxValue)
+	summary := h.Summary()
+	if summary.Min != 0 || summary.Max != HistogramMaxValue || summary.P999 != HistogramMaxValue {
+		t.Errorf("expect values limited to 0 and HistogramMaxValue, got %v", summary.String())
+	}
+	merged := NewHistogram()
+	merged.Merge(h)
+	merged.Merge(NewHistogram())
+	if merged.Summary() != summary {
+		t.Errorf("expect the merge to keep the summary, got %v", merged.Summary().String())
+	}
+	h.Reset()
+	if h.Summary() != (LatencySummary{}) {
+		t.Error("expect an empty summary after reset")
+	}
+}
+
+func TestHistogramJson(t *testing.T) {
+	h := NewHistogram()
+	for _, d := range []time.Duration{time.Millisecond, 2 * time.Millisecond, time.Second, time.Second} {
+		h.Record(d)
+	}
+	temp, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &Histogram{}
+	err = json.Unmarshal(temp, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Summary() != h.Summary() {
+		t.Errorf("expect %v, got %v", h.Summary().String(), result.Summary().String())
+	}
+	for _, invalid := range []string{`{"buckets": [[999999, 1]], "count": 1}`, `{"buckets": [[1, 1]], "count": 2}`} {
+		if json.Unmarshal([]byte(invalid), &Histogram{}) == nil {
+			t.Error("expect error for", invalid)
+		}
+	}
+}
+
+func TestHistogramSince(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 100; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	base := h.Copy()
+	for i := 0; i < 50; i++ {
+		h.Record(500 * time.Millisecond)
+	}
+	since := h.since(base).Summary()
+	if since.Count != 50 || since.P50 < 495*time.Millisecond || since.Min < 495*time.Millisecond || since.Max != 500*time.Millisecond {
+		t.Errorf("unexpected summary since the base %#v", since)
+	}
+	if since.Avg < 495*time.Millisecond || since.Avg > 505*time.Millisecond {
+		t.Error("unexpected average", since.Avg)
+	}
+	if h.since(h.Copy()).Count() != 0 || h.since(nil).Count() != 150 {
+		t.Error("unexpected count since an equal or no base")
+	}
+}
@@ -0,0 +1,108 @@
+package statistics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// phase is the state of the statistics at the start of the current scenario phase (see StartPhase)
+type phase struct {
+	index      int
+	name       string
+	started    time.Time
+	counters   counters
+	histograms *RunHistograms
+}
+
+// counters are the run totals of all services
+type counters struct {
+	ServiceTotals
+	missed             uint64
+	blocked            uint64
+	processesTriggered uint64
+	deliveries         deliveryCounts
+	errors             errorCounts
+}
+
+// counters returns the current run totals; the caller has to hold eventMux
+func (this *Implementation) counters() (result counters) {
+	this.eachShard(func(service string, shard *serviceShard) {
+		totals := shard.totals.copy()
+		result.Emitted = result.Emitted + totals.Emitted
+		result.Produced = result.Produced + totals.Produced
+		result.Failed = result.Failed + totals.Failed
+		result.CommandsHandled = result.CommandsHandled + totals.CommandsHandled
+	})
+	result.missed = atomic.LoadUint64(&this.totals.Missed)
+	result.blocked = atomic.LoadUint64(&this.totals.Blocked)
+	result.processesTriggered = atomic.LoadUint64(&this.totals.ProcessesTriggered)
+	result.deliveries = this.runDeliveries.total()
+	result.errors = this.runErrors.copy()
+	return result
+}
+
+// histograms returns the current run histograms; the caller has to hold eventMux
+func (this *Implementation) histograms() *RunHistograms {
+	result := &RunHistograms{
+		ProduceTime:       this.produceTimes((*latency).totalHistogram),
+		SendDelay:         this.sendDelays.totalHistogram(),
+		CommandLatency:    this.commandLatencies.totalHistogram(),
+		ProcessCompletion: this.processCompletions.totalHistogram(),
+		EventLatency:      map[string]*Histogram{},
+	}
+	for service, l := range this.eventLatencies {
+		result.EventLatency[service] = l.totalHistogram()
+	}
+	return result
+}
+
+// since returns the difference to the earlier totals base
+func (this counters) since(base counters) (result counters) {
+	result.Emitted = this.Emitted - base.Emitted
+	result.Produced = this.Produced - base.Produced
+	result.Failed = this.Failed - base.Failed
+	result.CommandsHandled = this.CommandsHandled - base.CommandsHandled
+	result.missed = this.missed - base.missed
+	result.blocked = this.blocked - base.blocked
+	result.processesTriggered = this.processesTriggered - base.processesTriggered
+	for i := range this.deliveries {
+		result.deliveries[i] = this.deliveries[i] - base.deliveries[i]
+	}
+	result.errors = errorCounts{}
+	for key, count := range this.errors {
+		if count > base.errors[key] {
+			result.errors[key] = count - base.errors[key]
+		}
+	}
+	return result
+}
+
+// StartPhase marks the start of a scenario phase: the current interval is written, the following intervals are labeled with name
+// and SummarizePhase covers the statistics from now on
+func (this *Implementation) StartPhase(index int, name string) {
+	if this.logAndResetInterval > 0 {
+		this.Flush()
+	}
+	this.eventMux.Lock()
+	defer this.eventMux.Unlock()
+	this.phase = phase{
+		index:      index,
+		name:       name,
+		started:    time.Now(),
+		counters:   this.counters(),
+		histograms: this.histograms(),
+	}
+}
+
+// Phase returns the 1 based index and the name of the current scenario phase; 0 and "" before the first phase
+func (this *Implementation) Phase() (index int, name string) {
+	this.eventMux.Lock()
+	defer this.eventMux.Unlock()
+	return this.phase.index, this.phase.name
+}
+
+// SummarizePhase merges the statistics of list since their last StartPhase (without device breakdown)
+func SummarizePhase(list []*Implementation) (result RunSummary) {
+	result, _ = summarize(list, true)
+	return result
+}
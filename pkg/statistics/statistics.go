@@ -4,106 +4,495 @@ import (
 	"context"
 	"log"
 	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
+// MaxIntervals is the number of finished intervals kept for Intervals and the report; older intervals are dropped.
+// use a csv_file or influx statistics sink for the complete time series of long runs
+const MaxIntervals = 1000
+
 type Interface interface {
-	EventProduce(duration time.Duration)
-	EventEmitted()
-	CommandsHandled()
+	EventProduce(device string, service string, duration time.Duration)
+	EventDelay(duration time.Duration)
+	EventEmitted(device string, service string)
+	EventFailed(device string, service string)
+	EventMissed()
+	EventBlocked()
+	QueueDepth(depth int, capacity int)
+	EventLatency(service string, duration time.Duration)
+	EventDelivery(device string, delivery Delivery)
+	CommandsHandled(device string, service string)
+	CommandLatency(duration time.Duration)
+	ProcessTriggered()
+	ProcessCompleted(duration time.Duration)
+	Error(category ErrorCategory, endpoint string)
 }
 
 type Void struct{}
 
-func (this Void) EventProduce(duration time.Duration) {}
-func (this Void) EventEmitted()                       {}
-func (this Void) CommandsHandled()                    {}
+func (this Void) EventProduce(device string, service string, duration time.Duration) {}
+func (this Void) EventDelay(duration time.Duration)                                  {}
+func (this Void) EventEmitted(device string, service string)                         {}
+func (this Void) EventFailed(device string, service string)                          {}
+func (this Void) EventMissed()                                                       {}
+func (this Void) EventBlocked()                                                      {}
+func (this Void) QueueDepth(depth int, capacity int)                                 {}
+func (this Void) EventLatency(service string, duration time.Duration)                {}
+func (this Void) EventDelivery(device string, delivery Delivery)                     {}
+func (this Void) CommandsHandled(device string, service string)                      {}
+func (this Void) CommandLatency(duration time.Duration)                              {}
+func (this Void) ProcessTriggered()                                                  {}
+func (this Void) Error(category ErrorCategory, endpoint string)                      {}
+func (this Void) ProcessCompleted(duration time.Duration)                            {}
+
+// Sink receives the summary of each statistics interval (see pkg/sinks)
+type Sink interface {
+	Write(summary IntervalSummary) error
+}
 
-func New(ctx context.Context, logAndResetInterval time.Duration) Interface {
-	result := &Implementation{}
+func New(ctx context.Context, logAndResetInterval time.Duration, sinks []Sink) *Implementation {
+	result := &Implementation{
+		logAndResetInterval: logAndResetInterval,
+		sinks:               sinks,
+		sendDelays:          newLatency(),
+		eventLatencies:      map[string]*latency{},
+		commandLatencies:    newLatency(),
+		processCompletions:  newLatency(),
+		shards:              map[string]*serviceShard{},
+		deliveries:          deliveries{},
+		runDeliveries:       deliveries{},
+		errors:              errorCounts{},
+		runErrors:           errorCounts{},
+		started:             time.Now(),
+		done:                make(chan struct{}),
+	}
+	result.intervalStart = result.started
+	result.phase.started = result.started
 	result.Start(ctx, logAndResetInterval)
 	return result
 }
 
 type Implementation struct {
 	logAndResetInterval  time.Duration
-	producedEvents       []time.Duration
+	sendDelays           *latency
+	eventLatencies       map[string]*latency
+	deliveries           deliveries
+	runDeliveries        deliveries
+	errors               errorCounts
+	runErrors            errorCounts
 	emittedCount         uint64
+	missedCount          uint64
+	blockedCount         uint64
+	queueDepth           int
+	maxQueueDepth        int
+	queueCapacity        int
 	commandsHandledCount uint64
+	commandLatencies     *latency
+	processesTriggered   uint64
+	processCompletions   *latency
+	failedCount          uint64
+	shards               map[string]*serviceShard
+	totals               Totals //without Services, see shards
+	started              time.Time
+	ended                time.Time
+	intervalStart        time.Time
+	intervals            []IntervalSummary
+	sinks                []Sink
+	devices              *deviceCounters
+	phase                phase
+	done                 chan struct{}
 	eventMux             sync.Mutex
+	shardsMux            sync.RWMutex //guards shards and the device index of devices; taken for reading on each event
+}
+
+// Totals are the counters of the whole run
+type Totals struct {
+	Missed             uint64                    `json:"missed"`
+	Blocked            uint64                    `json:"blocked"`
+	ProcessesTriggered uint64                    `json:"processes_triggered"`
+	Services           map[string]*ServiceTotals `json:"services"`
+}
+
+type ServiceTotals struct {
+	Emitted         uint64 `json:"emitted"`
+	Produced        uint64 `json:"produced"`
+	Failed          uint64 `json:"failed"`
+	CommandsHandled uint64 `json:"commands_handled"`
+}
+
+// serviceShard holds the counters and produce times of one service;
+// events only lock the statistics of their service, so that senders of different services do not contend
+type serviceShard struct {
+	totals       ServiceTotals //atomic
+	mux          sync.Mutex
+	produceTimes *latency
+}
+
+// shard returns the statistics of service
+func (this *Implementation) shard(service string) *serviceShard {
+	this.shardsMux.RLock()
+	result, ok := this.shards[service]
+	this.shardsMux.RUnlock()
+	if ok {
+		return result
+	}
+	this.shardsMux.Lock()
+	defer this.shardsMux.Unlock()
+	result, ok = this.shards[service]
+	if !ok {
+		result = &serviceShard{produceTimes: newLatency()}
+		this.shards[service] = result
+	}
+	return result
+}
+
+// eachShard calls f with the shard of each service while holding its lock
+func (this *Implementation) eachShard(f func(service string, shard *serviceShard)) {
+	this.shardsMux.RLock()
+	defer this.shardsMux.RUnlock()
+	for service, shard := range this.shards {
+		shard.mux.Lock()
+		f(service, shard)
+		shard.mux.Unlock()
+	}
+}
+
+// produceTimes merges histogram (e.g. (*latency).totalHistogram) of all services
+func (this *Implementation) produceTimes(histogram func(l *latency) *Histogram) *Histogram {
+	result := NewHistogram()
+	this.eachShard(func(service string, shard *serviceShard) {
+		result.Merge(histogram(shard.produceTimes))
+	})
+	return result
+}
+
+// device returns the interval counters of device or nil if the device is not tracked
+func (this *Implementation) device(device string) *deviceCounts {
+	this.shardsMux.RLock()
+	defer this.shardsMux.RUnlock()
+	return this.devices.get(device)
+}
+
+func (this *ServiceTotals) copy() ServiceTotals {
+	return ServiceTotals{
+		Emitted:         atomic.LoadUint64(&this.Emitted),
+		Produced:        atomic.LoadUint64(&this.Produced),
+		Failed:          atomic.LoadUint64(&this.Failed),
+		CommandsHandled: atomic.LoadUint64(&this.CommandsHandled),
+	}
 }
 
-func (this *Implementation) EventProduce(duration time.Duration) {
+func (this *Implementation) EventProduce(device string, service string, duration time.Duration) {
+	if counts := this.device(device); counts != nil {
+		atomic.AddUint64(&counts.Produced, 1)
+	}
+	shard := this.shard(service)
+	atomic.AddUint64(&shard.totals.Produced, 1)
+	shard.mux.Lock()
+	defer shard.mux.Unlock()
+	shard.produceTimes.Record(duration)
+}
+
+// EventDelay records the time between the scheduled and the actual send of an event
+func (this *Implementation) EventDelay(duration time.Duration) {
 	this.eventMux.Lock()
 	defer this.eventMux.Unlock()
-	this.producedEvents = append(this.producedEvents, duration)
+	this.sendDelays.Record(duration)
 }
-
-func (this *Implementation) EventEmitted() {
+func (this *Implementation) EventEmitted(device string, service string) {
 	atomic.AddUint64(&this.emittedCount, 1)
+	if counts := this.device(device); counts != nil {
+		atomic.AddUint64(&counts.Emitted, 1)
+	}
+	atomic.AddUint64(&this.shard(service).totals.Emitted, 1)
+}
+
+// EventFailed counts events which could not be sent
+func (this *Implementation) EventFailed(device string, service string) {
+	atomic.AddUint64(&this.failedCount, 1)
+	if counts := this.device(device); counts != nil {
+		atomic.AddUint64(&counts.Failed, 1)
+	}
+	atomic.AddUint64(&this.shard(service).totals.Failed, 1)
+}
+
+// EventMissed counts scheduled events that could not be sent because the sender was saturated
+func (this *Implementation) EventMissed() {
+	atomic.AddUint64(&this.missedCount, 1)
+	atomic.AddUint64(&this.totals.Missed, 1)
 }
 
-func (this *Implementation) CommandsHandled() {
+// EventBlocked counts events which had to wait for a free slot in the emitter queue
+func (this *Implementation) EventBlocked() {
+	atomic.AddUint64(&this.blockedCount, 1)
+	atomic.AddUint64(&this.totals.Blocked, 1)
+}
+
+func (this *Implementation) QueueDepth(depth int, capacity int) {
+	this.eventMux.Lock()
+	defer this.eventMux.Unlock()
+	this.queueDepth = depth
+	this.queueCapacity = capacity
+	if depth > this.maxQueueDepth {
+		this.maxQueueDepth = depth
+	}
+}
+
+// EventLatency records the time between publishing an event and its arrival downstream
+func (this *Implementation) EventLatency(service string, duration time.Duration) {
+	this.eventMux.Lock()
+	defer this.eventMux.Unlock()
+	l, ok := this.eventLatencies[service]
+	if !ok {
+		l = newLatency()
+		this.eventLatencies[service] = l
+	}
+	l.Record(duration)
+}
+
+// EventDelivery counts the downstream outcome of a published event per device
+func (this *Implementation) EventDelivery(device string, delivery Delivery) {
+	this.eventMux.Lock()
+	defer this.eventMux.Unlock()
+	this.deliveries.add(device, delivery)
+	this.runDeliveries.add(device, delivery)
+}
+
+func (this *Implementation) CommandsHandled(device string, service string) {
 	atomic.AddUint64(&this.commandsHandledCount, 1)
+	if counts := this.device(device); counts != nil {
+		atomic.AddUint64(&counts.Commands, 1)
+	}
+	atomic.AddUint64(&this.shard(service).totals.CommandsHandled, 1)
 }
 
+// CommandLatency records the time between a process trigger and the receipt of its command by the device
+func (this *Implementation) CommandLatency(duration time.Duration) {
+	this.eventMux.Lock()
+	defer this.eventMux.Unlock()
+	this.commandLatencies.Record(duration)
+}
+
+func (this *Implementation) ProcessTriggered() {
+	atomic.AddUint64(&this.processesTriggered, 1)
+	atomic.AddUint64(&this.totals.ProcessesTriggered, 1)
+}
+
+// ProcessCompleted records the time between a process trigger and the end of the process instance
+func (this *Implementation) ProcessCompleted(duration time.Duration) {
+	this.eventMux.Lock()
+	defer this.eventMux.Unlock()
+	this.processCompletions.Record(duration)
+}
+
+// TrackDevices enables per device counters with top-n/bottom-n reporting; has to be called before the first event
+func (this *Implementation) TrackDevices(devices []string, topN int) {
+	this.shardsMux.Lock()
+	defer this.shardsMux.Unlock()
+	this.devices = newDeviceCounters(devices, topN)
+}
+
+// AddTrackedDevices adds devices (e.g. on scaling) if TrackDevices is enabled
+func (this *Implementation) AddTrackedDevices(devices []string) {
+	this.shardsMux.Lock()
+	defer this.shardsMux.Unlock()
+	this.devices.add(devices)
+}
+
+// Error counts a failure by category and endpoint
+func (this *Implementation) Error(category ErrorCategory, endpoint string) {
+	this.eventMux.Lock()
+	defer this.eventMux.Unlock()
+	this.errors.add(category, endpoint)
+	this.runErrors.add(category, endpoint)
+}
+
+// Done is closed after the final interval and the run summary are recorded
+func (this *Implementation) Done() <-chan struct{} {
+	return this.done
+}
+
+// Start logs and resets the interval statistics every interval and logs the run summary when ctx is done;
+// interval <= 0 disables the interval log, but sinks still receive the whole run as one final interval
 func (this *Implementation) Start(ctx context.Context, interval time.Duration) {
-	t := time.NewTicker(interval)
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		tick = t.C
+	}
 	go func() {
 		for {
 			select {
 			case <-ctx.Done():
+				if interval > 0 || len(this.sinks) > 0 {
+					this.write(this.log())
+				}
+				this.logRun()
+				close(this.done)
 				return
-			case <-t.C:
-				this.log()
+			case <-tick:
+				this.write(this.log())
 			}
 		}
 	}()
+}
 
+// Snapshot is a copy of the statistics of the whole run
+type Snapshot struct {
+	Totals
+	QueueDepth         int
+	QueueCapacity      int
+	ProduceTimes       map[string]*Histogram
+	EventLatencies     map[string]*Histogram
+	CommandLatencies   *Histogram
+	ProcessCompletions *Histogram
+	Errors             map[string]uint64 //by category or category:endpoint
+	Phase              string            //name of the current scenario phase
+	PhaseIndex         int               //1 based index of the current scenario phase; 0 before the first phase
 }
 
-func (this *Implementation) log() {
+func (this *Implementation) Snapshot() (result Snapshot) {
 	this.eventMux.Lock()
 	defer this.eventMux.Unlock()
+	result.Missed = atomic.LoadUint64(&this.totals.Missed)
+	result.Blocked = atomic.LoadUint64(&this.totals.Blocked)
+	result.ProcessesTriggered = atomic.LoadUint64(&this.totals.ProcessesTriggered)
+	result.Services = map[string]*ServiceTotals{}
+	result.ProduceTimes = map[string]*Histogram{}
+	this.eachShard(func(service string, shard *serviceShard) {
+		totals := shard.totals.copy()
+		result.Services[service] = &totals
+		result.ProduceTimes[service] = shard.produceTimes.totalHistogram()
+	})
+	result.QueueDepth = this.queueDepth
+	result.QueueCapacity = this.queueCapacity
+	result.EventLatencies = map[string]*Histogram{}
+	for service, l := range this.eventLatencies {
+		result.EventLatencies[service] = l.totalHistogram()
+	}
+	result.CommandLatencies = this.commandLatencies.totalHistogram()
+	result.ProcessCompletions = this.processCompletions.totalHistogram()
+	result.Errors = this.runErrors.copy()
+	result.Phase = this.phase.name
+	result.PhaseIndex = this.phase.index
+	return result
+}
 
-	produced := len(this.producedEvents)
-	emitted := atomic.LoadUint64(&this.emittedCount)
-	commands := atomic.LoadUint64(&this.commandsHandledCount)
-
-	median, avg, min, max := statistics(this.producedEvents)
-	log.Println("LOG: produced events:", "\n\tcommands:", commands, "\n\temitted:", emitted, "\n\tproduced:", produced, "\n\tmedian-produce-time:", median.String(), "\n\tavg-produce-time:", avg.String(), "\n\tmin-produce-tim:", min.String(), "\n\tmax-produce-tim:", max.String())
+// Flush ends the current statistics interval early and writes it to the log and sinks
+func (this *Implementation) Flush() {
+	this.write(this.log())
+}
 
-	this.producedEvents = []time.Duration{}
-	atomic.StoreUint64(&this.emittedCount, 0)
-	atomic.StoreUint64(&this.commandsHandledCount, 0)
+// write passes summary to the sinks; called without lock because sinks may be slow
+func (this *Implementation) write(summary IntervalSummary) {
+	for _, sink := range this.sinks {
+		err := sink.Write(summary)
+		if err != nil {
+			log.Println("WARNING: unable to write statistics to sink", err)
+		}
+	}
 }
 
-func statistics(list []time.Duration) (median time.Duration, avg time.Duration, min time.Duration, max time.Duration) {
-	sort.Slice(list, func(i, j int) bool {
-		return list[i] < list[j]
+func (this *Implementation) log() IntervalSummary {
+	this.eventMux.Lock()
+	defer this.eventMux.Unlock()
+
+	now := time.Now()
+	produceTimes := this.produceTimes(func(l *latency) *Histogram {
+		interval := NewHistogram()
+		l.rotateInto(interval)
+		return interval
 	})
-	count := len(list)
-	if len(list) > 0 {
-		min = list[0]
-		max = list[count-1]
+	summary := IntervalSummary{
+		Start:              this.intervalStart,
+		End:                now,
+		Emitted:            atomic.LoadUint64(&this.emittedCount),
+		Failed:             atomic.LoadUint64(&this.failedCount),
+		Missed:             atomic.LoadUint64(&this.missedCount),
+		Blocked:            atomic.LoadUint64(&this.blockedCount),
+		CommandsHandled:    atomic.LoadUint64(&this.commandsHandledCount),
+		ProcessesTriggered: atomic.LoadUint64(&this.processesTriggered),
+		MaxQueueDepth:      this.maxQueueDepth,
+		ProduceTime:        produceTimes.Summary(),
+		SendDelay:          this.sendDelays.rotate(),
+		CommandLatency:     this.commandLatencies.rotate(),
+		ProcessCompletion:  this.processCompletions.rotate(),
+		EventLatency:       map[string]LatencySummary{},
+		Deliveries:         this.deliveries.total().Map(),
+		Errors:             this.errors.copy(),
+		Devices:            this.rotateDevices(),
+		Phase:              this.phase.name,
+	}
+	summary.Produced = summary.ProduceTime.Count
+	summary.Throughput = throughput(summary.Produced, summary.End.Sub(summary.Start))
+	for _, service := range this.services() {
+		summary.EventLatency[service] = this.eventLatencies[service].rotate()
+	}
+	if len(this.intervals) < MaxIntervals {
+		this.intervals = append(this.intervals, summary)
+	} else {
+		copy(this.intervals, this.intervals[1:])
+		this.intervals[len(this.intervals)-1] = summary
 	}
 
-	if count > 2 {
-		if count%2 == 0 {
-			median = list[count/2]
-		} else {
-			median = (list[count/2] + list[(count/2)-1]) / 2
-		}
+	log.Println("LOG: produced events:", "\n\tcommands:", summary.CommandsHandled, "\n\temitted:", summary.Emitted, "\n\tmissed:", summary.Missed, "\n\tblocked:", summary.Blocked, "\n\tproduced:", summary.Produced, "\n\tfailed:", summary.Failed, "\n\tthroughput:", strconv.FormatFloat(summary.Throughput, 'f', 2, 64), "/s", "\n\tproduce-time:", summary.ProduceTime.String(), "\n\tsend-delay:", summary.SendDelay.String(), "\n\tqueue-depth:", this.queueDepth, "/", this.queueCapacity, "\n\tmax-queue-depth:", this.maxQueueDepth)
+
+	for _, service := range this.services() {
+		log.Println("LOG: end-to-end latency of", service, "\n\t", summary.EventLatency[service].String())
 	}
 
-	sum := time.Duration(0)
-	for _, element := range list {
-		sum += element
+	this.deliveries.log("interval")
+	this.errors.log("interval")
+	summary.Devices.Log("interval device breakdown")
+
+	if summary.ProcessesTriggered > 0 || summary.CommandLatency.Count > 0 {
+		log.Println("LOG: processes:", "\n\ttriggered:", summary.ProcessesTriggered, "\n\tcommand-latency:", summary.CommandLatency.String(), "\n\tcompletion-time:", summary.ProcessCompletion.String())
 	}
-	if len(list) > 0 {
-		avg = sum / time.Duration(len(list))
+
+	this.intervalStart = now
+	this.deliveries = deliveries{}
+	this.errors = errorCounts{}
+	atomic.StoreUint64(&this.emittedCount, 0)
+	atomic.StoreUint64(&this.missedCount, 0)
+	atomic.StoreUint64(&this.failedCount, 0)
+	atomic.StoreUint64(&this.blockedCount, 0)
+	this.maxQueueDepth = this.queueDepth
+	atomic.StoreUint64(&this.commandsHandledCount, 0)
+	atomic.StoreUint64(&this.processesTriggered, 0)
+	return summary
+}
+
+func (this *Implementation) services() (result []string) {
+	for service := range this.eventLatencies {
+		result = append(result, service)
 	}
-	return
+	sort.Strings(result)
+	return result
+}
+
+// logRun logs the summary of the whole run
+func (this *Implementation) logRun() {
+	this.eventMux.Lock()
+	defer this.eventMux.Unlock()
+	this.ended = time.Now()
+	log.Println("LOG: run summary:", "\n\tproduce-time:", this.produceTimes((*latency).totalHistogram).Summary().String(), "\n\tsend-delay:", this.sendDelays.total().String(), "\n\tcommand-latency:", this.commandLatencies.total().String(), "\n\tcompletion-time:", this.processCompletions.total().String())
+	for _, service := range this.services() {
+		log.Println("LOG: run end-to-end latency of", service, "\n\t", this.eventLatencies[service].total().String())
+	}
+	this.runDeliveries.log("run")
+	this.runErrors.log("run")
+	this.shardsMux.RLock()
+	defer this.shardsMux.RUnlock()
+	if this.devices != nil {
+		newBreakdown(this.devices.names, this.devices.total(), this.devices.topN).Log("run device breakdown")
+	}
+}
+
+func (this *Implementation) rotateDevices() *Breakdown {
+	this.shardsMux.RLock()
+	defer this.shardsMux.RUnlock()
+	return this.devices.rotate()
 }
@@ -0,0 +1,138 @@
+package statistics
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stat := New(ctx, 0, nil)
+	stat.TrackDevices([]string{"device_0"}, 0)
+	services := []string{"a", "b"}
+	const events = 1000
+	wg := sync.WaitGroup{}
+	for i, service := range services {
+		device := "device_" + strconv.Itoa(i)
+		service := service
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < events; j++ {
+				stat.EventEmitted(device, service)
+				stat.EventProduce(device, service, time.Millisecond)
+				stat.EventFailed(device, service)
+				stat.CommandsHandled(device, service)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 100; j++ {
+			stat.AddTrackedDevices([]string{"device_1", "other_" + strconv.Itoa(j)})
+			stat.Snapshot()
+		}
+	}()
+	wg.Wait()
+
+	snapshot := stat.Snapshot()
+	for _, service := range services {
+		totals := snapshot.Services[service]
+		if totals == nil || totals.Emitted != events || totals.Produced != events || totals.Failed != events || totals.CommandsHandled != events {
+			t.Errorf("unexpected totals of %v: %#v", service, totals)
+		}
+		if snapshot.ProduceTimes[service].Count() != events {
+			t.Errorf("expect %v produce times of %v, got %v", events, service, snapshot.ProduceTimes[service].Count())
+		}
+	}
+	run := stat.Run()
+	if run.Produced != 2*events || run.ProduceTime.Count != 2*events || run.ProduceTime.Max != time.Millisecond {
+		t.Errorf("unexpected run summary %#v", run)
+	}
+	if run.Devices == nil || run.Devices.Top[0].Name != "device_0" || run.Devices.Top[0].Produced != events {
+		t.Errorf("unexpected device breakdown %#v", run.Devices)
+	}
+	interval := stat.log()
+	if interval.Produced != 2*events || interval.Emitted != 2*events {
+		t.Errorf("unexpected interval summary %#v", interval)
+	}
+	if stat.log().Produced != 0 || stat.Run().Produced != 2*events {
+		t.Error("expect the produce times to move from the interval to the run")
+	}
+}
+
+type sinkFunc func(summary IntervalSummary) error
+
+func (this sinkFunc) Write(summary IntervalSummary) error {
+	return this(summary)
+}
+
+func TestFinalIntervalWithoutInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	written := []IntervalSummary{}
+	stat := New(ctx, 0, []Sink{sinkFunc(func(summary IntervalSummary) error {
+		written = append(written, summary)
+		return nil
+	})})
+	stat.EventEmitted("device", "service")
+	cancel()
+	<-stat.Done()
+	if len(written) != 1 || written[0].Emitted != 1 {
+		t.Errorf("expect the whole run as one interval, got %#v", written)
+	}
+}
+
+func TestMaxIntervals(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stat := New(ctx, time.Hour, nil)
+	for i := 0; i < MaxIntervals+10; i++ {
+		if i < 10 || i == MaxIntervals+9 {
+			stat.EventEmitted("device", "service")
+		}
+		stat.Flush()
+	}
+	intervals := stat.Intervals()
+	emitted := uint64(0)
+	for _, interval := range intervals {
+		emitted = emitted + interval.Emitted
+	}
+	if len(intervals) != MaxIntervals || emitted != 1 || intervals[len(intervals)-1].Emitted != 1 {
+		t.Errorf("expect the last %v intervals, got %v with %v emitted", MaxIntervals, len(intervals), emitted)
+	}
+}
+
+func TestSummarizePhase(t *testing.T) {
+	for _, interval := range []time.Duration{0, time.Hour} {
+		t.Run(interval.String(), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stat := New(ctx, interval, nil)
+			for i := 0; i < 100; i++ {
+				stat.EventProduce("device", "service", time.Second)
+				stat.EventLatency("service", time.Second)
+			}
+			stat.StartPhase(1, "ramp")
+			for i := 0; i < 10; i++ {
+				stat.EventProduce("device", "service", time.Millisecond)
+				stat.EventLatency("other", time.Millisecond)
+			}
+			phase := SummarizePhase([]*Implementation{stat})
+			if phase.Produced != 10 || phase.ProduceTime.Count != 10 || phase.ProduceTime.Max > 2*time.Millisecond {
+				t.Errorf("expect only the produce times of the phase, got %#v", phase.ProduceTime)
+			}
+			if phase.EventLatency["service"].Count != 0 || phase.EventLatency["other"].Count != 10 || phase.EndToEndLatency.P99 > 2*time.Millisecond {
+				t.Errorf("expect only the event latencies of the phase, got %#v", phase.EventLatency)
+			}
+			run := stat.Run()
+			if run.ProduceTime.Count != 110 || run.ProduceTime.Max != time.Second || run.ProduceTime.Min != time.Millisecond {
+				t.Errorf("expect the produce times of the whole run, got %#v", run.ProduceTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package statistics
+
+import (
+	"time"
+)
+
+// IntervalSummary is the result of one statistics interval
+type IntervalSummary struct {
+	Start              time.Time                 `json:"start"`
+	End                time.Time                 `json:"end"`
+	Emitted            uint64                    `json:"emitted"`
+	Produced           uint64                    `json:"produced"`
+	Failed             uint64                    `json:"failed"`
+	Missed             uint64                    `json:"missed"`
+	Blocked            uint64                    `json:"blocked"`
+	CommandsHandled    uint64                    `json:"commands_handled"`
+	ProcessesTriggered uint64                    `json:"processes_triggered"`
+	MaxQueueDepth      int                       `json:"max_queue_depth"`
+	Throughput         float64                   `json:"throughput"` //produced events per second
+	ProduceTime        LatencySummary            `json:"produce_time"`
+	SendDelay          LatencySummary            `json:"send_delay"`
+	CommandLatency     LatencySummary            `json:"command_latency"`
+	ProcessCompletion  LatencySummary            `json:"process_completion"`
+	EventLatency       map[string]LatencySummary `json:"event_latency"`
+	Deliveries         map[string]uint64         `json:"deliveries"`
+	Errors             map[string]uint64         `json:"errors"` //by category or category:endpoint
+	Devices            *Breakdown                `json:"devices,omitempty"`
+	Phase              string                    `json:"phase,omitempty"` //scenario phase during the interval
+}
+
+// RunSummary is the result of the whole run of one or more statistics
+type RunSummary struct {
+	Start              time.Time                 `json:"start"`
+	End                time.Time                 `json:"end"`
+	Emitted            uint64                    `json:"emitted"`
+	Produced           uint64                    `json:"produced"`
+	Failed             uint64                    `json:"failed"`
+	Missed             uint64                    `json:"missed"`
+	Blocked            uint64                    `json:"blocked"`
+	CommandsHandled    uint64                    `json:"commands_handled"`
+	ProcessesTriggered uint64                    `json:"processes_triggered"`
+	Throughput         float64                   `json:"throughput"` //produced events per second
+	ProduceTime        LatencySummary            `json:"produce_time"`
+	SendDelay          LatencySummary            `json:"send_delay"`
+	CommandLatency     LatencySummary            `json:"command_latency"`
+	ProcessCompletion  LatencySummary            `json:"process_completion"`
+	EventLatency       map[string]LatencySummary `json:"event_latency"`
+	EndToEndLatency    LatencySummary            `json:"end_to_end_latency"` //event latency of all services
+	Deliveries         map[string]uint64         `json:"deliveries"`
+	Errors             map[string]uint64         `json:"errors"`               //by category or category:endpoint
+	Devices            *Breakdown                `json:"devices,omitempty"`    //if device statistics are enabled
+	Hubs               *Breakdown                `json:"hubs,omitempty"`       //see HubBreakdown
+	Histograms         *RunHistograms            `json:"histograms,omitempty"` //only sent by distributed workers (see Combine)
+}
+
+// RunHistograms are the latency histograms of a run; distributed workers send them with their report, so that the coordinator merges the latencies exactly
+type RunHistograms struct {
+	ProduceTime       *Histogram            `json:"produce_time"`
+	SendDelay         *Histogram            `json:"send_delay"`
+	CommandLatency    *Histogram            `json:"command_latency"`
+	ProcessCompletion *Histogram            `json:"process_completion"`
+	EventLatency      map[string]*Histogram `json:"event_latency"`
+}
+
+func NewRunHistograms() *RunHistograms {
+	return &RunHistograms{
+		ProduceTime:       NewHistogram(),
+		SendDelay:         NewHistogram(),
+		CommandLatency:    NewHistogram(),
+		ProcessCompletion: NewHistogram(),
+		EventLatency:      map[string]*Histogram{},
+	}
+}
+
+func (this *RunHistograms) merge(other *RunHistograms) {
+	merge := func(h *Histogram, other *Histogram) {
+		if other != nil {
+			h.Merge(other)
+		}
+	}
+	merge(this.ProduceTime, other.ProduceTime)
+	merge(this.SendDelay, other.SendDelay)
+	merge(this.CommandLatency, other.CommandLatency)
+	merge(this.ProcessCompletion, other.ProcessCompletion)
+	for service, latency := range other.EventLatency {
+		h, ok := this.EventLatency[service]
+		if !ok {
+			h = NewHistogram()
+			this.EventLatency[service] = h
+		}
+		merge(h, latency)
+	}
+}
+
+// since returns the values recorded after the earlier snapshot base (nil: all values)
+func (this *RunHistograms) since(base *RunHistograms) *RunHistograms {
+	if base == nil {
+		return this
+	}
+	result := &RunHistograms{
+		ProduceTime:       this.ProduceTime.since(base.ProduceTime),
+		SendDelay:         this.SendDelay.since(base.SendDelay),
+		CommandLatency:    this.CommandLatency.since(base.CommandLatency),
+		ProcessCompletion: this.ProcessCompletion.since(base.ProcessCompletion),
+		EventLatency:      map[string]*Histogram{},
+	}
+	for service, h := range this.EventLatency {
+		result.EventLatency[service] = h.since(base.EventLatency[service])
+	}
+	return result
+}
+
+// setLatencies sets the latency summaries of result from the histograms
+func (this *RunHistograms) setLatencies(result *RunSummary) {
+	endToEnd := NewHistogram()
+	result.ProduceTime = this.ProduceTime.Summary()
+	result.SendDelay = this.SendDelay.Summary()
+	result.CommandLatency = this.CommandLatency.Summary()
+	result.ProcessCompletion = this.ProcessCompletion.Summary()
+	result.EventLatency = map[string]LatencySummary{}
+	for service, h := range this.EventLatency {
+		result.EventLatency[service] = h.Summary()
+		endToEnd.Merge(h)
+	}
+	result.EndToEndLatency = endToEnd.Summary()
+}
+
+// Intervals returns the summaries of the last MaxIntervals finished intervals
+func (this *Implementation) Intervals() []IntervalSummary {
+	this.eventMux.Lock()
+	defer this.eventMux.Unlock()
+	return append([]IntervalSummary{}, this.intervals...)
+}
+
+func (this *Implementation) Run() RunSummary {
+	return Summarize([]*Implementation{this})
+}
+
+// Summarize merges the whole run statistics of list; runs which are not ended count until now
+func Summarize(list []*Implementation) (result RunSummary) {
+	result, _ = summarize(list, false)
+	return result
+}
+
+// SummarizeHistograms merges the latency histograms of the whole run of list (see RunHistograms)
+func SummarizeHistograms(list []*Implementation) *RunHistograms {
+	_, histograms := summarize(list, false)
+	return histograms
+}
+
+// summarize merges the statistics of list since their start or, with phase, since their last StartPhase
+func summarize(list []*Implementation, phase bool) (result RunSummary, histograms *RunHistograms) {
+	histograms = NewRunHistograms()
+	deliveries := deliveryCounts{}
+	errors := errorCounts{}
+	deviceNames := []string{}
+	devices := []deviceCounts{}
+	topN := 0
+	for _, stat := range list {
+		stat.eventMux.Lock()
+		start := stat.started
+		counts := stat.counters()
+		statHistograms := stat.histograms()
+		if phase {
+			start = stat.phase.started
+			counts = counts.since(stat.phase.counters)
+			statHistograms = statHistograms.since(stat.phase.histograms)
+		}
+		end := stat.ended
+		if end.IsZero() {
+			end = time.Now()
+		}
+		if result.Start.IsZero() || start.Before(result.Start) {
+			result.Start = start
+		}
+		if end.After(result.End) {
+			result.End = end
+		}
+		result.Emitted = result.Emitted + counts.Emitted
+		result.Produced = result.Produced + counts.Produced
+		result.Failed = result.Failed + counts.Failed
+		result.CommandsHandled = result.CommandsHandled + counts.CommandsHandled
+		result.Missed = result.Missed + counts.missed
+		result.Blocked = result.Blocked + counts.blocked
+		result.ProcessesTriggered = result.ProcessesTriggered + counts.processesTriggered
+		histograms.merge(statHistograms)
+		stat.shardsMux.RLock()
+		if stat.devices != nil && !phase {
+			deviceNames = append(deviceNames, stat.devices.names...)
+			devices = append(devices, stat.devices.total()...)
+			topN = stat.devices.topN
+		}
+		stat.shardsMux.RUnlock()
+		for key, count := range counts.errors {
+			errors[key] = errors[key] + count
+		}
+		for i := range counts.deliveries {
+			deliveries[i] = deliveries[i] + counts.deliveries[i]
+		}
+		stat.eventMux.Unlock()
+	}
+	result.Throughput = throughput(result.Produced, result.End.Sub(result.Start))
+	histograms.setLatencies(&result)
+	result.Deliveries = deliveries.Map()
+	result.Errors = errors.copy()
+	if len(devices) > 0 {
+		result.Devices = newBreakdown(deviceNames, devices, topN)
+	}
+
+	return result, histograms
+}
+
+func throughput(count uint64, duration time.Duration) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	return float64(count) / duration.Seconds()
+}
+
+// Combine merges run summaries (e.g. reports of distributed workers): counts are summed and,
+// if all runs contain their histograms (see RunHistograms), the latencies are merged exactly;
+// otherwise min/max/avg are exact and percentiles are the maximum of the runs and therefore an upper bound
+func Combine(runs []RunSummary) (result RunSummary) {
+	eventLatencies := map[string][]LatencySummary{}
+	produceTimes, sendDelays, commandLatencies, processCompletions, endToEnd := []LatencySummary{}, []LatencySummary{}, []LatencySummary{}, []LatencySummary{}, []LatencySummary{}
+	histograms := NewRunHistograms()
+	exact := true
+	result.Deliveries = map[string]uint64{}
+	result.Errors = map[string]uint64{}
+	for _, run := range runs {
+		if result.Start.IsZero() || run.Start.Before(result.Start) {
+			result.Start = run.Start
+		}
+		if run.End.After(result.End) {
+			result.End = run.End
+		}
+		result.Emitted = result.Emitted + run.Emitted
+		result.Produced = result.Produced + run.Produced
+		result.Failed = result.Failed + run.Failed
+		result.Missed = result.Missed + run.Missed
+		result.Blocked = result.Blocked + run.Blocked
+		result.CommandsHandled = result.CommandsHandled + run.CommandsHandled
+		result.ProcessesTriggered = result.ProcessesTriggered + run.ProcessesTriggered
+		produceTimes = append(produceTimes, run.ProduceTime)
+		sendDelays = append(sendDelays, run.SendDelay)
+		commandLatencies = append(commandLatencies, run.CommandLatency)
+		processCompletions = append(processCompletions, run.ProcessCompletion)
+		endToEnd = append(endToEnd, run.EndToEndLatency)
+		for service, latency := range run.EventLatency {
+			eventLatencies[service] = append(eventLatencies[service], latency)
+		}
+		if run.Histograms == nil {
+			exact = false
+		} else {
+			histograms.merge(run.Histograms)
+		}
+		for key, count := range run.Deliveries {
+			result.Deliveries[key] = result.Deliveries[key] + count
+		}
+		for key, count := range run.Errors {
+			result.Errors[key] = result.Errors[key] + count
+		}
+	}
+	result.Throughput = throughput(result.Produced, result.End.Sub(result.Start))
+	if exact && len(runs) > 0 {
+		histograms.setLatencies(&result)
+		return result
+	}
+	result.ProduceTime = CombineLatency(produceTimes)
+	result.SendDelay = CombineLatency(sendDelays)
+	result.CommandLatency = CombineLatency(commandLatencies)
+	result.ProcessCompletion = CombineLatency(processCompletions)
+	result.EndToEndLatency = CombineLatency(endToEnd)
+	result.EventLatency = map[string]LatencySummary{}
+	for service, list := range eventLatencies {
+		result.EventLatency[service] = CombineLatency(list)
+	}
+	return result
+}
+
+// CombineLatency merges latency summaries without histograms; percentiles are the maximum of list (upper bound)
+func CombineLatency(list []LatencySummary) (result LatencySummary) {
+	sum := 0.0
+	for _, latency := range list {
+		if latency.Count == 0 {
+			continue
+		}
+		if result.Count == 0 || latency.Min < result.Min {
+			result.Min = latency.Min
+		}
+		result.Count = result.Count + latency.Count
+		sum = sum + float64(latency.Avg)*float64(latency.Count)
+		result.P50 = maxDuration(result.P50, latency.P50)
+		result.P90 = maxDuration(result.P90, latency.P90)
+		result.P95 = maxDuration(result.P95, latency.P95)
+		result.P99 = maxDuration(result.P99, latency.P99)
+		result.P999 = maxDuration(result.P999, latency.P999)
+		result.Max = maxDuration(result.Max, latency.Max)
+	}
+	if result.Count > 0 {
+		result.Avg = time.Duration(sum / float64(result.Count))
+	}
+	return result
+}
+
+func maxDuration(a time.Duration, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,47 @@
+package statistics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCombine(t *testing.T) {
+	run := func(latency time.Duration, count int, withHistograms bool) RunSummary {
+		histograms := NewRunHistograms()
+		histograms.EventLatency["service"] = NewHistogram()
+		for i := 0; i < count; i++ {
+			histograms.ProduceTime.Record(latency)
+			histograms.EventLatency["service"].Record(latency)
+		}
+		result := RunSummary{Produced: uint64(count)}
+		histograms.setLatencies(&result)
+		if withHistograms {
+			result.Histograms = histograms
+		}
+		return result
+	}
+	tests := []struct {
+		name     string
+		runs     []RunSummary
+		expected time.Duration //p50 of the produce times and event latencies
+	}{
+		{name: "exact", runs: []RunSummary{run(time.Millisecond, 60, true), run(time.Second, 40, true)}, expected: time.Millisecond},
+		{name: "upper bound", runs: []RunSummary{run(time.Millisecond, 60, true), run(time.Second, 40, false)}, expected: time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := Combine(test.runs)
+			if result.Produced != 100 || result.ProduceTime.Count != 100 || result.EndToEndLatency.Count != 100 {
+				t.Fatalf("unexpected counts %#v", result)
+			}
+			for name, latency := range map[string]LatencySummary{"produce time": result.ProduceTime, "event latency": result.EventLatency["service"], "end to end": result.EndToEndLatency} {
+				if latency.P50 < test.expected || latency.P50 > test.expected+test.expected/100 {
+					t.Errorf("expect %v p50 of %v, got %v", test.expected, name, latency.P50)
+				}
+				if latency.Max != time.Second || latency.Min != time.Millisecond {
+					t.Errorf("expect exact min and max of %v, got %v", name, latency.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package tracking
+
+import (
+	"sync"
+	"time"
+)
+
+// Commands correlates process triggers with the commands received by the simulated devices.
+// the platform assigns the correlation id of a command when it sends the command, so a process start can not know it;
+// the first command of a correlation id ends the oldest open trigger of the device and repeated deliveries of it are ignored.
+// commands without correlation id (mqtt connector) always end the oldest open trigger.
+type Commands struct {
+	timeout   time.Duration
+	mux       sync.Mutex
+	triggered map[string][]time.Time
+	received  map[string]time.Time
+	order     []string
+}
+
+func NewCommands(timeout time.Duration) *Commands {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Commands{timeout: timeout, triggered: map[string][]time.Time{}, received: map[string]time.Time{}}
+}
+
+// Triggered records a process start which is expected to send a command to device
+func (this *Commands) Triggered(device string, t time.Time) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.triggered[device] = append(this.removeExpired(device, t), t)
+}
+
+// Received returns the time since the oldest open trigger for device;
+// ok is false if no trigger is open or if the command with correlationId was already received
+func (this *Commands) Received(device string, correlationId string, t time.Time) (latency time.Duration, ok bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if correlationId != "" {
+		this.removeExpiredCorrelations(t)
+		if _, known := this.received[correlationId]; known {
+			return 0, false
+		}
+		this.received[correlationId] = t
+		this.order = append(this.order, correlationId)
+	}
+	open := this.removeExpired(device, t)
+	if len(open) == 0 {
+		delete(this.triggered, device)
+		return 0, false
+	}
+	latency = t.Sub(open[0])
+	if len(open) == 1 {
+		delete(this.triggered, device)
+	} else {
+		this.triggered[device] = open[1:]
+	}
+	return latency, true
+}
+
+func (this *Commands) removeExpired(device string, now time.Time) []time.Time {
+	open := this.triggered[device]
+	for len(open) > 0 && now.Sub(open[0]) > this.timeout {
+		open = open[1:]
+	}
+	return open
+}
+
+func (this *Commands) removeExpiredCorrelations(now time.Time) {
+	for len(this.order) > 0 && now.Sub(this.received[this.order[0]]) > this.timeout {
+		delete(this.received, this.order[0])
+		this.order = this.order[1:]
+	}
+}
@@ -0,0 +1,98 @@
+package tracking
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCommands(t *testing.T) {
+	start := time.Now()
+	type receipt struct {
+		correlationId string
+		after         time.Duration
+		latency       time.Duration
+		ok            bool
+	}
+	tests := []struct {
+		name     string
+		triggers []time.Duration
+		receipts []receipt
+	}{
+		{
+			name:     "in order",
+			triggers: []time.Duration{0, time.Second},
+			receipts: []receipt{
+				{correlationId: "a", after: 2 * time.Second, latency: 2 * time.Second, ok: true},
+				{correlationId: "b", after: 3 * time.Second, latency: 2 * time.Second, ok: true},
+			},
+		},
+		{
+			name:     "repeated delivery",
+			triggers: []time.Duration{0, time.Second},
+			receipts: []receipt{
+				{correlationId: "a", after: 2 * time.Second, latency: 2 * time.Second, ok: true},
+				{correlationId: "a", after: 2 * time.Second},
+				{correlationId: "b", after: 3 * time.Second, latency: 2 * time.Second, ok: true},
+			},
+		},
+		{
+			name:     "without correlation id",
+			triggers: []time.Duration{0, time.Second},
+			receipts: []receipt{
+				{after: 2 * time.Second, latency: 2 * time.Second, ok: true},
+				{after: 2 * time.Second, latency: time.Second, ok: true},
+				{after: 2 * time.Second},
+			},
+		},
+		{
+			name:     "not triggered",
+			receipts: []receipt{{correlationId: "a", after: time.Second}},
+		},
+		{
+			name:     "expired trigger",
+			triggers: []time.Duration{0, 2 * time.Minute},
+			receipts: []receipt{{correlationId: "a", after: 2*time.Minute + time.Second, latency: time.Second, ok: true}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			commands := NewCommands(time.Minute)
+			for _, trigger := range test.triggers {
+				commands.Triggered("device", start.Add(trigger))
+			}
+			for i, r := range test.receipts {
+				latency, ok := commands.Received("device", r.correlationId, start.Add(r.after))
+				if ok != r.ok || latency != r.latency {
+					t.Errorf("receipt %v: expect %v %v, got %v %v", i, r.latency, r.ok, latency, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestCommandsDevices(t *testing.T) {
+	start := time.Now()
+	commands := NewCommands(time.Minute)
+	commands.Triggered("a", start)
+	commands.Triggered("b", start.Add(time.Second))
+	latency, ok := commands.Received("b", "x", start.Add(2*time.Second))
+	if !ok || latency != time.Second {
+		t.Error("expect the trigger of device b, got", latency, ok)
+	}
+	latency, ok = commands.Received("a", "y", start.Add(2*time.Second))
+	if !ok || latency != 2*time.Second {
+		t.Error("expect the trigger of device a, got", latency, ok)
+	}
+}
+
+func TestCommandsExpiredCorrelationId(t *testing.T) {
+	start := time.Now()
+	commands := NewCommands(time.Minute)
+	commands.Triggered("device", start)
+	commands.Received("device", "a", start.Add(time.Second))
+	commands.Triggered("device", start.Add(2*time.Minute))
+	latency, ok := commands.Received("device", "a", start.Add(2*time.Minute+time.Second))
+	if !ok || latency != time.Second {
+		t.Error("expect correlation ids to be forgotten after the timeout, got", latency, ok)
+	}
+}
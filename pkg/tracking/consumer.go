@@ -0,0 +1,65 @@
+package tracking
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Consumer reads the downstream event stream of the platform
+type Consumer interface {
+	Consume(ctx context.Context, handler func(msg []byte, received time.Time)) error
+}
+
+type Config struct {
+	Type      string   `json:"type"` //kafka; empty --> no consumer
+	KafkaUrl  string   `json:"kafka_url"`
+	Topics    []string `json:"topics"`
+	GroupId   string   `json:"group_id"`   //defaults to senergy-load-test_<run id>
+	TracePath string   `json:"trace_path"` //dot separated path to the trace string in the consumed json message, e.g. value.root.trace
+	Timeout   string   `json:"timeout"`    //time until a published event is no longer expected downstream; positive, defaults to 5m
+}
+
+// GetTimeout returns the parsed timeout or DefaultTimeout if it is empty
+func (this Config) GetTimeout() (time.Duration, error) {
+	if this.Timeout == "" {
+		return DefaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(this.Timeout)
+	if err != nil {
+		return timeout, err
+	}
+	if timeout <= 0 {
+		return timeout, errors.New("expect positive latency_consumer timeout")
+	}
+	return timeout, nil
+}
+
+func NewConsumer(config Config, runId string) (Consumer, error) {
+	switch config.Type {
+	case "kafka":
+		return NewKafkaConsumer(config, runId)
+	default:
+		return nil, errors.New("unknown consumer type: " + config.Type)
+	}
+}
+
+// ChanConsumer passes messages written to the channel to the handler; usable as fake consumer in tests
+type ChanConsumer chan []byte
+
+func (this ChanConsumer) Consume(ctx context.Context, handler func(msg []byte, received time.Time)) error {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-this:
+				if !ok {
+					return
+				}
+				handler(msg, time.Now())
+			}
+		}
+	}()
+	return nil
+}
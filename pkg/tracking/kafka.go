@@ -0,0 +1,51 @@
+package tracking
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"strings"
+	"time"
+)
+
+type KafkaConsumer struct {
+	config kafka.ReaderConfig
+}
+
+func NewKafkaConsumer(config Config, runId string) (*KafkaConsumer, error) {
+	if config.KafkaUrl == "" || len(config.Topics) == 0 {
+		return nil, errors.New("kafka consumer expects kafka_url and topics")
+	}
+	groupId := config.GroupId
+	if groupId == "" {
+		groupId = "senergy-load-test_" + runId
+	}
+	return &KafkaConsumer{config: kafka.ReaderConfig{
+		Brokers:     strings.Split(config.KafkaUrl, ","),
+		GroupID:     groupId,
+		GroupTopics: config.Topics,
+		StartOffset: kafka.LastOffset,
+		MaxWait:     1 * time.Second,
+	}}, nil
+}
+
+func (this *KafkaConsumer) Consume(ctx context.Context, handler func(msg []byte, received time.Time)) error {
+	reader := kafka.NewReader(this.config)
+	go func() {
+		defer reader.Close()
+		for {
+			m, err := reader.ReadMessage(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Println("ERROR: unable to read downstream kafka message", err)
+				time.Sleep(time.Second)
+				continue
+			}
+			handler(m.Value, time.Now())
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,280 @@
+package tracking
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultTimeout = 5 * time.Minute
+
+// MinExpireInterval limits how often published events are checked against the timeout
+const MinExpireInterval = 100 * time.Millisecond
+
+// ExpiredRetention defines how long (in multiples of the timeout) lost events are remembered to detect late arrivals
+const ExpiredRetention = 10
+
+// Tracker matches published events with events consumed downstream by their trace (<run-id>:<stream>:<seq>),
+// which has to be embedded into the event payload (see payload template .Trace).
+// the latency and the delivery outcome (see statistics.Delivery) of each event is reported to the statistics of its stream.
+type Tracker struct {
+	runId     string
+	tracePath []string
+	timeout   time.Duration
+	consume   bool
+	mux       sync.Mutex
+	streams   []*Stream
+	pending   map[key]time.Time
+	expired   map[key]time.Time
+	settled   chan struct{} //signaled when the last pending event is received, unpublished or expired (see Wait)
+}
+
+// Stream is the sequence of events of one service of one device
+type Stream struct {
+	Device  string
+	Service string
+	Stat    statistics.Interface
+	highest uint64 //highest received sequence number
+	removed bool   //the device was removed by scaling (see Forget)
+}
+
+type key struct {
+	stream int
+	seq    uint64
+}
+
+func New(runId string, config Config) (result *Tracker, err error) {
+	result = &Tracker{
+		runId:   runId,
+		timeout: DefaultTimeout,
+		consume: config.Type != "",
+		pending: map[key]time.Time{},
+		expired: map[key]time.Time{},
+		settled: make(chan struct{}, 1),
+	}
+	if config.TracePath != "" {
+		result.tracePath = strings.Split(config.TracePath, ".")
+	}
+	result.timeout, err = config.GetTimeout()
+	return result, err
+}
+
+// Start handles the messages of the consumer and removes published events which are not seen downstream within the timeout;
+// ctx should end after the last Wait, so that the events published on shutdown are still received
+func (this *Tracker) Start(ctx context.Context, consumer Consumer) error {
+	err := consumer.Consume(ctx, this.Handle)
+	if err != nil {
+		return err
+	}
+	go func() {
+		t := time.NewTicker(this.expireInterval())
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				this.expire(time.Now())
+			}
+		}
+	}()
+	return nil
+}
+
+// expireInterval is a tenth of the timeout, at least MinExpireInterval
+func (this *Tracker) expireInterval() time.Duration {
+	if this.timeout/10 < MinExpireInterval {
+		return MinExpireInterval
+	}
+	return this.timeout / 10
+}
+
+func (this *Tracker) RunId() string {
+	return this.runId
+}
+
+// Register returns the stream id used in traces
+func (this *Tracker) Register(device string, service string, stat statistics.Interface) int {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.streams = append(this.streams, &Stream{Device: device, Service: service, Stat: stat})
+	return len(this.streams) - 1
+}
+
+// Forget stops the delivery tracking of the streams of a removed device: its pending events still report their latency if they arrive,
+// but they are not counted as lost after the timeout and later messages of the streams are ignored
+func (this *Tracker) Forget(device string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, stream := range this.streams {
+		if stream.Device == device {
+			stream.removed = true
+		}
+	}
+}
+
+func (this *Tracker) Trace(stream int, seq uint64) string {
+	return this.runId + ":" + strconv.Itoa(stream) + ":" + strconv.FormatUint(seq, 10)
+}
+
+// Published registers an event which is expected downstream; it has to be called before the event is sent,
+// because the event may be consumed before the publish returns
+func (this *Tracker) Published(stream int, seq uint64, t time.Time) {
+	if !this.consume {
+		return
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.pending[key{stream: stream, seq: seq}] = t
+}
+
+// Unpublished removes an event registered with Published whose send failed
+func (this *Tracker) Unpublished(stream int, seq uint64) {
+	if !this.consume {
+		return
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	delete(this.pending, key{stream: stream, seq: seq})
+	this.settle()
+}
+
+// Wait waits until all published events are received downstream or expired, at most the timeout and two expire intervals
+// (the first expire after the timeout may just miss it), and returns the number of events which are still pending
+func (this *Tracker) Wait() int {
+	deadline := time.NewTimer(this.timeout + 2*this.expireInterval())
+	defer deadline.Stop()
+	for {
+		this.mux.Lock()
+		pending := len(this.pending)
+		this.mux.Unlock()
+		if pending == 0 {
+			return 0
+		}
+		select {
+		case <-this.settled:
+		case <-deadline.C:
+			return pending
+		}
+	}
+}
+
+// settle signals Wait if no events are pending; the caller has to hold mux
+func (this *Tracker) settle() {
+	if len(this.pending) > 0 {
+		return
+	}
+	select {
+	case this.settled <- struct{}{}:
+	default:
+	}
+}
+
+// Handle measures the latency of a downstream message containing a trace of this run
+func (this *Tracker) Handle(msg []byte, received time.Time) {
+	trace, ok := this.extractTrace(msg)
+	if !ok {
+		return
+	}
+	k, ok := this.parseTrace(trace)
+	if !ok {
+		return
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if k.stream >= len(this.streams) {
+		return
+	}
+	stream := this.streams[k.stream]
+	published, ok := this.pending[k]
+	switch {
+	case ok:
+		delete(this.pending, k)
+		this.settle()
+		stream.Stat.EventLatency(stream.Service, received.Sub(published))
+		if k.seq < stream.highest {
+			stream.Stat.EventDelivery(stream.Device, statistics.Reordered)
+		} else {
+			for seq := stream.highest + 1; seq < k.seq; seq++ {
+				if _, missing := this.pending[key{stream: k.stream, seq: seq}]; missing {
+					stream.Stat.EventDelivery(stream.Device, statistics.Gap)
+				}
+			}
+			stream.highest = k.seq
+			stream.Stat.EventDelivery(stream.Device, statistics.Received)
+		}
+	case stream.removed:
+		delete(this.expired, k)
+	case !this.expired[k].IsZero():
+		delete(this.expired, k)
+		stream.Stat.EventDelivery(stream.Device, statistics.Late)
+	default:
+		stream.Stat.EventDelivery(stream.Device, statistics.Duplicate)
+	}
+}
+
+func (this *Tracker) expire(now time.Time) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	expired := 0
+	for k, published := range this.pending {
+		if now.Sub(published) > this.timeout {
+			delete(this.pending, k)
+			stream := this.streams[k.stream]
+			if stream.removed {
+				continue
+			}
+			this.expired[k] = published
+			expired++
+			stream.Stat.EventDelivery(stream.Device, statistics.Lost)
+		}
+	}
+	//late arrivals are only detected within ExpiredRetention; afterwards they count as duplicates
+	for k, published := range this.expired {
+		if now.Sub(published) > ExpiredRetention*this.timeout {
+			delete(this.expired, k)
+		}
+	}
+	this.settle()
+	if expired > 0 {
+		log.Println("WARNING:", expired, "published events not seen downstream within", this.timeout.String())
+	}
+}
+
+func (this *Tracker) parseTrace(trace string) (result key, ok bool) {
+	parts := strings.Split(trace, ":")
+	if len(parts) != 3 || parts[0] != this.runId {
+		return result, false
+	}
+	stream, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return result, false
+	}
+	seq, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return result, false
+	}
+	return key{stream: stream, seq: seq}, true
+}
+
+func (this *Tracker) extractTrace(msg []byte) (trace string, ok bool) {
+	var value interface{}
+	err := json.Unmarshal(msg, &value)
+	if err != nil {
+		return "", false
+	}
+	for _, field := range this.tracePath {
+		m, isMap := value.(map[string]interface{})
+		if !isMap {
+			return "", false
+		}
+		value = m[field]
+	}
+	trace, ok = value.(string)
+	return trace, ok
+}
@@ -0,0 +1,272 @@
+package tracking
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/senergy-load-test/pkg/statistics"
+	"sync"
+	"testing"
+	"time"
+)
+
+// deliveryStat records the deliveries and latencies reported by the tracker
+type deliveryStat struct {
+	statistics.Void
+	mux        sync.Mutex
+	deliveries map[statistics.Delivery]int
+	latencies  []time.Duration
+}
+
+func (this *deliveryStat) EventDelivery(device string, delivery statistics.Delivery) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.deliveries[delivery]++
+}
+
+func (this *deliveryStat) EventLatency(service string, duration time.Duration) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.latencies = append(this.latencies, duration)
+}
+
+func (this *deliveryStat) count(delivery statistics.Delivery) int {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.deliveries[delivery]
+}
+
+func newTracker(t *testing.T, timeout string) (*Tracker, *deliveryStat, int) {
+	t.Helper()
+	tracker, err := New("run", Config{Type: "test", TracePath: "trace", Timeout: timeout})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat := &deliveryStat{deliveries: map[statistics.Delivery]int{}}
+	stream := tracker.Register("device", "service", stat)
+	return tracker, stat, stream
+}
+
+func message(tracker *Tracker, stream int, seq uint64) []byte {
+	return []byte(`{"trace": "` + tracker.Trace(stream, seq) + `"}`)
+}
+
+func TestDeliveries(t *testing.T) {
+	published := time.Now()
+	tests := []struct {
+		name      string
+		publish   []uint64
+		receive   []uint64
+		expect    map[statistics.Delivery]int
+		latencies int
+	}{
+		{
+			name:      "in order",
+			publish:   []uint64{1, 2, 3},
+			receive:   []uint64{1, 2, 3},
+			expect:    map[statistics.Delivery]int{statistics.Received: 3},
+			latencies: 3,
+		},
+		{
+			name:      "duplicate",
+			publish:   []uint64{1, 2},
+			receive:   []uint64{1, 1, 2},
+			expect:    map[statistics.Delivery]int{statistics.Received: 2, statistics.Duplicate: 1},
+			latencies: 2,
+		},
+		{
+			name:      "not published",
+			publish:   []uint64{},
+			receive:   []uint64{1},
+			expect:    map[statistics.Delivery]int{statistics.Duplicate: 1},
+			latencies: 0,
+		},
+		{
+			name:      "reordered",
+			publish:   []uint64{1, 2, 3},
+			receive:   []uint64{1, 3, 2},
+			expect:    map[statistics.Delivery]int{statistics.Received: 2, statistics.Gap: 1, statistics.Reordered: 1},
+			latencies: 3,
+		},
+		{
+			name:      "gap",
+			publish:   []uint64{1, 2, 3, 4},
+			receive:   []uint64{1, 4},
+			expect:    map[statistics.Delivery]int{statistics.Received: 2, statistics.Gap: 2},
+			latencies: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracker, stat, stream := newTracker(t, "1m")
+			for _, seq := range test.publish {
+				tracker.Published(stream, seq, published)
+			}
+			for _, seq := range test.receive {
+				tracker.Handle(message(tracker, stream, seq), published.Add(time.Second))
+			}
+			for delivery := statistics.Received; delivery <= statistics.Gap; delivery++ {
+				if stat.count(delivery) != test.expect[delivery] {
+					t.Errorf("expect %v %v, got %v", test.expect[delivery], delivery.String(), stat.count(delivery))
+				}
+			}
+			if len(stat.latencies) != test.latencies {
+				t.Errorf("expect %v latencies, got %v", test.latencies, len(stat.latencies))
+			}
+			for _, latency := range stat.latencies {
+				if latency != time.Second {
+					t.Error("expect latency of 1s, got", latency)
+				}
+			}
+		})
+	}
+}
+
+func TestLostAndLate(t *testing.T) {
+	tracker, stat, stream := newTracker(t, "1m")
+	published := time.Now()
+	tracker.Published(stream, 1, published)
+	tracker.Published(stream, 2, published)
+	tracker.Handle(message(tracker, stream, 1), published.Add(time.Second))
+	tracker.expire(published.Add(2 * time.Minute))
+	if stat.count(statistics.Lost) != 1 {
+		t.Error("expect 1 lost event, got", stat.count(statistics.Lost))
+	}
+	tracker.Handle(message(tracker, stream, 2), published.Add(3*time.Minute))
+	if stat.count(statistics.Late) != 1 {
+		t.Error("expect 1 late event, got", stat.count(statistics.Late))
+	}
+	tracker.Handle(message(tracker, stream, 2), published.Add(3*time.Minute))
+	if stat.count(statistics.Duplicate) != 1 {
+		t.Error("expect a second arrival of a late event to be a duplicate, got", stat.count(statistics.Duplicate))
+	}
+}
+
+func TestUnpublished(t *testing.T) {
+	tracker, stat, stream := newTracker(t, "1m")
+	published := time.Now()
+	tracker.Published(stream, 1, published)
+	tracker.Published(stream, 2, published)
+	tracker.Unpublished(stream, 2)
+	tracker.expire(published.Add(2 * time.Minute))
+	if stat.count(statistics.Lost) != 1 {
+		t.Error("expect only the published event to be lost, got", stat.count(statistics.Lost))
+	}
+}
+
+func TestForget(t *testing.T) {
+	tracker, stat, stream := newTracker(t, "1m")
+	published := time.Now()
+	tracker.Published(stream, 1, published)
+	tracker.Published(stream, 2, published)
+	tracker.Published(stream, 3, published)
+	tracker.Forget("device")
+	tracker.Handle(message(tracker, stream, 1), published.Add(time.Second))
+	tracker.expire(published.Add(2 * time.Minute))
+	tracker.Handle(message(tracker, stream, 2), published.Add(3*time.Minute))
+	if stat.count(statistics.Received) != 1 || len(stat.latencies) != 1 {
+		t.Errorf("expect the in-flight event of a forgotten stream to be received, got %v", stat.deliveries)
+	}
+	for _, delivery := range []statistics.Delivery{statistics.Lost, statistics.Late, statistics.Duplicate} {
+		if stat.count(delivery) != 0 {
+			t.Errorf("expect no %v for a forgotten stream, got %v", delivery.String(), stat.count(delivery))
+		}
+	}
+}
+
+func TestForeignMessages(t *testing.T) {
+	tracker, stat, stream := newTracker(t, "1m")
+	tracker.Published(stream, 1, time.Now())
+	for _, msg := range []string{
+		`not json`,
+		`{"other": "field"}`,
+		`{"trace": 42}`,
+		`{"trace": "other-run:0:1"}`,
+		`{"trace": "run:99:1"}`,
+		`{"trace": "run:x:1"}`,
+	} {
+		tracker.Handle([]byte(msg), time.Now())
+	}
+	for delivery := statistics.Received; delivery <= statistics.Gap; delivery++ {
+		if stat.count(delivery) != 0 {
+			t.Errorf("expect no %v for foreign messages, got %v", delivery.String(), stat.count(delivery))
+		}
+	}
+}
+
+func TestConsumer(t *testing.T) {
+	tracker, stat, stream := newTracker(t, "200ms")
+	consumer := make(ChanConsumer)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := tracker.Start(ctx, consumer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Published(stream, 1, time.Now())
+	tracker.Published(stream, 2, time.Now())
+	consumer <- message(tracker, stream, 1)
+	deadline := time.Now().Add(5 * time.Second)
+	for stat.count(statistics.Lost) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stat.count(statistics.Received) != 1 || stat.count(statistics.Lost) != 1 {
+		t.Errorf("expect 1 received and 1 lost event, got %v", stat.deliveries)
+	}
+}
+
+func TestInvalidTimeout(t *testing.T) {
+	for _, timeout := range []string{"0s", "-1m", "soon"} {
+		_, err := New("run", Config{Type: "test", Timeout: timeout})
+		if err == nil {
+			t.Error("expect error for timeout", timeout)
+		}
+	}
+	tracker, err := New("run", Config{Type: "test", Timeout: "5ns"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracker.expireInterval() != MinExpireInterval {
+		t.Error("expect MinExpireInterval for very short timeouts, got", tracker.expireInterval())
+	}
+}
+
+func TestWait(t *testing.T) {
+	tracker, stat, stream := newTracker(t, "10s")
+	consumer := make(ChanConsumer)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := tracker.Start(ctx, consumer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracker.Wait() != 0 {
+		t.Error("expect no pending events")
+	}
+	tracker.Published(stream, 1, time.Now())
+	tracker.Published(stream, 2, time.Now())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		consumer <- message(tracker, stream, 1)
+		consumer <- message(tracker, stream, 2)
+	}()
+	start := time.Now()
+	if pending := tracker.Wait(); pending != 0 {
+		t.Error("expect all events received, got", pending)
+	}
+	if time.Since(start) > 5*time.Second || stat.count(statistics.Received) != 2 {
+		t.Error("expect the wait to end with the last receipt")
+	}
+}
+
+func TestWaitExpired(t *testing.T) {
+	tracker, stat, stream := newTracker(t, "100ms")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := tracker.Start(ctx, make(ChanConsumer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Published(stream, 1, time.Now())
+	if pending := tracker.Wait(); pending != 0 || stat.count(statistics.Lost) != 1 {
+		t.Errorf("expect the event to be lost after the timeout, got %v pending and %v lost", pending, stat.count(statistics.Lost))
+	}
+}